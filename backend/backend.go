@@ -35,17 +35,41 @@ type Backend interface {
 	// Get retrieves the backend entry at provided path.
 	Get(ctx context.Context, path string) ([]*BackendEntry, error)
 
-	// List reports the existing paths.
-	List(ctx context.Context) ([]string, error)
+	// List reports the existing paths that start with the prefix,
+	// in lexical order. An empty prefix reports every path.
+	List(ctx context.Context, prefix string) ([]string, error)
 
 	// Delete removes all secrets at the given path.
 	//
 	// This should "succeed" in deleting even not-existing
 	// secrets at the provided path.
 	Delete(ctx context.Context, path string) error
+
+	// Transaction performs all the operations atomically. Either all
+	// the operations are persisted, or none of them are.
+	Transaction(ctx context.Context, txns []*TxnEntry) error
 }
 
 type BackendEntry struct {
 	Key   string
 	Value []byte
 }
+
+type Operation string
+
+const (
+
+	// PutOperation patches the entries at the path.
+	PutOperation Operation = "put"
+
+	// DeleteOperation removes the entry keys at the path. When no entries
+	// are provided, all secrets at the path are removed.
+	DeleteOperation Operation = "delete"
+)
+
+// TxnEntry is a single operation performed within a transaction.
+type TxnEntry struct {
+	Operation Operation
+	Path      string
+	Entries   []*BackendEntry
+}
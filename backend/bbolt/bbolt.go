@@ -1,6 +1,7 @@
 package bbolt
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 
@@ -163,10 +164,11 @@ func (b *BoltBackend) Get(ctx context.Context, path string) ([]*backend.BackendE
 	return entries, nil
 }
 
-// List reports all existing paths.
-// This can be used in conjunction with filtering or sorting operations.
-// By default, this does not provide filtering / sorting.
-func (b *BoltBackend) List(ctx context.Context) ([]string, error) {
+// List reports the existing paths that start with the prefix.
+//
+// Paths are stored as top level buckets in lexical order, so this seeks
+// directly to the prefix rather than iterating every bucket.
+func (b *BoltBackend) List(ctx context.Context, prefix string) ([]string, error) {
 	tx, err := b.db.Begin(false)
 
 	if err != nil {
@@ -175,19 +177,12 @@ func (b *BoltBackend) List(ctx context.Context) ([]string, error) {
 
 	paths := []string{}
 
-	err = tx.ForEach(func(name []byte, b *bolt.Bucket) error {
-		paths = append(paths, string(name))
-		return nil
-	})
-
-	if err != nil {
-		rbErr := tx.Rollback()
+	prefixBytes := []byte(prefix)
 
-		if rbErr != nil {
-			return nil, fmt.Errorf("failed to list root paths: %s. rollback failed: %s", err.Error(), rbErr.Error())
-		}
+	cursor := tx.Cursor()
 
-		return nil, fmt.Errorf("failed to list root paths: %s", err.Error())
+	for name, _ := cursor.Seek(prefixBytes); name != nil && bytes.HasPrefix(name, prefixBytes); name, _ = cursor.Next() {
+		paths = append(paths, string(name))
 	}
 
 	err = tx.Rollback()
@@ -227,3 +222,85 @@ func (b *BoltBackend) Delete(ctx context.Context, path string) error {
 	return tx.Commit()
 
 }
+
+// Transaction performs the operations within a single bolt transaction.
+// If any of the operations fail, none of them are persisted.
+func (b *BoltBackend) Transaction(ctx context.Context, txns []*backend.TxnEntry) error {
+
+	tx, err := b.db.Begin(true)
+
+	if err != nil {
+		return err
+	}
+
+	for _, txn := range txns {
+
+		err = applyTxn(tx, txn)
+
+		if err != nil {
+
+			rbErr := tx.Rollback()
+
+			if rbErr != nil {
+				return fmt.Errorf("failed to %s entries at path '%s': %s. rollback failed: %s", txn.Operation, txn.Path, err.Error(), rbErr.Error())
+			}
+
+			return fmt.Errorf("failed to %s entries at path '%s': %s", txn.Operation, txn.Path, err.Error())
+		}
+	}
+
+	return tx.Commit()
+}
+
+// applyTxn performs a single transaction operation.
+func applyTxn(tx *bolt.Tx, txn *backend.TxnEntry) error {
+
+	switch txn.Operation {
+
+	case backend.PutOperation:
+
+		bucket, err := tx.CreateBucketIfNotExists([]byte(txn.Path))
+
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range txn.Entries {
+			if err := bucket.Put([]byte(entry.Key), entry.Value); err != nil {
+				return err
+			}
+		}
+
+		return nil
+
+	case backend.DeleteOperation:
+
+		if len(txn.Entries) == 0 {
+
+			err := tx.DeleteBucket([]byte(txn.Path))
+
+			if err == bolt.ErrBucketNotFound {
+				return nil
+			}
+
+			return err
+		}
+
+		bucket := tx.Bucket([]byte(txn.Path))
+
+		if bucket == nil {
+			return nil
+		}
+
+		for _, entry := range txn.Entries {
+			if err := bucket.Delete([]byte(entry.Key)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+
+	default:
+		return fmt.Errorf("unsupported operation '%s'", txn.Operation)
+	}
+}
@@ -0,0 +1,176 @@
+package bbolt
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/woodrufj4/keyring-practice/backend"
+)
+
+const (
+	DefaultTestKeyringPath = "keyring-test.db"
+)
+
+func TestBoltTransaction(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("to slow for testing.Short. IO operations")
+	}
+
+	b := setupBackend(t)
+	ctx := context.Background()
+
+	err := b.Put(ctx, "secret/foo", []*backend.BackendEntry{
+		{Key: "a", Value: []byte("1")},
+		{Key: "b", Value: []byte("2")},
+	})
+
+	if err != nil {
+		t.Fatalf("failed to put entries: %s", err.Error())
+	}
+
+	err = b.Transaction(ctx, []*backend.TxnEntry{
+		{
+			Operation: backend.DeleteOperation,
+			Path:      "secret/foo",
+			Entries:   []*backend.BackendEntry{{Key: "a"}},
+		},
+		{
+			Operation: backend.PutOperation,
+			Path:      "secret/bar",
+			Entries:   []*backend.BackendEntry{{Key: "c", Value: []byte("3")}},
+		},
+	})
+
+	if err != nil {
+		t.Fatalf("failed to perform transaction: %s", err.Error())
+	}
+
+	entries, err := b.Get(ctx, "secret/foo")
+
+	if err != nil {
+		t.Fatalf("failed to get entries: %s", err.Error())
+	}
+
+	if len(entries) != 1 || entries[0].Key != "b" {
+		t.Fatalf("expected only key 'b' to remain, but got %#v", entries)
+	}
+
+	entries, err = b.Get(ctx, "secret/bar")
+
+	if err != nil {
+		t.Fatalf("failed to get entries: %s", err.Error())
+	}
+
+	if len(entries) != 1 || entries[0].Key != "c" {
+		t.Fatalf("expected key 'c' to be written, but got %#v", entries)
+	}
+
+}
+
+func TestBoltTransactionRollback(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("to slow for testing.Short. IO operations")
+	}
+
+	b := setupBackend(t)
+	ctx := context.Background()
+
+	err := b.Transaction(ctx, []*backend.TxnEntry{
+		{
+			Operation: backend.PutOperation,
+			Path:      "secret/foo",
+			Entries:   []*backend.BackendEntry{{Key: "a", Value: []byte("1")}},
+		},
+		{
+			Operation: "unsupported",
+			Path:      "secret/foo",
+		},
+	})
+
+	if err == nil {
+		t.Fatalf("expected transaction with an unsupported operation to fail")
+	}
+
+	entries, err := b.Get(ctx, "secret/foo")
+
+	if err != nil {
+		t.Fatalf("failed to get entries: %s", err.Error())
+	}
+
+	if entries != nil {
+		t.Fatalf("expected failed transaction to persist nothing, but got %#v", entries)
+	}
+
+}
+
+func TestBoltListPrefix(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("to slow for testing.Short. IO operations")
+	}
+
+	b := setupBackend(t)
+	ctx := context.Background()
+
+	for _, path := range []string{"secret/app", "secret/app/db", "secret/apple", "other/app"} {
+		if err := b.Put(ctx, path, []*backend.BackendEntry{{Key: "a", Value: []byte("1")}}); err != nil {
+			t.Fatalf("failed to put entries: %s", err.Error())
+		}
+	}
+
+	paths, err := b.List(ctx, "secret/app/")
+
+	if err != nil {
+		t.Fatalf("failed to list paths: %s", err.Error())
+	}
+
+	if !reflect.DeepEqual(paths, []string{"secret/app/db"}) {
+		t.Fatalf("expected only paths within the prefix, but got %v", paths)
+	}
+
+	paths, err = b.List(ctx, "")
+
+	if err != nil {
+		t.Fatalf("failed to list paths: %s", err.Error())
+	}
+
+	if len(paths) != 4 {
+		t.Fatalf("expected an empty prefix to list every path, but got %v", paths)
+	}
+
+}
+
+func setupBackend(t *testing.T) backend.Backend {
+	t.Helper()
+
+	config := DefaultConfig()
+	config.Path = DefaultTestKeyringPath
+
+	b, err := NewBoltBackend(config)
+
+	if err != nil {
+		t.Fatalf("failed to instantiate backend: %s", err.Error())
+	}
+
+	if err := b.Setup(context.Background()); err != nil {
+		t.Fatalf("failed to setup backend: %s", err.Error())
+	}
+
+	t.Cleanup(func() {
+
+		if shutdownErr := b.Cleanup(context.Background()); shutdownErr != nil {
+			t.Errorf("failed to cleanly shutdown the backend: %s", shutdownErr.Error())
+		}
+
+		if removeErr := os.Remove(DefaultTestKeyringPath); removeErr != nil {
+			t.Errorf("failed to remove backend artifact: %s", removeErr.Error())
+		}
+
+	})
+
+	return b
+}
@@ -0,0 +1,81 @@
+package command
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/mitchellh/cli"
+	"github.com/woodrufj4/keyring-practice/backend"
+	"github.com/woodrufj4/keyring-practice/internal"
+)
+
+// EnvArchivePassphrase sets the passphrase of exported and imported
+// archives, rather than prompting for it.
+const EnvArchivePassphrase = "KEYRING_ARCHIVE_PASSPHRASE"
+
+// archivePassphrase reads the archive passphrase from the environment,
+// otherwise prompting for it. When confirm is set, the prompted passphrase
+// must be entered twice.
+func archivePassphrase(ui cli.Ui, confirm bool) ([]byte, error) {
+
+	if passphrase := os.Getenv(EnvArchivePassphrase); passphrase != "" {
+		return []byte(passphrase), nil
+	}
+
+	passphrase, err := ui.AskSecret("Archive passphrase:")
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read passphrase: %s", err.Error())
+	}
+
+	if passphrase == "" {
+		return nil, fmt.Errorf("passphrase must not be empty")
+	}
+
+	if confirm {
+
+		confirmation, err := ui.AskSecret("Confirm archive passphrase:")
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to read passphrase: %s", err.Error())
+		}
+
+		if confirmation != passphrase {
+			return nil, fmt.Errorf("passphrases do not match")
+		}
+	}
+
+	return []byte(passphrase), nil
+}
+
+// decodeArchiveKey decodes a base64 recipient or identity key.
+func decodeArchiveKey(raw string) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(strings.TrimSpace(raw))
+}
+
+// archiveEntries converts the key value pairs of the record into entries,
+// ordered by key.
+func archiveEntries(record *internal.ArchiveRecord) []*backend.BackendEntry {
+
+	keys := make([]string, 0, len(record.Data))
+
+	for key := range record.Data {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	entries := make([]*backend.BackendEntry, 0, len(keys))
+
+	for _, key := range keys {
+		entries = append(entries, &backend.BackendEntry{
+			Key:   key,
+			Value: record.Data[key],
+		})
+	}
+
+	return entries
+}
@@ -0,0 +1,76 @@
+package command
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/mitchellh/cli"
+	"github.com/woodrufj4/keyring-practice/internal"
+)
+
+// unlockBarrier sets up the configured backend and initializes the existing
+// barrier with the configured root token.
+//
+// Any failure is reported to the ui, in which case ok is false. Otherwise,
+// the caller is responsible for calling cleanup to shutdown the backend.
+func unlockBarrier(ctx context.Context, ui cli.Ui, config *internal.GeneralConfig) (barrier *internal.Barrier, cleanup func(), ok bool) {
+
+	if config.RootToken == "" {
+		ui.Error("missing root token")
+		return nil, nil, false
+	}
+
+	rootTokenBytes, err := base64.StdEncoding.DecodeString(config.RootToken)
+
+	if err != nil {
+		ui.Error(fmt.Sprintf("not able to decode root token: %s", err.Error()))
+		return nil, nil, false
+	}
+
+	kvbackend, err := internal.SetupBackend(ctx, config)
+
+	if err != nil {
+		ui.Error(fmt.Sprintf("failed to setup backend: %s", err.Error()))
+		return nil, nil, false
+	}
+
+	cleanup = func() {
+		cleanupErr := kvbackend.Cleanup(ctx)
+		if cleanupErr != nil {
+			ui.Warn(fmt.Sprintf("failed to gracefully shutdown backend: %s", cleanupErr.Error()))
+		}
+	}
+
+	barrier, err = internal.NewBarrier(kvbackend)
+
+	if err != nil {
+		cleanup()
+		ui.Error(fmt.Sprintf("failed to instantiate barrier: %s", err.Error()))
+		return nil, nil, false
+	}
+
+	initialized, err := barrier.KeyringPersisted(ctx)
+
+	if err != nil {
+		cleanup()
+		ui.Error(fmt.Sprintf("failed to validate existing barrier: %s", err.Error()))
+		return nil, nil, false
+	}
+
+	if !initialized {
+		cleanup()
+		ui.Warn("keyring not initialized. Run keyring init.")
+		return nil, nil, false
+	}
+
+	err = barrier.Initialize(ctx, string(rootTokenBytes), nil)
+
+	if err != nil {
+		cleanup()
+		ui.Error(fmt.Sprintf("failed to initialize existing barrier: %s", err.Error()))
+		return nil, nil, false
+	}
+
+	return barrier, cleanup, true
+}
@@ -20,16 +20,56 @@ func CommandFactory() map[string]cli.CommandFactory {
 	}
 
 	commands := map[string]cli.CommandFactory{
+		"copy": func() (cli.Command, error) {
+			return &KVCopyCommand{
+				ui: &coloredUI,
+			}, nil
+		},
 		"delete": func() (cli.Command, error) {
 			return &KVDeleteCommand{
 				ui: &coloredUI,
 			}, nil
 		},
+		"destroy": func() (cli.Command, error) {
+			return &KVDestroyCommand{
+				ui: &coloredUI,
+			}, nil
+		},
+		"detokenize": func() (cli.Command, error) {
+			return &DetokenizeCommand{
+				ui: &coloredUI,
+			}, nil
+		},
+		"exec": func() (cli.Command, error) {
+			return &ExecCommand{
+				ui: &coloredUI,
+			}, nil
+		},
+		"export": func() (cli.Command, error) {
+			return &ExportCommand{
+				ui: &coloredUI,
+			}, nil
+		},
+		"generate": func() (cli.Command, error) {
+			return &GenerateCommand{
+				ui: &coloredUI,
+			}, nil
+		},
+		"generate-keypair": func() (cli.Command, error) {
+			return &GenerateKeypairCommand{
+				ui: &coloredUI,
+			}, nil
+		},
 		"get": func() (cli.Command, error) {
 			return &KVGetCommand{
 				ui: &coloredUI,
 			}, nil
 		},
+		"import": func() (cli.Command, error) {
+			return &ImportCommand{
+				ui: &coloredUI,
+			}, nil
+		},
 		"init": func() (cli.Command, error) {
 			return &InitCommand{
 				ui: &coloredUI,
@@ -40,11 +80,101 @@ func CommandFactory() map[string]cli.CommandFactory {
 				ui: &coloredUI,
 			}, nil
 		},
+		"metadata": func() (cli.Command, error) {
+			return &KVMetadataCommand{
+				ui: &coloredUI,
+			}, nil
+		},
+		"metadata get": func() (cli.Command, error) {
+			return &KVMetadataGetCommand{
+				ui: &coloredUI,
+			}, nil
+		},
+		"metadata put": func() (cli.Command, error) {
+			return &KVMetadataPutCommand{
+				ui: &coloredUI,
+			}, nil
+		},
+		"move": func() (cli.Command, error) {
+			return &KVMoveCommand{
+				ui: &coloredUI,
+			}, nil
+		},
+		"operator": func() (cli.Command, error) {
+			return &OperatorCommand{
+				ui: &coloredUI,
+			}, nil
+		},
+		"operator configure": func() (cli.Command, error) {
+			return &OperatorConfigureCommand{
+				ui: &coloredUI,
+			}, nil
+		},
+		"operator reap": func() (cli.Command, error) {
+			return &OperatorReapCommand{
+				ui: &coloredUI,
+			}, nil
+		},
+		"patch": func() (cli.Command, error) {
+			return &KVPatchCommand{
+				ui: &coloredUI,
+			}, nil
+		},
 		"put": func() (cli.Command, error) {
 			return &KVPutCommand{
 				ui: &coloredUI,
 			}, nil
 		},
+		"render": func() (cli.Command, error) {
+			return &RenderCommand{
+				ui: &coloredUI,
+			}, nil
+		},
+		"rollback": func() (cli.Command, error) {
+			return &KVRollbackCommand{
+				ui: &coloredUI,
+			}, nil
+		},
+		"schema": func() (cli.Command, error) {
+			return &SchemaCommand{
+				ui: &coloredUI,
+			}, nil
+		},
+		"schema delete": func() (cli.Command, error) {
+			return &SchemaDeleteCommand{
+				ui: &coloredUI,
+			}, nil
+		},
+		"schema get": func() (cli.Command, error) {
+			return &SchemaGetCommand{
+				ui: &coloredUI,
+			}, nil
+		},
+		"schema list": func() (cli.Command, error) {
+			return &SchemaListCommand{
+				ui: &coloredUI,
+			}, nil
+		},
+		"schema put": func() (cli.Command, error) {
+			return &SchemaPutCommand{
+				ui: &coloredUI,
+			}, nil
+		},
+		"search": func() (cli.Command, error) {
+			return &SearchCommand{
+				ui: &coloredUI,
+			}, nil
+		},
+		"template": func() (cli.Command, error) {
+			return &TemplateCommand{
+				ui: &coloredUI,
+			}, nil
+		},
+		"tokenize": func() (cli.Command, error) {
+			return &TokenizeCommand{
+				ui: &coloredUI,
+			}, nil
+		},
 		"transit": func() (cli.Command, error) {
 			return &TransitCommand{
 				ui: &coloredUI,
@@ -60,6 +190,16 @@ func CommandFactory() map[string]cli.CommandFactory {
 				ui: &coloredUI,
 			}, nil
 		},
+		"undelete": func() (cli.Command, error) {
+			return &KVUndeleteCommand{
+				ui: &coloredUI,
+			}, nil
+		},
+		"unset": func() (cli.Command, error) {
+			return &KVUnsetCommand{
+				ui: &coloredUI,
+			}, nil
+		},
 	}
 	return commands
 
@@ -32,23 +32,34 @@ Usage: keying transit decrypt [options] <base64ciphertext>
 
       If not provided here, the '%s' environment
       variable will be used.
+
+    -format=<string>
+      The output format: table, json or yaml. Defaults to table,
+      or the '%s' environment variable when set.
 `
 
-	return fmt.Sprintf(helpText, EnvSecretKey)
+	return fmt.Sprintf(helpText, EnvSecretKey, EnvFormat)
 }
 
 func (dc *DecryptCommand) Run(args []string) int {
 
 	var secretKey string
+	var format string
 
 	fs := flag.NewFlagSet("decrypt", flag.ContinueOnError)
 	fs.StringVar(&secretKey, "secret-key", "", "The secret key used to decrypt ciphertext")
+	formatFlag(fs, &format)
 
 	if err := fs.Parse(args); err != nil {
 		dc.ui.Error("failed to parse flags")
 		return 1
 	}
 
+	if err := validateFormat(format); err != nil {
+		dc.ui.Error(err.Error())
+		return 1
+	}
+
 	if secretKey == "" {
 		secretKey = os.Getenv(EnvSecretKey)
 
@@ -97,7 +108,19 @@ func (dc *DecryptCommand) Run(args []string) int {
 		return 1
 	}
 
-	dc.ui.Output(fmt.Sprintf("plaintext: %s", plaintext))
+	err = outputFormatted(dc.ui, format, &decryptOutput{Plaintext: string(plaintext)}, func() {
+		dc.ui.Output(fmt.Sprintf("plaintext: %s", plaintext))
+	})
+
+	if err != nil {
+		dc.ui.Error(err.Error())
+		return 1
+	}
 
 	return 0
 }
+
+// decryptOutput is the json and yaml schema of keyring transit decrypt.
+type decryptOutput struct {
+	Plaintext string `json:"plaintext"`
+}
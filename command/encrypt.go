@@ -42,23 +42,34 @@ Usage: keying transit encrypt [options] <plaintext>
       The secret key should be an AES key,
       either 16, 24, or 32 bytes long to select
       AES-128, AES-192, or AES-256.
+
+    -format=<string>
+      The output format: table, json or yaml. Defaults to table,
+      or the '%s' environment variable when set.
 `
 
-	return fmt.Sprintf(helpText, EnvSecretKey)
+	return fmt.Sprintf(helpText, EnvSecretKey, EnvFormat)
 }
 
 func (ec *EncryptCommand) Run(args []string) int {
 
 	var secretKey string
+	var format string
 
 	fs := flag.NewFlagSet("encrypt", flag.ContinueOnError)
 	fs.StringVar(&secretKey, "secret-key", "", "The secret key used to encrypt ciphertext")
+	formatFlag(fs, &format)
 
 	if err := fs.Parse(args); err != nil {
 		ec.ui.Error("failed to parse flags")
 		return 1
 	}
 
+	if err := validateFormat(format); err != nil {
+		ec.ui.Error(err.Error())
+		return 1
+	}
+
 	if secretKey == "" {
 		secretKey = os.Getenv(EnvSecretKey)
 
@@ -116,7 +127,21 @@ func (ec *EncryptCommand) Run(args []string) int {
 
 	cipherBytes := gcm.Seal(out, nonce, []byte(plaintext), nil)
 
-	ec.ui.Info(fmt.Sprintf("ciphertext: %s", base64.StdEncoding.EncodeToString(cipherBytes)))
+	ciphertext := base64.StdEncoding.EncodeToString(cipherBytes)
+
+	err = outputFormatted(ec.ui, format, &encryptOutput{Ciphertext: ciphertext}, func() {
+		ec.ui.Info(fmt.Sprintf("ciphertext: %s", ciphertext))
+	})
+
+	if err != nil {
+		ec.ui.Error(err.Error())
+		return 1
+	}
 
 	return 0
 }
+
+// encryptOutput is the json and yaml schema of keyring transit encrypt.
+type encryptOutput struct {
+	Ciphertext string `json:"ciphertext"`
+}
@@ -0,0 +1,245 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/mitchellh/cli"
+	"github.com/woodrufj4/keyring-practice/internal"
+)
+
+type ExecCommand struct {
+	ui cli.Ui
+}
+
+func (ec ExecCommand) Synopsis() string {
+	return "Runs a command with the key-value pairs at a path as environment variables"
+}
+
+func (ec ExecCommand) Help() string {
+	helpText := `
+Usage: keying exec [options] -path=<path> -- <command> [args]
+
+  Runs the command with the key-value pairs at the path added to its
+  environment, so the values never pass through the shell.
+
+  Keys are upper-cased, with any character other than letters, digits
+  and underscores replaced by an underscore. Keys which map to the same
+  name, such as a-b and a.b, are refused. String values are set as is,
+  while any other value is set as json.
+
+  Signals received by keyring are forwarded to the command, and keyring
+  exits with the exit code of the command.
+
+  Example:
+
+    $ keyring exec -path=secret/app -prefix=APP_ -- ./server
+
+  Options:
+
+    -path=<string>
+      The path of the key-value pairs to expose. Required.
+
+    -prefix=<string>
+      A prefix added to every environment variable name.
+
+    -root-token=<string>
+      The root token to access the keyring.
+      If not provided here, the '%s' environment
+      variable will be used.
+
+  Backend Options:
+
+    -backend-type=<string>
+      The type of backend to use.
+      Currently, only the 'file' type backend is supported,
+      and is also the default.
+
+    File Backend Options:
+
+      -filepath=<string>
+        The file path where your secrets will be persisted to disc.
+`
+	return fmt.Sprintf(helpText, internal.DefaultEnvRootToken)
+}
+
+func (ec *ExecCommand) Run(args []string) int {
+
+	var path, prefix string
+
+	fs := flag.NewFlagSet("exec", flag.ContinueOnError)
+	fs.StringVar(&path, "path", "", "The path of the key-value pairs to expose")
+	fs.StringVar(&prefix, "prefix", "", "A prefix added to every environment variable name")
+
+	config, err := internal.ReadFlagSetConfig(fs, args)
+
+	if err != nil {
+		ec.ui.Error(fmt.Sprintf("not able to read config: %s", err.Error()))
+		return 1
+	}
+
+	if path == "" {
+		ec.ui.Error("missing path")
+		return 1
+	}
+
+	if strings.HasPrefix(path, internal.KeyringPrefix) {
+		ec.ui.Warn(fmt.Sprintf("paths prefixed with %s are restricted", internal.KeyringPrefix))
+		return 1
+	}
+
+	if len(fs.Args()) == 0 {
+		ec.ui.Error("missing command to run")
+		return 1
+	}
+
+	env, ok := ec.secretEnv(config, path, prefix)
+
+	if !ok {
+		return 1
+	}
+
+	cmd := exec.Command(fs.Arg(0), fs.Args()[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), env...)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT)
+	defer signal.Stop(signals)
+
+	if err := cmd.Start(); err != nil {
+		ec.ui.Error(fmt.Sprintf("failed to start command: %s", err.Error()))
+		return 1
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				cmd.Process.Signal(sig)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	err = cmd.Wait()
+
+	var exitErr *exec.ExitError
+
+	if errors.As(err, &exitErr) {
+		return exitCode(exitErr)
+	}
+
+	if err != nil {
+		ec.ui.Error(fmt.Sprintf("failed to run command: %s", err.Error()))
+		return 1
+	}
+
+	return 0
+}
+
+// secretEnv reads the key-value pairs at the path as environment variables.
+//
+// The backend is shutdown before returning, so the keyring is not held
+// open for the lifetime of the command.
+func (ec *ExecCommand) secretEnv(config *internal.GeneralConfig, path string, prefix string) ([]string, bool) {
+
+	defaultCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+
+	defer cancel()
+
+	barrier, cleanup, ok := unlockBarrier(defaultCtx, ec.ui, config)
+
+	if !ok {
+		return nil, false
+	}
+
+	defer cleanup()
+
+	kvStore, err := internal.NewKV(barrier)
+
+	if err != nil {
+		ec.ui.Error(fmt.Sprintf("failed to instantiate kv store: %s", err.Error()))
+		return nil, false
+	}
+
+	_, data, err := readKVData(defaultCtx, kvStore, path)
+
+	if err != nil {
+		ec.ui.Error(fmt.Sprintf("failed to retrieve secrets at path '%s': %s", path, err.Error()))
+		return nil, false
+	}
+
+	env := make([]string, 0, len(data))
+	names := make(map[string]string, len(data))
+
+	for key, value := range data {
+
+		raw, err := rawFieldValue(value)
+
+		if err != nil {
+			ec.ui.Error(fmt.Sprintf("failed to format field '%s': %s", key, err.Error()))
+			return nil, false
+		}
+
+		name := envName(prefix, key)
+
+		if other, ok := names[name]; ok {
+			ec.ui.Error(fmt.Sprintf("keys '%s' and '%s' at path '%s' both map to the environment variable %s", other, key, path, name))
+			return nil, false
+		}
+
+		names[name] = key
+		env = append(env, name+"="+raw)
+	}
+
+	sort.Strings(env)
+
+	return env, true
+}
+
+// envName converts the key into an upper-cased environment variable name.
+func envName(prefix string, key string) string {
+	return sanitizeEnvName(strings.ToUpper(prefix + key))
+}
+
+// sanitizeEnvName replaces any character other than letters, digits and
+// underscores with an underscore.
+func sanitizeEnvName(name string) string {
+
+	runes := []rune(name)
+
+	for i, r := range runes {
+		if !(r == '_' || (r >= 'A' && r <= 'Z') || (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9')) {
+			runes[i] = '_'
+		}
+	}
+
+	return string(runes)
+}
+
+// exitCode reports the exit code of the command. Commands terminated by a
+// signal report 128 plus the signal number, like a shell does.
+func exitCode(exitErr *exec.ExitError) int {
+
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+
+	return exitErr.ExitCode()
+}
@@ -0,0 +1,226 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/cli"
+	"github.com/woodrufj4/keyring-practice/internal"
+)
+
+type ExportCommand struct {
+	ui cli.Ui
+}
+
+func (ec ExportCommand) Synopsis() string {
+	return "Exports the stored paths into an encrypted archive"
+}
+
+func (ec ExportCommand) Help() string {
+	helpText := `
+Usage: keying export [options] -out=<file> [prefix]
+
+  Exports the current version of every path within the prefix into an
+  archive, which can be imported into another keyring with keyring import.
+  Without a prefix, every path is exported. Version history and metadata
+  are not exported, and deleted, destroyed or expired paths are skipped.
+
+  The archive is encrypted independently of the keyring, either with a
+  passphrase or for a recipient key. The passphrase is read from the
+  '%s' environment variable, or prompted for.
+  Recipient keys are generated with keyring import -generate-identity.
+
+  Example:
+
+    $ keyring export -out=dump.enc secret/
+
+    $ keyring export -out=dump.enc -recipient=<key> secret/
+
+    $ keyring export -out=prod.enc -selector=env=prod secret/
+
+  Options:
+
+    -out=<string>
+      The file to write the archive to. Required.
+
+    -recipient=<string>
+      The base64 recipient key to encrypt the archive for, rather
+      than a passphrase.
+
+    -selector=<string>
+      Only export the paths within the prefix whose labels match the
+      label selector, such as 'env=prod,team=payments'.
+
+    -root-token=<string>
+      The root token to access the keyring.
+      If not provided here, the '%s' environment
+      variable will be used.
+
+  Backend Options:
+
+    -backend-type=<string>
+      The type of backend to use.
+      Currently, only the 'file' type backend is supported,
+      and is also the default.
+
+    File Backend Options:
+
+      -filepath=<string>
+        The file path where your secrets will be persisted to disc.
+`
+	return fmt.Sprintf(helpText, EnvArchivePassphrase, internal.DefaultEnvRootToken)
+}
+
+func (ec *ExportCommand) Run(args []string) int {
+
+	defaultCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+
+	defer cancel()
+
+	var out, rawRecipient, rawSelector string
+
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	fs.StringVar(&out, "out", "", "The file to write the archive to")
+	fs.StringVar(&rawRecipient, "recipient", "", "The base64 recipient key to encrypt the archive for")
+	fs.StringVar(&rawSelector, "selector", "", "Only export the paths whose labels match the selector")
+
+	config, err := internal.ReadFlagSetConfig(fs, args)
+
+	if err != nil {
+		ec.ui.Error(fmt.Sprintf("not able to read config: %s", err.Error()))
+		return 1
+	}
+
+	if out == "" {
+		ec.ui.Error("missing output file")
+		return 1
+	}
+
+	selector, err := internal.ParseLabelSelector(rawSelector)
+
+	if err != nil {
+		ec.ui.Error(fmt.Sprintf("not able to parse selector: %s", err.Error()))
+		return 1
+	}
+
+	prefix := fs.Arg(0)
+
+	if strings.HasPrefix(prefix, internal.KeyringPrefix) {
+		ec.ui.Warn(fmt.Sprintf("paths prefixed with %s are restricted", internal.KeyringPrefix))
+		return 1
+	}
+
+	var recipient, passphrase []byte
+
+	if rawRecipient != "" {
+
+		recipient, err = decodeArchiveKey(rawRecipient)
+
+		if err != nil {
+			ec.ui.Error(fmt.Sprintf("not able to decode recipient: %s", err.Error()))
+			return 1
+		}
+
+	} else {
+
+		passphrase, err = archivePassphrase(ec.ui, true)
+
+		if err != nil {
+			ec.ui.Error(err.Error())
+			return 1
+		}
+	}
+
+	barrier, cleanup, ok := unlockBarrier(defaultCtx, ec.ui, config)
+
+	if !ok {
+		return 1
+	}
+
+	defer cleanup()
+
+	kvStore, err := internal.NewKV(barrier)
+
+	if err != nil {
+		ec.ui.Error(fmt.Sprintf("failed to instantiate kv store: %s", err.Error()))
+		return 1
+	}
+
+	folder := kvFolder(prefix)
+
+	children, err := kvStore.Select(defaultCtx, folder, selector)
+
+	if err != nil {
+		ec.ui.Error(fmt.Sprintf("failed to list secrets within %s: %s", folder, err.Error()))
+		return 1
+	}
+
+	exported := 0
+
+	err = writeAtomic(out, 0600, func(w io.Writer) error {
+
+		var archive *internal.ArchiveWriter
+
+		if recipient != nil {
+			archive, err = internal.NewRecipientArchiveWriter(w, recipient)
+		} else {
+			archive, err = internal.NewPassphraseArchiveWriter(w, passphrase)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		for _, child := range children {
+
+			path := folder + child
+
+			secret, err := kvStore.Get(defaultCtx, path)
+
+			if errors.Is(err, internal.ErrKVExpired) {
+				ec.ui.Warn(fmt.Sprintf("Skipping %s: %s", path, err.Error()))
+				continue
+			}
+
+			if err != nil {
+				return fmt.Errorf("failed to retrieve secrets at path '%s': %s", path, err.Error())
+			}
+
+			if secret == nil || secret.Entries == nil {
+				ec.ui.Warn(fmt.Sprintf("Skipping %s: the current version has been deleted or destroyed", path))
+				continue
+			}
+
+			record := &internal.ArchiveRecord{
+				Path: path,
+				Data: make(map[string][]byte, len(secret.Entries)),
+			}
+
+			for _, entry := range secret.Entries {
+				record.Data[entry.Key] = entry.Value
+			}
+
+			if err := archive.Write(record); err != nil {
+				return err
+			}
+
+			exported++
+		}
+
+		return archive.Close()
+	})
+
+	if err != nil {
+		ec.ui.Error(fmt.Sprintf("failed to export archive: %s", err.Error()))
+		return 1
+	}
+
+	ec.ui.Info(fmt.Sprintf("Success! Exported %d paths to %s", exported, out))
+
+	return 0
+}
@@ -0,0 +1,94 @@
+package command
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mitchellh/cli"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+
+	// EnvFormat sets the default output format for every command.
+	EnvFormat = "KEYRING_FORMAT"
+
+	FormatTable = "table"
+	FormatJSON  = "json"
+	FormatYAML  = "yaml"
+)
+
+// formatFlag registers the -format flag, defaulting to the
+// KEYRING_FORMAT environment variable when set.
+func formatFlag(fs *flag.FlagSet, format *string) {
+
+	defaultFormat := os.Getenv(EnvFormat)
+
+	if defaultFormat == "" {
+		defaultFormat = FormatTable
+	}
+
+	fs.StringVar(format, "format", defaultFormat, "The output format: table, json or yaml")
+}
+
+// validateFormat ensures the format is supported.
+func validateFormat(format string) error {
+
+	switch strings.ToLower(format) {
+
+	case FormatTable, FormatJSON, FormatYAML:
+		return nil
+
+	default:
+		return fmt.Errorf("unsupported output format '%s'", format)
+	}
+}
+
+// outputFormatted writes the data in the requested format. Table output is
+// delegated to the provided func, since each command lays out its own table.
+func outputFormatted(ui cli.Ui, format string, data interface{}, table func()) error {
+
+	switch strings.ToLower(format) {
+
+	case FormatJSON:
+
+		out, err := json.MarshalIndent(data, "", "  ")
+
+		if err != nil {
+			return fmt.Errorf("failed to format json: %s", err.Error())
+		}
+
+		ui.Output(string(out))
+
+	case FormatYAML:
+
+		// Round trip through json so yaml output shares the json field names.
+		jsonBytes, err := json.Marshal(data)
+
+		if err != nil {
+			return fmt.Errorf("failed to format yaml: %s", err.Error())
+		}
+
+		var generic interface{}
+
+		if err := json.Unmarshal(jsonBytes, &generic); err != nil {
+			return fmt.Errorf("failed to format yaml: %s", err.Error())
+		}
+
+		out, err := yaml.Marshal(generic)
+
+		if err != nil {
+			return fmt.Errorf("failed to format yaml: %s", err.Error())
+		}
+
+		ui.Output(strings.TrimSuffix(string(out), "\n"))
+
+	default:
+		table()
+	}
+
+	return nil
+}
@@ -0,0 +1,283 @@
+package command
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/cli"
+	"github.com/woodrufj4/keyring-practice/backend"
+	"github.com/woodrufj4/keyring-practice/internal"
+)
+
+type GenerateCommand struct {
+	ui cli.Ui
+}
+
+func (gc GenerateCommand) Synopsis() string {
+	return "Generates a password or token and stores it at the provided path"
+}
+
+func (gc GenerateCommand) Help() string {
+	helpText := `
+Usage: keying generate [options] <path> <key>
+
+  Generates a random value from a named policy and stores it under the key
+  at the path, keeping any other key-value pairs of the current version.
+  The value is only printed with -print, so it never has to be pasted
+  into keyring put.
+
+  The following policies are available:
+
+    password
+      24 characters, with at least one lower case, upper case, digit
+      and symbol character. This is the default.
+
+    alphanumeric
+      32 lower case, upper case and digit characters.
+
+    pin
+      6 digits.
+
+    passphrase
+      6 words drawn from the EFF diceware wordlist, joined by '-'.
+
+    hex, base64
+      32 random bytes, hex or base64 encoded.
+
+    uuid
+      A random version 4 UUID.
+
+  Example:
+
+    $ keyring generate secret/db password
+
+    $ keyring generate -policy=passphrase -length=8 -print secret/disk passphrase
+
+    $ keyring generate -length=16 -charsets=lower,digit:4 secret/app pin
+
+  Options:
+
+    -policy=<string>
+      The named policy to generate the value with. Defaults to password.
+
+    -length=<int>
+      Overrides the length of the policy. This is the number of
+      characters of passwords, the number of words of passphrases, or
+      the number of random bytes of hex and base64 tokens.
+
+    -charsets=<string>
+      Overrides the character classes of passwords, as a comma separated
+      list of lower, upper, digit and symbol. Each class can require a
+      minimum number of characters, such as upper:2.
+
+    -separator=<string>
+      Overrides the separator between the words of passphrases.
+
+    -print
+      Print the generated value once it is stored.
+
+    -force
+      Replace the key if it already exists at the path.
+
+    -root-token=<string>
+      The root token to access the keyring.
+      If not provided here, the '%s' environment
+      variable will be used.
+
+  Backend Options:
+
+    -backend-type=<string>
+      The type of backend to use.
+      Currently, only the 'file' type backend is supported,
+      and is also the default.
+
+    File Backend Options:
+
+      -filepath=<string>
+        The file path where your secrets will be persisted to disc.
+`
+	return fmt.Sprintf(helpText, internal.DefaultEnvRootToken)
+}
+
+func (gc *GenerateCommand) Run(args []string) int {
+
+	defaultCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+
+	defer cancel()
+
+	var policyName, rawCharsets, separator string
+	var length int
+	var printValue, force bool
+
+	fs := flag.NewFlagSet("generate", flag.ContinueOnError)
+	fs.StringVar(&policyName, "policy", "password", "The named policy to generate the value with")
+	fs.IntVar(&length, "length", 0, "Overrides the length of the policy")
+	fs.StringVar(&rawCharsets, "charsets", "", "Overrides the character classes of passwords")
+	fs.StringVar(&separator, "separator", "", "Overrides the separator between the words of passphrases")
+	fs.BoolVar(&printValue, "print", false, "Print the generated value once it is stored")
+	fs.BoolVar(&force, "force", false, "Replace the key if it already exists")
+
+	config, err := internal.ReadFlagSetConfig(fs, args)
+
+	if err != nil {
+		gc.ui.Error(fmt.Sprintf("not able to read config: %s", err.Error()))
+		return 1
+	}
+
+	namedPolicy, ok := internal.GeneratorPolicies[policyName]
+
+	if !ok {
+		gc.ui.Error(fmt.Sprintf("unknown policy '%s'. Must be one of %s", policyName, strings.Join(internal.GeneratorPolicyNames(), ", ")))
+		return 1
+	}
+
+	policy := namedPolicy.Clone()
+
+	if length != 0 {
+		policy.Length = length
+	}
+
+	if rawCharsets != "" {
+
+		if policy.Kind != internal.GeneratorKindPassword {
+			gc.ui.Error(fmt.Sprintf("-charsets is not supported by the %s policy", policyName))
+			return 1
+		}
+
+		policy.Charsets, err = internal.ParseCharsetRules(rawCharsets)
+
+		if err != nil {
+			gc.ui.Error(fmt.Sprintf("not able to parse charsets: %s", err.Error()))
+			return 1
+		}
+	}
+
+	separatorSet := false
+
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "separator" {
+			separatorSet = true
+		}
+	})
+
+	if separatorSet {
+
+		if policy.Kind != internal.GeneratorKindPassphrase {
+			gc.ui.Error(fmt.Sprintf("-separator is not supported by the %s policy", policyName))
+			return 1
+		}
+
+		policy.Separator = separator
+	}
+
+	if err := policy.Validate(); err != nil {
+		gc.ui.Error(fmt.Sprintf("invalid policy: %s", err.Error()))
+		return 1
+	}
+
+	if len(fs.Args()) != 2 {
+		gc.ui.Error("expected a path and a key")
+		return 1
+	}
+
+	path, key := fs.Arg(0), fs.Arg(1)
+
+	if path == "" || key == "" {
+		gc.ui.Error("missing path or key")
+		return 1
+	}
+
+	if strings.HasPrefix(path, internal.KeyringPrefix) {
+		gc.ui.Warn(fmt.Sprintf("paths prefixed with %s are restricted", internal.KeyringPrefix))
+		return 1
+	}
+
+	barrier, cleanup, ok := unlockBarrier(defaultCtx, gc.ui, config)
+
+	if !ok {
+		return 1
+	}
+
+	defer cleanup()
+
+	kvStore, err := internal.NewKV(barrier)
+
+	if err != nil {
+		gc.ui.Error(fmt.Sprintf("failed to instantiate kv store: %s", err.Error()))
+		return 1
+	}
+
+	secret, err := kvStore.Get(defaultCtx, path)
+
+	if err != nil {
+		gc.ui.Error(fmt.Sprintf("failed to retrieve secrets at path '%s': %s", path, err.Error()))
+		return 1
+	}
+
+	value, err := policy.Generate()
+
+	if err != nil {
+		gc.ui.Error(fmt.Sprintf("failed to generate value: %s", err.Error()))
+		return 1
+	}
+
+	valueBytes, err := internal.EncodeValue(value)
+
+	if err != nil {
+		gc.ui.Error(fmt.Sprintf("failed to encode value: %s", err.Error()))
+		return 1
+	}
+
+	entries := []*backend.BackendEntry{
+		{
+			Key:   key,
+			Value: valueBytes,
+		},
+	}
+
+	// Guard against writes made since the current version was read.
+	var cas uint64
+
+	if secret != nil {
+		cas = secret.Version
+	}
+
+	opts := &internal.KVPutOptions{CAS: &cas}
+
+	var version uint64
+
+	if secret != nil && secret.Entries != nil {
+
+		for _, entry := range secret.Entries {
+			if entry.Key == key && !force {
+				gc.ui.Error(fmt.Sprintf("key '%s' already exists at %s. Retry with -force to replace it.", key, path))
+				return 1
+			}
+		}
+
+		version, err = kvStore.Patch(defaultCtx, path, entries, opts)
+
+	} else {
+		version, err = kvStore.Put(defaultCtx, path, entries, opts)
+	}
+
+	if reportSchemaError(gc.ui, err) {
+		return 1
+	}
+
+	if err != nil {
+		gc.ui.Error(fmt.Sprintf("failed to persist generated value at path '%s': %s", path, err.Error()))
+		return 1
+	}
+
+	if printValue {
+		gc.ui.Output(value)
+	}
+
+	gc.ui.Info(fmt.Sprintf("Success! Generated '%s' at %s, version: %d", key, path, version))
+
+	return 0
+}
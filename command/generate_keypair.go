@@ -0,0 +1,196 @@
+package command
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/cli"
+	"github.com/woodrufj4/keyring-practice/backend"
+	"github.com/woodrufj4/keyring-practice/internal"
+)
+
+type GenerateKeypairCommand struct {
+	ui cli.Ui
+}
+
+func (gc GenerateKeypairCommand) Synopsis() string {
+	return "Generates an SSH or TLS keypair and stores it at the provided path"
+}
+
+func (gc GenerateKeypairCommand) Help() string {
+	helpText := `
+Usage: keying generate-keypair [options] <path>
+
+  Generates a keypair, storing the private and public keys at the path
+  under the private_key and public_key keys. Only the public key is
+  printed, so the private key never touches disk in plaintext. Use
+  keyring get -field=private_key to read the private key when needed.
+
+  Example:
+
+    $ keyring generate-keypair -comment=deploy@ci secret/ssh/deploy >> authorized_keys
+
+    $ keyring generate-keypair -type=ecdsa-p256 -format=pem secret/tls/signing
+
+  Options:
+
+    -type=<string>
+      The type of keypair: ed25519, rsa-4096 or ecdsa-p256.
+      Defaults to ed25519.
+
+    -format=<string>
+      The encoding of the keypair. With openssh, the private key is in
+      the OpenSSH format and the public key is an authorized_keys line.
+      With pem, the private key is PKCS #8 and the public key is PKIX,
+      both PEM encoded. Defaults to openssh.
+
+    -comment=<string>
+      The comment of OpenSSH keys, such as user@host.
+
+    -force
+      Replace the keypair if the path already exists, writing a new
+      version.
+
+    -root-token=<string>
+      The root token to access the keyring.
+      If not provided here, the '%s' environment
+      variable will be used.
+
+  Backend Options:
+
+    -backend-type=<string>
+      The type of backend to use.
+      Currently, only the 'file' type backend is supported,
+      and is also the default.
+
+    File Backend Options:
+
+      -filepath=<string>
+        The file path where your secrets will be persisted to disc.
+`
+	return fmt.Sprintf(helpText, internal.DefaultEnvRootToken)
+}
+
+func (gc *GenerateKeypairCommand) Run(args []string) int {
+
+	defaultCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+
+	defer cancel()
+
+	var keyType, format, comment string
+	var force bool
+
+	fs := flag.NewFlagSet("generate-keypair", flag.ContinueOnError)
+	fs.StringVar(&keyType, "type", internal.KeypairTypeEd25519, "The type of keypair")
+	fs.StringVar(&format, "format", internal.KeypairFormatOpenSSH, "The encoding of the keypair")
+	fs.StringVar(&comment, "comment", "", "The comment of OpenSSH keys")
+	fs.BoolVar(&force, "force", false, "Replace the keypair if the path already exists")
+
+	config, err := internal.ReadFlagSetConfig(fs, args)
+
+	if err != nil {
+		gc.ui.Error(fmt.Sprintf("not able to read config: %s", err.Error()))
+		return 1
+	}
+
+	if comment != "" && format != internal.KeypairFormatOpenSSH {
+		gc.ui.Error("-comment is only supported for the openssh format")
+		return 1
+	}
+
+	path := fs.Arg(0)
+
+	if path == "" {
+		gc.ui.Error("missing path")
+		return 1
+	}
+
+	if strings.HasPrefix(path, internal.KeyringPrefix) {
+		gc.ui.Warn(fmt.Sprintf("paths prefixed with %s are restricted", internal.KeyringPrefix))
+		return 1
+	}
+
+	keypair, err := internal.GenerateKeypair(keyType, format, comment)
+
+	if err != nil {
+		gc.ui.Error(fmt.Sprintf("failed to generate keypair: %s", err.Error()))
+		return 1
+	}
+
+	barrier, cleanup, ok := unlockBarrier(defaultCtx, gc.ui, config)
+
+	if !ok {
+		return 1
+	}
+
+	defer cleanup()
+
+	kvStore, err := internal.NewKV(barrier)
+
+	if err != nil {
+		gc.ui.Error(fmt.Sprintf("failed to instantiate kv store: %s", err.Error()))
+		return 1
+	}
+
+	// Only write if nothing exists at the path, unless forced.
+	var cas uint64
+
+	if force {
+
+		metadata, err := kvStore.Metadata(defaultCtx, path)
+
+		if err != nil {
+			gc.ui.Error(fmt.Sprintf("failed to retrieve metadata at path '%s': %s", path, err.Error()))
+			return 1
+		}
+
+		if metadata != nil {
+			cas = metadata.CurrentVersion
+		}
+	}
+
+	entries := make([]*backend.BackendEntry, 0, 3)
+
+	for key, value := range map[string]string{
+		"private_key": keypair.PrivateKey,
+		"public_key":  keypair.PublicKey,
+		"key_type":    keypair.Type,
+	} {
+
+		valueBytes, err := internal.EncodeValue(value)
+
+		if err != nil {
+			gc.ui.Error(fmt.Sprintf("failed to encode %s: %s", key, err.Error()))
+			return 1
+		}
+
+		entries = append(entries, &backend.BackendEntry{
+			Key:   key,
+			Value: valueBytes,
+		})
+	}
+
+	_, err = kvStore.Put(defaultCtx, path, entries, &internal.KVPutOptions{CAS: &cas})
+
+	if isCASError(err) {
+		gc.ui.Error(fmt.Sprintf("refusing to write to path '%s': the path already exists", path))
+		gc.ui.Error("Retry with -force to replace the keypair.")
+		return 1
+	}
+
+	if reportSchemaError(gc.ui, err) {
+		return 1
+	}
+
+	if err != nil {
+		gc.ui.Error(fmt.Sprintf("failed to persist keypair at path '%s': %s", path, err.Error()))
+		return 1
+	}
+
+	gc.ui.Output(strings.TrimSuffix(keypair.PublicKey, "\n"))
+
+	return 0
+}
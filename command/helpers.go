@@ -0,0 +1,341 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/woodrufj4/keyring-practice/backend"
+	"github.com/woodrufj4/keyring-practice/internal"
+)
+
+// parseVersions parses a comma separated list of versions.
+func parseVersions(raw string) ([]uint64, error) {
+
+	versions := make([]uint64, 0)
+
+	if strings.TrimSpace(raw) == "" {
+		return versions, nil
+	}
+
+	for _, part := range strings.Split(raw, ",") {
+
+		version, err := strconv.ParseUint(strings.TrimSpace(part), 10, 64)
+
+		if err != nil || version == 0 {
+			return nil, fmt.Errorf("invalid version '%s'", part)
+		}
+
+		versions = append(versions, version)
+	}
+
+	return versions, nil
+}
+
+// splitPrefixes splits a comma separated list of path prefixes, dropping
+// empty prefixes.
+func splitPrefixes(list string) []string {
+
+	prefixes := make([]string, 0)
+
+	for _, prefix := range strings.Split(list, ",") {
+		if prefix = strings.TrimSpace(prefix); prefix != "" {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+
+	return prefixes
+}
+
+// parseDuration parses a duration, additionally accepting a whole number
+// of days such as "7d". An empty duration is 0.
+func parseDuration(raw string) (time.Duration, error) {
+
+	if raw == "" {
+		return 0, nil
+	}
+
+	if strings.HasSuffix(raw, "d") {
+
+		days, err := strconv.ParseUint(strings.TrimSuffix(raw, "d"), 10, 32)
+
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration '%s'", raw)
+		}
+
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+
+	duration, err := time.ParseDuration(raw)
+
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration '%s'", raw)
+	}
+
+	return duration, nil
+}
+
+// formatTime formats the time for display, leaving unset times empty.
+func formatTime(t time.Time) string {
+
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.Format(time.RFC3339)
+}
+
+// parseKVEntries parses the key=value args into entries with values
+// stored in the provided encoding.
+//
+// Args in the form key=@file:<filename> read the value from the file,
+// which is always stored as raw bytes, or decoded from base64 with the
+// base64 encoding.
+func parseKVEntries(stdin io.Reader, args []string, encoding string) ([]*backend.BackendEntry, error) {
+
+	entries := make([]*backend.BackendEntry, 0, len(args))
+	builderArgs := make([]string, 0, len(args))
+
+	for _, arg := range args {
+
+		key, filename, ok := strings.Cut(arg, "=@file:")
+
+		if !ok || key == "" {
+			builderArgs = append(builderArgs, arg)
+			continue
+		}
+
+		contents, err := os.ReadFile(filename)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to read value of key '%s': %s", key, err.Error())
+		}
+
+		fileEncoding := internal.ValueEncodingRaw
+
+		if encoding == internal.ValueEncodingBase64 {
+			fileEncoding = encoding
+		}
+
+		value, err := internal.EncodeValueAs(fileEncoding, string(contents))
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode value of key '%s': %s", key, err.Error())
+		}
+
+		entries = append(entries, &backend.BackendEntry{
+			Key:   key,
+			Value: value,
+		})
+	}
+
+	kvPairs, err := internal.ParseArgsData(stdin, builderArgs)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(kvPairs)+len(entries) == 0 {
+		return nil, fmt.Errorf("must provide key value data")
+	}
+
+	for key, pair := range kvPairs {
+
+		entryBytes, err := internal.EncodeValueAs(encoding, pair)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode value of key '%s': %s", key, err.Error())
+		}
+
+		entries = append(entries, &backend.BackendEntry{
+			Key:   key,
+			Value: entryBytes,
+		})
+	}
+
+	return entries, nil
+}
+
+// validateValueEncoding checks the value encoding is supported.
+func validateValueEncoding(encoding string) error {
+
+	switch encoding {
+	case internal.ValueEncodingJSON, internal.ValueEncodingRaw, internal.ValueEncodingBase64:
+		return nil
+	default:
+		return fmt.Errorf("unsupported value encoding '%s'. Must be one of json, raw or base64", encoding)
+	}
+}
+
+// decodeKVEntries decodes the values written by parseKVEntries. Values
+// stored as raw bytes are decoded as []byte.
+func decodeKVEntries(entries []*backend.BackendEntry) (map[string]interface{}, error) {
+
+	data := make(map[string]interface{}, len(entries))
+
+	for _, entry := range entries {
+
+		entryValue, err := internal.DecodeValue(entry.Value)
+
+		if err != nil {
+			return nil, err
+		}
+
+		data[entry.Key] = entryValue
+	}
+
+	return data, nil
+}
+
+// readKVData retrieves and decodes the current version at the path. Unlike
+// keyring get, a missing, deleted or destroyed version is an error.
+//
+// The secret is returned along with the decoded data, and is only nil if
+// nothing has been written to the path.
+func readKVData(ctx context.Context, kvStore *internal.KV, path string) (*internal.KVSecret, map[string]interface{}, error) {
+
+	secret, err := kvStore.Get(ctx, path)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if secret == nil {
+		return nil, nil, fmt.Errorf("no value found at %s", path)
+	}
+
+	if secret.Metadata.Destroyed {
+		return secret, nil, fmt.Errorf("version %d at %s has been destroyed", secret.Version, path)
+	}
+
+	if secret.Metadata.Deleted() {
+		return secret, nil, fmt.Errorf("version %d at %s has been deleted", secret.Version, path)
+	}
+
+	data, err := decodeKVEntries(secret.Entries)
+
+	return secret, data, err
+}
+
+// writeFileAtomic writes the data to a temporary file in the same directory
+// before renaming it over the file, so readers never observe a partially
+// written file.
+func writeFileAtomic(filename string, data []byte, perms os.FileMode) error {
+	return writeAtomic(filename, perms, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// writeAtomic streams the output of write to a temporary file in the same
+// directory before renaming it over the file. The file is left untouched
+// if write fails.
+func writeAtomic(filename string, perms os.FileMode, write func(w io.Writer) error) error {
+
+	tmp, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+".tmp*")
+
+	if err != nil {
+		return err
+	}
+
+	// Removing the temporary file fails once it has been renamed.
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(perms); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), filename)
+}
+
+// kvFolder normalizes the prefix into a folder ending with "/".
+// An empty prefix is the top level.
+func kvFolder(prefix string) string {
+
+	if prefix == "" {
+		return ""
+	}
+
+	return strings.TrimSuffix(prefix, "/") + "/"
+}
+
+// kvPutOptions converts the -cas flag into put options. A negative
+// cas leaves check-and-set unset.
+func kvPutOptions(cas int64) *internal.KVPutOptions {
+
+	opts := &internal.KVPutOptions{}
+
+	if cas >= 0 {
+		casVersion := uint64(cas)
+		opts.CAS = &casVersion
+	}
+
+	return opts
+}
+
+// isCASError reports if the write was refused by check-and-set.
+func isCASError(err error) bool {
+	return errors.Is(err, internal.ErrKVCASMismatch) || errors.Is(err, internal.ErrKVCASRequired)
+}
+
+// mapFlag collects repeated key=value flags into a map.
+type mapFlag map[string]string
+
+func (m mapFlag) String() string {
+
+	pairs := make([]string, 0, len(m))
+
+	for key, value := range m {
+		pairs = append(pairs, key+"="+value)
+	}
+
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, ",")
+}
+
+func (m mapFlag) Set(raw string) error {
+
+	parts := strings.SplitN(raw, "=", 2)
+
+	if len(parts) != 2 || parts[0] == "" {
+		return fmt.Errorf("expected key=value, but got '%s'", raw)
+	}
+
+	m[parts[0]] = parts[1]
+
+	return nil
+}
+
+// customMetadataOutput ensures custom metadata and labels are always
+// reported as an object, rather than null, in json and yaml output.
+func customMetadataOutput(customMetadata map[string]string) map[string]string {
+
+	if customMetadata == nil {
+		return map[string]string{}
+	}
+
+	return customMetadata
+}
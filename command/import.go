@@ -0,0 +1,545 @@
+package command
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/cli"
+	"github.com/woodrufj4/keyring-practice/internal"
+)
+
+const (
+	ConflictFail      = "fail"
+	ConflictSkip      = "skip"
+	ConflictOverwrite = "overwrite"
+)
+
+type ImportCommand struct {
+	ui cli.Ui
+}
+
+func (ic ImportCommand) Synopsis() string {
+	return "Imports paths from an encrypted archive or plaintext files"
+}
+
+func (ic ImportCommand) Help() string {
+	helpText := `
+Usage: keying import [options] <file or directory>
+
+  Imports every path of an archive created by keyring export, or the
+  key-value pairs of plaintext files. Everything is read and parsed
+  before anything is written.
+
+  Archives encrypted with a passphrase read the passphrase from the
+  '%s' environment variable, or prompt for it.
+  Archives encrypted for a recipient key require the -identity of the
+  recipient.
+
+  Plaintext files are imported with -format and written to -path. A
+  directory is imported as a tree, where each file is written to -path
+  joined with the file path relative to the directory, without its
+  extension. Only files with the extension of the format are imported:
+  .env, .json, .yaml or .yml. Nested objects are kept as the value of
+  their top level key.
+
+  Example:
+
+    $ keyring import dump.enc
+
+    $ keyring import -conflict=skip -identity=identity.key dump.enc
+
+    $ keyring import -generate-identity=identity.key
+
+    $ keyring import -format=dotenv -path=secret/app .env
+
+    $ keyring import -dry-run -format=yaml -path=secret/ config/
+
+  Options:
+
+    -format=<string>
+      The format of the input: archive, dotenv, json, yaml or
+      vault-kv, the json output of vault kv get. Defaults to archive.
+
+    -path=<string>
+      The path, or the prefix of a directory tree, to write plaintext
+      files to. Required for plaintext formats.
+
+    -dry-run
+      Report the paths and keys that would be written, without
+      writing anything.
+
+    -conflict=<string>
+      How to handle paths that already exist: fail, skip or
+      overwrite. Overwriting writes a new version at the path.
+      Defaults to fail, in which case nothing is written if any
+      path already exists.
+
+    -identity=<string>
+      The file holding the identity to decrypt archives encrypted
+      for a recipient key.
+
+    -generate-identity=<string>
+      Generate a new identity into the file and print its recipient
+      key, which keyring export -recipient encrypts archives for.
+
+    -root-token=<string>
+      The root token to access the keyring.
+      If not provided here, the '%s' environment
+      variable will be used.
+
+  Backend Options:
+
+    -backend-type=<string>
+      The type of backend to use.
+      Currently, only the 'file' type backend is supported,
+      and is also the default.
+
+    File Backend Options:
+
+      -filepath=<string>
+        The file path where your secrets will be persisted to disc.
+`
+	return fmt.Sprintf(helpText, EnvArchivePassphrase, internal.DefaultEnvRootToken)
+}
+
+func (ic *ImportCommand) Run(args []string) int {
+
+	defaultCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+
+	defer cancel()
+
+	var format, path, conflict, identityFile, generateIdentity string
+	var dryRun bool
+
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	fs.StringVar(&format, "format", internal.ImportFormatArchive, "The format of the input")
+	fs.StringVar(&path, "path", "", "The path to write plaintext files to")
+	fs.BoolVar(&dryRun, "dry-run", false, "Report what would be written")
+	fs.StringVar(&conflict, "conflict", ConflictFail, "How to handle paths that already exist")
+	fs.StringVar(&identityFile, "identity", "", "The file holding the identity to decrypt the archive")
+	fs.StringVar(&generateIdentity, "generate-identity", "", "Generate a new identity into the file")
+
+	config, err := internal.ReadFlagSetConfig(fs, args)
+
+	if err != nil {
+		ic.ui.Error(fmt.Sprintf("not able to read config: %s", err.Error()))
+		return 1
+	}
+
+	if generateIdentity != "" {
+		return ic.generateIdentity(generateIdentity)
+	}
+
+	switch conflict {
+	case ConflictFail, ConflictSkip, ConflictOverwrite:
+	default:
+		ic.ui.Error(fmt.Sprintf("unsupported conflict strategy '%s'", conflict))
+		return 1
+	}
+
+	filename := fs.Arg(0)
+
+	if filename == "" {
+		ic.ui.Error("missing file to import")
+		return 1
+	}
+
+	if format != internal.ImportFormatArchive {
+
+		if _, ok := internal.ImportFormatExtensions[format]; !ok {
+			ic.ui.Error(fmt.Sprintf("unsupported import format '%s'", format))
+			return 1
+		}
+
+		if path == "" {
+			ic.ui.Error("missing path to import to")
+			return 1
+		}
+
+	} else if path != "" {
+		ic.ui.Error("-path is only supported for plaintext formats")
+		return 1
+	}
+
+	keys := &internal.ArchiveKeys{}
+
+	if identityFile != "" {
+
+		rawIdentity, err := os.ReadFile(identityFile)
+
+		if err != nil {
+			ic.ui.Error(fmt.Sprintf("failed to read identity: %s", err.Error()))
+			return 1
+		}
+
+		keys.Identity, err = decodeArchiveKey(string(rawIdentity))
+
+		if err != nil {
+			ic.ui.Error(fmt.Sprintf("not able to decode identity: %s", err.Error()))
+			return 1
+		}
+	}
+
+	var records []*internal.ArchiveRecord
+
+	if format == internal.ImportFormatArchive {
+		records, err = ic.readArchive(filename, keys)
+	} else {
+		records, err = readImportFiles(format, filename, path)
+	}
+
+	if err != nil {
+		ic.ui.Error(fmt.Sprintf("failed to read %s: %s", filename, err.Error()))
+		return 1
+	}
+
+	for _, record := range records {
+		if record.Path == "" || strings.HasPrefix(record.Path, internal.KeyringPrefix) {
+			ic.ui.Error(fmt.Sprintf("refusing to import the invalid path '%s'", record.Path))
+			return 1
+		}
+	}
+
+	barrier, cleanup, ok := unlockBarrier(defaultCtx, ic.ui, config)
+
+	if !ok {
+		return 1
+	}
+
+	defer cleanup()
+
+	kvStore, err := internal.NewKV(barrier)
+
+	if err != nil {
+		ic.ui.Error(fmt.Sprintf("failed to instantiate kv store: %s", err.Error()))
+		return 1
+	}
+
+	return ic.importRecords(defaultCtx, kvStore, records, conflict, dryRun)
+}
+
+// readArchive decrypts every record of the archive. A passphrase is only
+// requested once the archive is known to be encrypted with one.
+func (ic *ImportCommand) readArchive(filename string, keys *internal.ArchiveKeys) ([]*internal.ArchiveRecord, error) {
+
+	file, err := os.Open(filename)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	archive, err := internal.OpenArchive(file, keys)
+
+	if errors.Is(err, internal.ErrArchivePassphraseRequired) {
+
+		keys.Passphrase, err = archivePassphrase(ic.ui, false)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+
+		archive, err = internal.OpenArchive(file, keys)
+	}
+
+	if errors.Is(err, internal.ErrArchiveIdentityRequired) {
+		return nil, fmt.Errorf("%s. Provide it with -identity", err.Error())
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]*internal.ArchiveRecord, 0)
+
+	for {
+
+		record, err := archive.Next()
+
+		if err == io.EOF {
+			return records, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		records = append(records, record)
+	}
+}
+
+// readImportFiles parses a plaintext file, or every file of the format within
+// a directory tree, into records.
+func readImportFiles(format string, filename string, path string) ([]*internal.ArchiveRecord, error) {
+
+	info, err := os.Stat(filename)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+
+		record, err := readImportFile(format, filename, path)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return []*internal.ArchiveRecord{record}, nil
+	}
+
+	folder := kvFolder(path)
+
+	records := make([]*internal.ArchiveRecord, 0)
+
+	err = filepath.WalkDir(filename, func(file string, entry fs.DirEntry, err error) error {
+
+		if err != nil || entry.IsDir() {
+			return err
+		}
+
+		ext := filepath.Ext(file)
+
+		supported := false
+
+		for _, formatExt := range internal.ImportFormatExtensions[format] {
+			supported = supported || ext == formatExt
+		}
+
+		if !supported {
+			return nil
+		}
+
+		rel, err := filepath.Rel(filename, file)
+
+		if err != nil {
+			return err
+		}
+
+		// A file named only by its extension, such as .env, is written
+		// to the path of its directory.
+		filePath := strings.TrimSuffix(folder+filepath.ToSlash(strings.TrimSuffix(rel, ext)), "/")
+
+		record, err := readImportFile(format, file, filePath)
+
+		if err != nil {
+			return err
+		}
+
+		records = append(records, record)
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(records))
+
+	for _, record := range records {
+
+		if seen[record.Path] {
+			return nil, fmt.Errorf("multiple files map onto the path '%s'", record.Path)
+		}
+
+		seen[record.Path] = true
+	}
+
+	return records, nil
+}
+
+// readImportFile parses a plaintext file into a record for the path. Values
+// are json encoded, like the values written by keyring put.
+func readImportFile(format string, filename string, path string) (*internal.ArchiveRecord, error) {
+
+	data, err := os.ReadFile(filename)
+
+	if err != nil {
+		return nil, err
+	}
+
+	kvPairs, err := internal.ParseImportData(format, data)
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err.Error())
+	}
+
+	record := &internal.ArchiveRecord{
+		Path: path,
+		Data: make(map[string][]byte, len(kvPairs)),
+	}
+
+	for key, value := range kvPairs {
+
+		valueBytes, err := internal.EncodeValue(value)
+
+		if err != nil {
+			return nil, fmt.Errorf("%s: failed to convert key '%s' into bytes: %s", filename, key, err.Error())
+		}
+
+		record.Data[key] = valueBytes
+	}
+
+	return record, nil
+}
+
+// importRecords writes the records, handling paths that already exist
+// with the conflict strategy. With dryRun, the records are only reported.
+func (ic *ImportCommand) importRecords(ctx context.Context, kvStore *internal.KV, records []*internal.ArchiveRecord, conflict string, dryRun bool) int {
+
+	current := make(map[string]uint64, len(records))
+	conflicts := make([]string, 0)
+
+	for _, record := range records {
+
+		metadata, err := kvStore.Metadata(ctx, record.Path)
+
+		if err != nil {
+			ic.ui.Error(fmt.Sprintf("failed to retrieve metadata at path '%s': %s", record.Path, err.Error()))
+			return 1
+		}
+
+		if metadata != nil {
+			current[record.Path] = metadata.CurrentVersion
+			conflicts = append(conflicts, record.Path)
+		}
+	}
+
+	if dryRun {
+		return ic.reportRecords(records, current, conflict)
+	}
+
+	if conflict == ConflictFail && len(conflicts) > 0 {
+
+		ic.ui.Error("The following paths already exist. Nothing was imported:")
+
+		for _, path := range conflicts {
+			ic.ui.Error(fmt.Sprintf("  %s", path))
+		}
+
+		ic.ui.Error("Retry with -conflict=skip or -conflict=overwrite.")
+		return 1
+	}
+
+	imported, skipped := 0, 0
+
+	for _, record := range records {
+
+		version, exists := current[record.Path]
+
+		if exists && conflict == ConflictSkip {
+			ic.ui.Warn(fmt.Sprintf("Skipping existing path: %s", record.Path))
+			skipped++
+			continue
+		}
+
+		entries := archiveEntries(record)
+
+		// The check-and-set version guards against writes
+		// made since the conflicts were checked.
+		_, err := kvStore.Put(ctx, record.Path, entries, &internal.KVPutOptions{CAS: &version})
+
+		if reportSchemaError(ic.ui, err) {
+			ic.ui.Error(fmt.Sprintf("Imported %d paths before failing", imported))
+			return 1
+		}
+
+		if err != nil {
+			ic.ui.Error(fmt.Sprintf("failed to import path '%s': %s", record.Path, err.Error()))
+			ic.ui.Error(fmt.Sprintf("Imported %d paths before failing", imported))
+			return 1
+		}
+
+		imported++
+	}
+
+	ic.ui.Info(fmt.Sprintf("Success! Imported %d paths, skipped %d", imported, skipped))
+
+	return 0
+}
+
+// reportRecords reports what importing the records would write.
+func (ic *ImportCommand) reportRecords(records []*internal.ArchiveRecord, current map[string]uint64, conflict string) int {
+
+	writes, skips, failures := 0, 0, 0
+
+	for _, record := range records {
+
+		action := "create"
+
+		if _, exists := current[record.Path]; exists {
+
+			switch conflict {
+			case ConflictSkip:
+				action = "skip"
+				skips++
+			case ConflictOverwrite:
+				action = "overwrite"
+			default:
+				action = "conflict"
+				failures++
+			}
+		}
+
+		if action != "skip" && action != "conflict" {
+			writes++
+		}
+
+		keys := make([]string, 0, len(record.Data))
+
+		for _, entry := range archiveEntries(record) {
+			keys = append(keys, entry.Key)
+		}
+
+		ic.ui.Output(fmt.Sprintf("%s\t%s\t%s", action, record.Path, strings.Join(keys, ",")))
+	}
+
+	if failures > 0 {
+		ic.ui.Warn(fmt.Sprintf("Dry run: %d paths already exist, so nothing would be imported without -conflict=skip or -conflict=overwrite", failures))
+		return 1
+	}
+
+	ic.ui.Info(fmt.Sprintf("Dry run: %d paths would be written, %d skipped. Nothing was written.", writes, skips))
+
+	return 0
+}
+
+// generateIdentity writes a new identity to the file and prints
+// its recipient key.
+func (ic *ImportCommand) generateIdentity(filename string) int {
+
+	identity, recipient, err := internal.GenerateArchiveIdentity()
+
+	if err != nil {
+		ic.ui.Error(fmt.Sprintf("failed to generate identity: %s", err.Error()))
+		return 1
+	}
+
+	encoded := base64.StdEncoding.EncodeToString(identity) + "\n"
+
+	if err := writeFileAtomic(filename, []byte(encoded), 0600); err != nil {
+		ic.ui.Error(fmt.Sprintf("failed to write identity: %s", err.Error()))
+		return 1
+	}
+
+	ic.ui.Info(fmt.Sprintf("Identity written to %s. Keep it private.", filename))
+	ic.ui.Output(fmt.Sprintf("recipient: %s", base64.StdEncoding.EncodeToString(recipient)))
+
+	return 0
+}
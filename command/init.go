@@ -3,6 +3,7 @@ package command
 import (
 	"context"
 	"encoding/base64"
+	"flag"
 	"fmt"
 	"time"
 
@@ -19,11 +20,32 @@ func (ic InitCommand) Synopsis() string {
 }
 
 func (ic InitCommand) Help() string {
-	return `
+	helpText := `
 Usage: keyring init [options]
 
   Initializes the keyring and the datastore.
 
+  Options:
+
+    -confidential-names
+      Store paths and keys as keyed hashes, so the datastore reveals no
+      names. Paths are listed from an encrypted index once unlocked.
+      This can only be set when initializing the keyring.
+
+    -padding=<string>
+      The scheme values are padded with before encryption, hiding their
+      length: none, buckets or padme. This can be changed later with
+      keyring operator configure.
+
+    -compression=<string>
+      The scheme values are compressed with before encryption: none,
+      gzip or zstd. This can be changed later with keyring operator
+      configure.
+
+    -format=<string>
+      The output format: table, json or yaml. Defaults to table,
+      or the '%s' environment variable when set.
+
   Backend Options:
 
     -backend-type=<string>
@@ -36,6 +58,7 @@ Usage: keyring init [options]
       -filepath=<string>
         The file path where your secrets will be persisted to disc.
 `
+	return fmt.Sprintf(helpText, EnvFormat)
 }
 
 // Run initializes the keyring if it hasn't already been initialized.
@@ -49,13 +72,39 @@ func (ic *InitCommand) Run(args []string) int {
 
 	defer cancel()
 
-	config, _, err := internal.ReadConfig(args)
+	var format string
+	var confidentialNames bool
+	var padding string
+	var compression string
+
+	fs := flag.NewFlagSet("init", flag.ContinueOnError)
+	fs.BoolVar(&confidentialNames, "confidential-names", false, "Store paths and keys as keyed hashes")
+	fs.StringVar(&padding, "padding", "", "The scheme values are padded with")
+	fs.StringVar(&compression, "compression", "", "The scheme values are compressed with")
+	formatFlag(fs, &format)
+
+	config, err := internal.ReadFlagSetConfig(fs, args)
 
 	if err != nil {
 		ic.ui.Error(fmt.Sprintf("failed to read config: %s", err.Error()))
 		return 1
 	}
 
+	if err := validateFormat(format); err != nil {
+		ic.ui.Error(err.Error())
+		return 1
+	}
+
+	if err := internal.ValidatePadding(padding); err != nil {
+		ic.ui.Error(err.Error())
+		return 1
+	}
+
+	if err := internal.ValidateCompression(compression); err != nil {
+		ic.ui.Error(err.Error())
+		return 1
+	}
+
 	initBackend, err := internal.SetupBackend(defaultCtx, config)
 
 	if err != nil {
@@ -85,7 +134,16 @@ func (ic *InitCommand) Run(args []string) int {
 	}
 
 	if exists {
-		ic.ui.Info("keyring already initialized")
+
+		err = outputFormatted(ic.ui, format, &initOutput{AlreadyInitialized: true}, func() {
+			ic.ui.Info("keyring already initialized")
+		})
+
+		if err != nil {
+			ic.ui.Error(err.Error())
+			return 1
+		}
+
 		return 0
 	}
 
@@ -96,21 +154,43 @@ func (ic *InitCommand) Run(args []string) int {
 		return 1
 	}
 
-	err = barrier.Initialize(defaultCtx, string(initialKey))
+	// The keyring is persisted along with its configuration, so a failure
+	// never leaves a keyring whose root token was not displayed.
+	err = barrier.Initialize(defaultCtx, string(initialKey), &internal.BarrierConfig{
+		ConfidentialNames: confidentialNames,
+		Padding:           padding,
+		Compression:       compression,
+	})
 
 	if err != nil {
 		ic.ui.Error(fmt.Sprintf("failed to initialize barrier: %s", err.Error()))
 		return 1
 	}
 
+	rootToken := base64.StdEncoding.EncodeToString(initialKey)
+
 	// display root token to user
-	msg := `Keyring initialized!
+	err = outputFormatted(ic.ui, format, &initOutput{RootToken: rootToken}, func() {
+
+		msg := `Keyring initialized!
 This is the one and only time the root token will be displayed!
 
 root token: %s
 `
 
-	ic.ui.Warn(fmt.Sprintf(msg, base64.StdEncoding.EncodeToString(initialKey)))
+		ic.ui.Warn(fmt.Sprintf(msg, rootToken))
+	})
+
+	if err != nil {
+		ic.ui.Error(err.Error())
+		return 1
+	}
 
 	return 0
 }
+
+// initOutput is the json and yaml schema of keyring init.
+type initOutput struct {
+	RootToken          string `json:"root_token"`
+	AlreadyInitialized bool   `json:"already_initialized"`
+}
@@ -0,0 +1,199 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/cli"
+	"github.com/woodrufj4/keyring-practice/internal"
+)
+
+type KVCopyCommand struct {
+	ui cli.Ui
+}
+
+func (kv KVCopyCommand) Synopsis() string {
+	return "Copies the key-value pairs at a path to another path"
+}
+
+func (kv KVCopyCommand) Help() string {
+	helpText := `
+Usage: keying copy [options] <source> <destination>
+
+  Copies every version and the metadata at the source path to the
+  destination path. The copy is performed in a single transaction, and
+  the copied entries are re-encrypted under the active key term.
+
+  With -recursive, every path within the source prefix is copied to the
+  same relative path within the destination prefix.
+
+  Example:
+
+    $ keyring copy secret/app secret/app-backup
+
+    $ keyring copy -recursive secret/staging/ secret/prod/
+
+  Options:
+
+    -recursive
+      Copy every path within the source prefix.
+
+    -force
+      Replace destination paths that already exist, including
+      their version history. Destination paths configured with
+      -cas-required are never replaced.
+
+    -root-token=<string>
+      The root token to access the keyring.
+      If not provided here, the '%s' environment
+      variable will be used.
+
+  Backend Options:
+
+    -backend-type=<string>
+      The type of backend to use.
+      Currently, only the 'file' type backend is supported,
+      and is also the default.
+
+    File Backend Options:
+
+      -filepath=<string>
+        The file path where your secrets will be persisted to disc.
+`
+	return fmt.Sprintf(helpText, internal.DefaultEnvRootToken)
+}
+
+func (kv *KVCopyCommand) Run(args []string) int {
+	return runRelocation(kv.ui, "copy", args)
+}
+
+// runRelocation runs keyring copy, or keyring move, which only differ
+// in whether the sources are removed.
+func runRelocation(ui cli.Ui, name string, args []string) int {
+
+	defaultCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+
+	defer cancel()
+
+	var recursive, force bool
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.BoolVar(&recursive, "recursive", false, "Relocate every path within the source prefix")
+	fs.BoolVar(&force, "force", false, "Replace destination paths that already exist")
+
+	config, err := internal.ReadFlagSetConfig(fs, args)
+
+	if err != nil {
+		ui.Error(fmt.Sprintf("not able to read config: %s", err.Error()))
+		return 1
+	}
+
+	if len(fs.Args()) != 2 {
+		ui.Error("expected a source and a destination")
+		return 1
+	}
+
+	source, destination := fs.Arg(0), fs.Arg(1)
+
+	if source == "" || destination == "" {
+		ui.Error("missing path")
+		return 1
+	}
+
+	if strings.HasPrefix(source, internal.KeyringPrefix) || strings.HasPrefix(destination, internal.KeyringPrefix) {
+		ui.Warn(fmt.Sprintf("paths prefixed with %s are restricted", internal.KeyringPrefix))
+		return 1
+	}
+
+	barrier, cleanup, ok := unlockBarrier(defaultCtx, ui, config)
+
+	if !ok {
+		return 1
+	}
+
+	defer cleanup()
+
+	kvStore, err := internal.NewKV(barrier)
+
+	if err != nil {
+		ui.Error(fmt.Sprintf("failed to instantiate kv store: %s", err.Error()))
+		return 1
+	}
+
+	relocations := []*internal.KVRelocation{
+		{
+			Source:      source,
+			Destination: destination,
+		},
+	}
+
+	if recursive {
+
+		sourceFolder, destinationFolder := kvFolder(source), kvFolder(destination)
+
+		children, err := kvStore.List(defaultCtx, sourceFolder, true)
+
+		if err != nil {
+			ui.Error(fmt.Sprintf("failed to list secrets within %s: %s", sourceFolder, err.Error()))
+			return 1
+		}
+
+		if len(children) == 0 {
+			ui.Warn(fmt.Sprintf("No paths found within %s", sourceFolder))
+			return 1
+		}
+
+		relocations = make([]*internal.KVRelocation, 0, len(children))
+
+		for _, child := range children {
+			relocations = append(relocations, &internal.KVRelocation{
+				Source:      sourceFolder + child,
+				Destination: destinationFolder + child,
+			})
+		}
+	}
+
+	if name == "move" {
+		err = kvStore.Move(defaultCtx, relocations, force)
+	} else {
+		err = kvStore.Copy(defaultCtx, relocations, force)
+	}
+
+	if errors.Is(err, internal.ErrKVDestinationExists) {
+		ui.Error(fmt.Sprintf("refusing to %s onto an existing path: %s", name, err.Error()))
+		ui.Error("Nothing was changed. Retry with -force to replace existing paths.")
+		return 1
+	}
+
+	if isCASError(err) {
+		ui.Error(fmt.Sprintf("refusing to %s onto a path which requires check-and-set: %s", name, err.Error()))
+		ui.Error("Nothing was changed. Remove -cas-required from the path metadata to replace it.")
+		return 1
+	}
+
+	if reportSchemaError(ui, err) {
+		ui.Error("Nothing was changed.")
+		return 1
+	}
+
+	if err != nil {
+		ui.Error(fmt.Sprintf("failed to %s %s: %s", name, source, err.Error()))
+		return 1
+	}
+
+	for _, relocation := range relocations {
+		ui.Output(fmt.Sprintf("%s -> %s", relocation.Source, relocation.Destination))
+	}
+
+	if name == "move" {
+		ui.Info(fmt.Sprintf("Success! Moved %d paths", len(relocations)))
+	} else {
+		ui.Info(fmt.Sprintf("Success! Copied %d paths", len(relocations)))
+	}
+
+	return 0
+}
@@ -2,8 +2,9 @@ package command
 
 import (
 	"context"
-	"encoding/base64"
+	"flag"
 	"fmt"
+	"reflect"
 	"strings"
 	"time"
 
@@ -16,21 +17,52 @@ type KVDeleteCommand struct {
 }
 
 func (kv KVDeleteCommand) Synopsis() string {
-	return "Deletes the key-value pairs from the data store at the provided path"
+	return "Deletes versions of the key-value pairs at the provided path"
 }
 
 func (kv KVDeleteCommand) Help() string {
 	helpText := `
 Usage: keying delete [options] <path>
 
-  Deletes all the key-value pairs given the provided path.
+  Soft deletes versions of the key-value pairs at the provided path.
+  Deleted versions can be restored with keyring undelete. To permanently
+  remove data, use keyring destroy.
+
+  With -recursive, the provided path and every path within it are
+  permanently removed, along with all of their versions and metadata.
+  A path ending in "/" only removes the paths within it. The paths are
+  listed and must be confirmed before anything is removed. The paths are
+  removed in a single transaction, so either all of them are removed,
+  or none of them are.
 
   Example:
 
     $ keyring delete secret/foo
 
+    $ keyring delete -recursive -dry-run secret/app
+
+    $ keyring delete -recursive -selector=env=staging secret/
+
   Options:
 
+    -versions=<int,...>
+      The versions to delete. By default, the current version
+      is deleted.
+
+    -recursive
+      Permanently remove the path and every path within it.
+
+    -selector=<string>
+      Only remove the paths within the prefix whose labels match the
+      label selector, such as 'env=staging,team!=infra'. Without a
+      prefix, every path is considered. This requires -recursive.
+
+    -dry-run
+      Only list the paths -recursive would remove.
+
+    -force
+      Skip the confirmation prompt of -recursive.
+
     -root-token=<string>
       The root token to use for encryption.
       If not provided here, the '%s' environment
@@ -57,28 +89,50 @@ func (kv *KVDeleteCommand) Run(args []string) int {
 
 	defer cancel()
 
-	config, fs, err := internal.ReadConfig(args)
+	var rawVersions, rawSelector string
+	var recursive, dryRun, force bool
+
+	fs := flag.NewFlagSet("delete", flag.ContinueOnError)
+	fs.StringVar(&rawVersions, "versions", "", "The versions to delete")
+	fs.BoolVar(&recursive, "recursive", false, "Permanently remove every path within the prefix")
+	fs.StringVar(&rawSelector, "selector", "", "Only remove the paths whose labels match the selector")
+	fs.BoolVar(&dryRun, "dry-run", false, "Only list the paths -recursive would remove")
+	fs.BoolVar(&force, "force", false, "Skip the confirmation prompt of -recursive")
+
+	config, err := internal.ReadFlagSetConfig(fs, args)
 
 	if err != nil {
 		kv.ui.Error(fmt.Sprintf("not able to read config: %s", err.Error()))
 		return 1
 	}
 
-	if config.RootToken == "" {
-		kv.ui.Error("missing root token")
+	versions, err := parseVersions(rawVersions)
+
+	if err != nil {
+		kv.ui.Error(fmt.Sprintf("not able to parse versions: %s", err.Error()))
 		return 1
 	}
 
-	rootTokenBytes, err := base64.StdEncoding.DecodeString(config.RootToken)
+	if recursive && rawVersions != "" {
+		kv.ui.Error("-versions can not be combined with -recursive")
+		return 1
+	}
+
+	if (dryRun || force || rawSelector != "") && !recursive {
+		kv.ui.Error("-dry-run, -force and -selector require -recursive")
+		return 1
+	}
+
+	selector, err := internal.ParseLabelSelector(rawSelector)
 
 	if err != nil {
-		kv.ui.Error(fmt.Sprintf("not able to decode root token: %s", err.Error()))
+		kv.ui.Error(fmt.Sprintf("not able to parse selector: %s", err.Error()))
 		return 1
 	}
 
 	path := fs.Arg(0)
 
-	if path == "" {
+	if path == "" && rawSelector == "" {
 		kv.ui.Error("missing path")
 		return 1
 	}
@@ -88,54 +142,199 @@ func (kv *KVDeleteCommand) Run(args []string) int {
 		return 1
 	}
 
-	kvbackend, err := internal.SetupBackend(defaultCtx, config)
+	if recursive {
+		return kv.deleteRecursive(config, path, selector, dryRun, force)
+	}
 
-	if err != nil {
-		kv.ui.Error(fmt.Sprintf("failed to setup backend: %s", err.Error()))
+	barrier, cleanup, ok := unlockBarrier(defaultCtx, kv.ui, config)
+
+	if !ok {
 		return 1
 	}
 
-	defer func() {
-		cleanupErr := kvbackend.Cleanup(defaultCtx)
-		if cleanupErr != nil {
-			kv.ui.Warn(fmt.Sprintf("failed to gracefully shutdown backend: %s", cleanupErr.Error()))
-		}
-	}()
+	defer cleanup()
 
-	barrier, err := internal.NewBarrier(kvbackend)
+	kvStore, err := internal.NewKV(barrier)
 
 	if err != nil {
-		kv.ui.Error(fmt.Sprintf("failed to instantiate barrier: %s", err.Error()))
+		kv.ui.Error(fmt.Sprintf("failed to instantiate kv store: %s", err.Error()))
 		return 1
 	}
 
-	initialized, err := barrier.KeyringPersisted(defaultCtx)
+	err = kvStore.Delete(defaultCtx, path, versions)
 
 	if err != nil {
-		kv.ui.Error(fmt.Sprintf("failed to validate existing barrier: %s", err.Error()))
+		kv.ui.Error(fmt.Sprintf("failed to delete secrets at path %s: %s", path, err.Error()))
 		return 1
 	}
 
-	if !initialized {
-		kv.ui.Warn("keyring not initialized. Run keyring init.")
+	kv.ui.Info(fmt.Sprintf("Success! Data deleted (if it existed) at: %s", path))
+
+	return 0
+}
+
+// deleteRecursive permanently removes the path and every path within it
+// which matches the selector, after listing the paths and asking for
+// confirmation.
+//
+// The backend is not held open while waiting for confirmation, so other
+// commands are not blocked. The paths are listed again once confirmed, and
+// nothing is removed if they have changed since.
+func (kv *KVDeleteCommand) deleteRecursive(config *internal.GeneralConfig, prefix string, selector *internal.LabelSelector, dryRun bool, force bool) int {
+
+	paths, ok := kv.listRecursive(config, prefix, selector)
+
+	if !ok {
 		return 1
 	}
 
-	err = barrier.Initialize(defaultCtx, string(rootTokenBytes))
+	if len(paths) == 0 {
+		kv.ui.Warn(fmt.Sprintf("No paths found at %s", prefix))
+		return 0
+	}
 
-	if err != nil {
-		kv.ui.Error(fmt.Sprintf("failed to initialize existing barrier: %s", err.Error()))
-		return 1
+	kv.ui.Output(fmt.Sprintf("The following %d paths will be permanently removed:\n", len(paths)))
+
+	for _, path := range paths {
+		kv.ui.Output(fmt.Sprintf("  %s", path))
 	}
 
-	err = barrier.Delete(defaultCtx, path)
+	kv.ui.Output("")
 
-	if err != nil {
-		kv.ui.Error(fmt.Sprintf("failed to delete secrets at path %s: %s", path, err.Error()))
+	if dryRun {
+		return 0
+	}
+
+	if !force {
+
+		answer, err := kv.ui.Ask("Type 'yes' to confirm:")
+
+		if err != nil {
+			kv.ui.Error(fmt.Sprintf("failed to read confirmation: %s", err.Error()))
+			return 1
+		}
+
+		if strings.TrimSpace(answer) != "yes" {
+			kv.ui.Warn("Aborted. Nothing was removed.")
+			return 1
+		}
+	}
+
+	if !kv.removeRecursive(config, prefix, selector, paths) {
 		return 1
 	}
 
-	kv.ui.Info(fmt.Sprintf("Success! Data deleted (if it existed) at: %s", path))
+	kv.ui.Info(fmt.Sprintf("Success! Removed %d paths at: %s", len(paths), prefix))
 
 	return 0
 }
+
+// listRecursive lists the paths deleteRecursive would remove. Any failure
+// is reported to the ui, in which case ok is false.
+func (kv *KVDeleteCommand) listRecursive(config *internal.GeneralConfig, prefix string, selector *internal.LabelSelector) ([]string, bool) {
+
+	defaultCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+
+	defer cancel()
+
+	barrier, cleanup, ok := unlockBarrier(defaultCtx, kv.ui, config)
+
+	if !ok {
+		return nil, false
+	}
+
+	defer cleanup()
+
+	kvStore, err := internal.NewKV(barrier)
+
+	if err != nil {
+		kv.ui.Error(fmt.Sprintf("failed to instantiate kv store: %s", err.Error()))
+		return nil, false
+	}
+
+	paths, err := recursivePaths(defaultCtx, kvStore, prefix, selector)
+
+	if err != nil {
+		kv.ui.Error(fmt.Sprintf("failed to list secrets at %s: %s", prefix, err.Error()))
+		return nil, false
+	}
+
+	return paths, true
+}
+
+// removeRecursive removes the confirmed paths, as long as the paths
+// deleteRecursive would remove have not changed since they were listed.
+func (kv *KVDeleteCommand) removeRecursive(config *internal.GeneralConfig, prefix string, selector *internal.LabelSelector, confirmed []string) bool {
+
+	defaultCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+
+	defer cancel()
+
+	barrier, cleanup, ok := unlockBarrier(defaultCtx, kv.ui, config)
+
+	if !ok {
+		return false
+	}
+
+	defer cleanup()
+
+	kvStore, err := internal.NewKV(barrier)
+
+	if err != nil {
+		kv.ui.Error(fmt.Sprintf("failed to instantiate kv store: %s", err.Error()))
+		return false
+	}
+
+	paths, err := recursivePaths(defaultCtx, kvStore, prefix, selector)
+
+	if err != nil {
+		kv.ui.Error(fmt.Sprintf("failed to list secrets at %s: %s", prefix, err.Error()))
+		return false
+	}
+
+	if !reflect.DeepEqual(paths, confirmed) {
+		kv.ui.Error(fmt.Sprintf("The paths at %s changed while waiting for confirmation. Nothing was removed.", prefix))
+		return false
+	}
+
+	if err := kvStore.DestroyPaths(defaultCtx, paths); err != nil {
+		kv.ui.Error(fmt.Sprintf("failed to remove secrets at %s: %s", prefix, err.Error()))
+		return false
+	}
+
+	return true
+}
+
+// recursivePaths lists the path and every path within it which matches
+// the selector. A prefix ending in "/" only lists the paths within it.
+func recursivePaths(ctx context.Context, kvStore *internal.KV, prefix string, selector *internal.LabelSelector) ([]string, error) {
+
+	paths := make([]string, 0)
+
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+
+		metadata, err := kvStore.Metadata(ctx, prefix)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if metadata != nil && selector.Matches(metadata.Labels) {
+			paths = append(paths, prefix)
+		}
+	}
+
+	folder := kvFolder(prefix)
+
+	children, err := kvStore.Select(ctx, folder, selector)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, child := range children {
+		paths = append(paths, folder+child)
+	}
+
+	return paths, nil
+}
@@ -0,0 +1,141 @@
+package command
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/cli"
+	"github.com/woodrufj4/keyring-practice/internal"
+)
+
+type KVDestroyCommand struct {
+	ui cli.Ui
+}
+
+func (kv KVDestroyCommand) Synopsis() string {
+	return "Permanently removes versions of the key-value pairs at the provided path"
+}
+
+func (kv KVDestroyCommand) Help() string {
+	helpText := `
+Usage: keying destroy [options] <path>
+
+  Permanently removes versions of the key-value pairs at the provided
+  path. Destroyed versions cannot be restored.
+
+  Example:
+
+    $ keyring destroy -versions=1,2 secret/foo
+
+    $ keyring destroy -all secret/foo
+
+  Options:
+
+    -versions=<int,...>
+      The versions to destroy. The version metadata is retained.
+
+    -all
+      Destroy every version along with the metadata at the path.
+
+    -root-token=<string>
+      The root token to access the keyring.
+      If not provided here, the '%s' environment
+      variable will be used.
+
+  Backend Options:
+
+    -backend-type=<string>
+      The type of backend to use.
+      Currently, only the 'file' type backend is supported,
+      and is also the default.
+
+    File Backend Options:
+
+      -filepath=<string>
+        The file path where your secrets will be persisted to disc.
+`
+	return fmt.Sprintf(helpText, internal.DefaultEnvRootToken)
+}
+
+func (kv *KVDestroyCommand) Run(args []string) int {
+
+	defaultCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+
+	defer cancel()
+
+	var rawVersions string
+	var all bool
+
+	fs := flag.NewFlagSet("destroy", flag.ContinueOnError)
+	fs.StringVar(&rawVersions, "versions", "", "The versions to destroy")
+	fs.BoolVar(&all, "all", false, "Destroy every version along with the metadata")
+
+	config, err := internal.ReadFlagSetConfig(fs, args)
+
+	if err != nil {
+		kv.ui.Error(fmt.Sprintf("not able to read config: %s", err.Error()))
+		return 1
+	}
+
+	versions, err := parseVersions(rawVersions)
+
+	if err != nil {
+		kv.ui.Error(fmt.Sprintf("not able to parse versions: %s", err.Error()))
+		return 1
+	}
+
+	if len(versions) == 0 && !all {
+		kv.ui.Error("must provide either versions or -all")
+		return 1
+	}
+
+	if len(versions) > 0 && all {
+		kv.ui.Error("versions and -all are mutually exclusive")
+		return 1
+	}
+
+	path := fs.Arg(0)
+
+	if path == "" {
+		kv.ui.Error("missing path")
+		return 1
+	}
+
+	if strings.HasPrefix(path, internal.KeyringPrefix) {
+		kv.ui.Warn(fmt.Sprintf("paths prefixed with %s are restricted", internal.KeyringPrefix))
+		return 1
+	}
+
+	barrier, cleanup, ok := unlockBarrier(defaultCtx, kv.ui, config)
+
+	if !ok {
+		return 1
+	}
+
+	defer cleanup()
+
+	kvStore, err := internal.NewKV(barrier)
+
+	if err != nil {
+		kv.ui.Error(fmt.Sprintf("failed to instantiate kv store: %s", err.Error()))
+		return 1
+	}
+
+	if all {
+		err = kvStore.DestroyAll(defaultCtx, path)
+	} else {
+		err = kvStore.Destroy(defaultCtx, path, versions)
+	}
+
+	if err != nil {
+		kv.ui.Error(fmt.Sprintf("failed to destroy secrets at path %s: %s", path, err.Error()))
+		return 1
+	}
+
+	kv.ui.Info(fmt.Sprintf("Success! Data destroyed (if it existed) at: %s", path))
+
+	return 0
+}
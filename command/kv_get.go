@@ -2,11 +2,16 @@ package command
 
 import (
 	"context"
-	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/mitchellh/cli"
 	"github.com/woodrufj4/keyring-practice/internal"
@@ -26,12 +31,37 @@ Usage: keying get [options] <path>
 
   This retrieves and decrypts key-value pairs at given a path.
 
+  Values stored as raw bytes are shown in the table when they are valid
+  utf-8, and are base64 encoded in json and yaml output. Their keys are
+  listed in raw_fields, so they can be told apart from string values.
+
   Example:
 
     $ keyring get secret/foo
 
+    $ keyring get -field=cert -out=cert.pem secret/tls
+
   Options:
 
+    -version=<int>
+      The version to retrieve. By default, the current
+      version is retrieved.
+
+    -field=<string>
+      Print only the raw value of the key. String values are
+      printed without quotes, other values are printed as json.
+
+    -out=<string>
+      Write the value of -field to the file rather than printing it.
+      Values stored as raw bytes are written back byte for byte.
+
+    -perms=<octal>
+      The permissions of the file written with -out. Defaults to 0600.
+
+    -format=<string>
+      The output format: table, json or yaml. Defaults to table,
+      or the '%s' environment variable when set.
+
     -root-token=<string>
       The root token to use for encryption.
       If not provided here, the '%s' environment
@@ -49,7 +79,7 @@ Usage: keying get [options] <path>
       -filepath=<string>
         The file path where your secrets will be persisted to disc.
 `
-	return fmt.Sprintf(helpText, internal.DefaultEnvRootToken)
+	return fmt.Sprintf(helpText, EnvFormat, internal.DefaultEnvRootToken)
 }
 
 func (kv *KVGetCommand) Run(args []string) int {
@@ -58,22 +88,38 @@ func (kv *KVGetCommand) Run(args []string) int {
 
 	defer cancel()
 
-	config, fs, err := internal.ReadConfig(args)
+	var version uint64
+	var field, out, rawPerms string
+	var format string
+
+	fs := flag.NewFlagSet("get", flag.ContinueOnError)
+	fs.Uint64Var(&version, "version", 0, "The version to retrieve")
+	fs.StringVar(&field, "field", "", "Print only the raw value of the key")
+	fs.StringVar(&out, "out", "", "Write the value of -field to the file")
+	fs.StringVar(&rawPerms, "perms", "0600", "The permissions of the file written with -out")
+	formatFlag(fs, &format)
+
+	config, err := internal.ReadFlagSetConfig(fs, args)
 
 	if err != nil {
 		kv.ui.Error(fmt.Sprintf("not able to read config: %s", err.Error()))
 		return 1
 	}
 
-	if config.RootToken == "" {
-		kv.ui.Error("missing root token")
+	if err := validateFormat(format); err != nil {
+		kv.ui.Error(err.Error())
 		return 1
 	}
 
-	rootTokenBytes, err := base64.StdEncoding.DecodeString(config.RootToken)
+	if out != "" && field == "" {
+		kv.ui.Error("-out requires -field")
+		return 1
+	}
 
-	if err != nil {
-		kv.ui.Error(fmt.Sprintf("not able to decode root token: %s", err.Error()))
+	perms, err := strconv.ParseUint(rawPerms, 8, 32)
+
+	if err != nil || perms > 0777 {
+		kv.ui.Error(fmt.Sprintf("invalid perms '%s'", rawPerms))
 		return 1
 	}
 
@@ -89,73 +135,196 @@ func (kv *KVGetCommand) Run(args []string) int {
 		return 1
 	}
 
-	kvbackend, err := internal.SetupBackend(defaultCtx, config)
+	barrier, cleanup, ok := unlockBarrier(defaultCtx, kv.ui, config)
 
-	if err != nil {
-		kv.ui.Error(fmt.Sprintf("failed to setup backend: %s", err.Error()))
+	if !ok {
 		return 1
 	}
 
-	defer func() {
-		cleanupErr := kvbackend.Cleanup(defaultCtx)
-		if cleanupErr != nil {
-			kv.ui.Warn(fmt.Sprintf("failed to gracefully shutdown backend: %s", cleanupErr.Error()))
-		}
-	}()
+	defer cleanup()
 
-	barrier, err := internal.NewBarrier(kvbackend)
+	kvStore, err := internal.NewKV(barrier)
 
 	if err != nil {
-		kv.ui.Error(fmt.Sprintf("failed to instantiate barrier: %s", err.Error()))
+		kv.ui.Error(fmt.Sprintf("failed to instantiate kv store: %s", err.Error()))
 		return 1
 	}
 
-	initialized, err := barrier.KeyringPersisted(defaultCtx)
+	secret, err := kvStore.GetVersion(defaultCtx, path, version)
+
+	if errors.Is(err, internal.ErrKVExpired) {
+		kv.ui.Warn(fmt.Sprintf("Secret at %s is no longer valid: %s", path, err.Error()))
+		return 1
+	}
 
 	if err != nil {
-		kv.ui.Error(fmt.Sprintf("failed to validate existing barrier: %s", err.Error()))
+		kv.ui.Error(fmt.Sprintf("failed to retrieve secrets at path '%s': %s", path, err.Error()))
 		return 1
 	}
 
-	if !initialized {
-		kv.ui.Warn("keyring not initialized. Run keyring init.")
+	if secret == nil {
+		kv.ui.Error(fmt.Sprintf("No value found at %s", path))
 		return 1
 	}
 
-	err = barrier.Initialize(defaultCtx, string(rootTokenBytes))
+	if secret.Metadata.Destroyed {
+		kv.ui.Warn(fmt.Sprintf("Version %d at %s has been destroyed", secret.Version, path))
+		return 1
+	}
 
-	if err != nil {
-		kv.ui.Error(fmt.Sprintf("failed to initialize existing barrier: %s", err.Error()))
+	if secret.Metadata.Deleted() {
+		kv.ui.Warn(fmt.Sprintf("Version %d at %s was deleted at %s", secret.Version, path, secret.Metadata.DeletionTime.Format(time.RFC3339)))
 		return 1
 	}
 
-	entries, err := barrier.Get(defaultCtx, path)
+	data, err := decodeKVEntries(secret.Entries)
 
 	if err != nil {
-		kv.ui.Error(fmt.Sprintf("failed to retrieve secrets at path '%s': %s", path, err.Error()))
+		kv.ui.Error(err.Error())
 		return 1
 	}
 
-	if entries == nil {
-		kv.ui.Error(fmt.Sprintf("No value found at %s", path))
-		return 1
+	// Failing to record the read does not prevent the secret being read.
+	if err := kvStore.RecordRead(defaultCtx, path); err != nil {
+		kv.ui.Warn(fmt.Sprintf("failed to record last read time at path '%s': %s", path, err.Error()))
 	}
 
-	var entryValue interface{}
+	if field != "" {
+
+		value, ok := data[field]
 
-	kv.ui.Output("============ Data ============")
-	kv.ui.Output("key\t\tValue")
-	kv.ui.Output("---\t\t-----")
+		if !ok {
+			kv.ui.Error(fmt.Sprintf("field '%s' not present at %s", field, path))
+			return 1
+		}
 
-	for _, entry := range entries {
+		raw, err := rawFieldValue(value)
 
-		if err = json.Unmarshal(entry.Value, &entryValue); err != nil {
-			kv.ui.Error(fmt.Sprintf("failed to unmarshal json: %s", err.Error()))
+		if err != nil {
+			kv.ui.Error(fmt.Sprintf("failed to format field '%s': %s", field, err.Error()))
 			return 1
 		}
 
-		kv.ui.Output(fmt.Sprintf("%s\t\t%v", entry.Key, entryValue))
+		if out == "" {
+			kv.ui.Output(raw)
+			return 0
+		}
+
+		if err := writeFileAtomic(out, []byte(raw), os.FileMode(perms)); err != nil {
+			kv.ui.Error(fmt.Sprintf("failed to write field '%s': %s", field, err.Error()))
+			return 1
+		}
+
+		kv.ui.Info(fmt.Sprintf("Success! Wrote field '%s' to %s", field, out))
+		return 0
+	}
+
+	output := &kvGetOutput{
+		Path:           path,
+		Metadata:       newKVVersionOutput(secret.Version, secret.Metadata),
+		UpdatedTime:    formatTime(secret.PathMetadata.UpdatedTime),
+		UpdatedBy:      secret.PathMetadata.UpdatedBy,
+		ExpireTime:     formatTime(secret.PathMetadata.ExpireTime),
+		CustomMetadata: customMetadataOutput(secret.PathMetadata.CustomMetadata),
+		Data:           data,
+		RawFields:      rawFields(data),
+	}
+
+	err = outputFormatted(kv.ui, format, output, func() {
+
+		kv.ui.Output("========== Metadata ==========")
+		kv.ui.Output("key\t\t\tValue")
+		kv.ui.Output("---\t\t\t-----")
+		kv.ui.Output(fmt.Sprintf("version\t\t\t%d", secret.Version))
+		kv.ui.Output(fmt.Sprintf("created_time\t\t%s", formatTime(secret.Metadata.CreatedTime)))
+		kv.ui.Output(fmt.Sprintf("created_by\t\t%s", secret.Metadata.CreatedBy))
+		kv.ui.Output(fmt.Sprintf("updated_time\t\t%s", formatTime(secret.PathMetadata.UpdatedTime)))
+		kv.ui.Output(fmt.Sprintf("updated_by\t\t%s", secret.PathMetadata.UpdatedBy))
+		kv.ui.Output(fmt.Sprintf("expire_time\t\t%s", formatTime(secret.PathMetadata.ExpireTime)))
+		kv.ui.Output(fmt.Sprintf("custom_metadata\t\t%s", mapFlag(secret.PathMetadata.CustomMetadata)))
+		kv.ui.Output("")
+		kv.ui.Output("============ Data ============")
+		kv.ui.Output("key\t\tValue")
+		kv.ui.Output("---\t\t-----")
+
+		for _, entry := range secret.Entries {
+			kv.ui.Output(fmt.Sprintf("%s\t\t%s", entry.Key, displayValue(data[entry.Key])))
+		}
+	})
+
+	if err != nil {
+		kv.ui.Error(err.Error())
+		return 1
 	}
 
 	return 0
 }
+
+// kvGetOutput is the json and yaml schema of keyring get.
+type kvGetOutput struct {
+	Path           string                 `json:"path"`
+	Metadata       *kvVersionOutput       `json:"metadata"`
+	UpdatedTime    string                 `json:"updated_time"`
+	UpdatedBy      string                 `json:"updated_by"`
+	ExpireTime     string                 `json:"expire_time"`
+	CustomMetadata map[string]string      `json:"custom_metadata"`
+	Data           map[string]interface{} `json:"data"`
+
+	// RawFields are the keys of data holding raw bytes, which are base64
+	// encoded rather than holding a json value.
+	RawFields []string `json:"raw_fields"`
+}
+
+// rawFields reports the keys holding raw bytes, in order.
+func rawFields(data map[string]interface{}) []string {
+
+	fields := make([]string, 0)
+
+	for key, value := range data {
+		if _, ok := value.([]byte); ok {
+			fields = append(fields, key)
+		}
+	}
+
+	sort.Strings(fields)
+
+	return fields
+}
+
+// displayValue formats a value for the table output. Raw values which are
+// not valid utf-8 are summarized rather than printed.
+func displayValue(value interface{}) string {
+
+	raw, ok := value.([]byte)
+
+	if !ok {
+		return fmt.Sprintf("%v", value)
+	}
+
+	if !utf8.Valid(raw) {
+		return fmt.Sprintf("<%d bytes of binary data>", len(raw))
+	}
+
+	return string(raw)
+}
+
+// rawFieldValue formats a single value for scripts. Strings and raw values
+// are printed as is, while any other value is printed as json.
+func rawFieldValue(value interface{}) (string, error) {
+
+	if str, ok := value.(string); ok {
+		return str, nil
+	}
+
+	if raw, ok := value.([]byte); ok {
+		return string(raw), nil
+	}
+
+	out, err := json.Marshal(value)
+
+	if err != nil {
+		return "", err
+	}
+
+	return string(out), nil
+}
@@ -2,7 +2,7 @@ package command
 
 import (
 	"context"
-	"encoding/base64"
+	"flag"
 	"fmt"
 	"strings"
 	"time"
@@ -16,21 +16,48 @@ type KVListCommand struct {
 }
 
 func (kv KVListCommand) Synopsis() string {
-	return "Lists the stored paths given a path prefix"
+	return "Lists the stored paths within a folder"
 }
 
 func (kv KVListCommand) Help() string {
 	helpText := `
-Usage: keying list [options] <path-prefix>
+Usage: keying list [options] [folder]
 
-  Lists the stored paths given a path prefix
+  Lists the paths and sub-folders within a folder. Sub-folders are
+  suffixed with "/". Without a folder, the top level is listed.
 
   Example:
 
-    $ keyring list secret/path-prefix
+    $ keyring list secret/
+
+    $ keyring list -recursive secret/app
+
+    $ keyring list -expiring-within=7d secret/
+
+    $ keyring list -selector='env=prod,team!=infra' secret/
 
   Options:
 
+    -recursive
+      List every path within the folder, rather than only the
+      immediate children.
+
+    -expiring-within=<duration>
+      Only list the paths within the folder that expire within the
+      duration, such as 72h or 7d, along with their expiration.
+      Paths that have already expired are included. This implies
+      -recursive.
+
+    -selector=<string>
+      Only list the paths within the folder whose labels match the
+      label selector, such as 'env=prod,team!=infra', 'env in (dev,qa)',
+      'rotation' or '!rotation'. Labels are attached with keyring
+      metadata put -label. This implies -recursive.
+
+    -format=<string>
+      The output format: table, json or yaml. Defaults to table,
+      or the '%s' environment variable when set.
+
     -root-token=<string>
       The root token to access the keyring.
       If not provided here, the '%s' environment
@@ -48,7 +75,7 @@ Usage: keying list [options] <path-prefix>
       -filepath=<string>
         The file path where your secrets will be persisted to disc.
 `
-	return fmt.Sprintf(helpText, internal.DefaultEnvRootToken)
+	return fmt.Sprintf(helpText, EnvFormat, internal.DefaultEnvRootToken)
 }
 
 func (kv *KVListCommand) Run(args []string) int {
@@ -57,87 +84,165 @@ func (kv *KVListCommand) Run(args []string) int {
 
 	defer cancel()
 
-	config, fs, err := internal.ReadConfig(args)
+	var recursive bool
+	var rawExpiringWithin string
+	var rawSelector string
+	var format string
+
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	fs.BoolVar(&recursive, "recursive", false, "List every path within the folder")
+	fs.StringVar(&rawExpiringWithin, "expiring-within", "", "Only list the paths that expire within the duration")
+	fs.StringVar(&rawSelector, "selector", "", "Only list the paths whose labels match the selector")
+	formatFlag(fs, &format)
+
+	config, err := internal.ReadFlagSetConfig(fs, args)
 
 	if err != nil {
 		kv.ui.Error(fmt.Sprintf("not able to read config: %s", err.Error()))
 		return 1
 	}
 
-	if config.RootToken == "" {
-		kv.ui.Error("missing root token")
+	if err := validateFormat(format); err != nil {
+		kv.ui.Error(err.Error())
 		return 1
 	}
 
-	rootTokenBytes, err := base64.StdEncoding.DecodeString(config.RootToken)
+	expiringWithin, err := parseDuration(rawExpiringWithin)
 
 	if err != nil {
-		kv.ui.Error(fmt.Sprintf("not able to decode root token: %s", err.Error()))
+		kv.ui.Error(fmt.Sprintf("not able to parse expiring-within: %s", err.Error()))
 		return 1
 	}
 
-	path := fs.Arg(0)
+	selector, err := internal.ParseLabelSelector(rawSelector)
+
+	if err != nil {
+		kv.ui.Error(fmt.Sprintf("not able to parse selector: %s", err.Error()))
+		return 1
+	}
 
-	if path == "" {
-		kv.ui.Error("missing path prefix")
+	if rawExpiringWithin != "" && rawSelector != "" {
+		kv.ui.Error("-selector can not be combined with -expiring-within")
 		return 1
 	}
 
+	path := fs.Arg(0)
+
 	if strings.HasPrefix(path, internal.KeyringPrefix) {
 		kv.ui.Warn(fmt.Sprintf("paths prefixed with %s are restricted", internal.KeyringPrefix))
 		return 1
 	}
 
-	kvbackend, err := internal.SetupBackend(defaultCtx, config)
+	barrier, cleanup, ok := unlockBarrier(defaultCtx, kv.ui, config)
 
-	if err != nil {
-		kv.ui.Error(fmt.Sprintf("failed to setup backend: %s", err.Error()))
+	if !ok {
 		return 1
 	}
 
-	defer func() {
-		cleanupErr := kvbackend.Cleanup(defaultCtx)
-		if cleanupErr != nil {
-			kv.ui.Warn(fmt.Sprintf("failed to gracefully shutdown backend: %s", cleanupErr.Error()))
-		}
-	}()
+	defer cleanup()
 
-	barrier, err := internal.NewBarrier(kvbackend)
+	kvStore, err := internal.NewKV(barrier)
 
 	if err != nil {
-		kv.ui.Error(fmt.Sprintf("failed to instantiate barrier: %s", err.Error()))
+		kv.ui.Error(fmt.Sprintf("failed to instantiate kv store: %s", err.Error()))
 		return 1
 	}
 
-	initialized, err := barrier.KeyringPersisted(defaultCtx)
+	if rawExpiringWithin != "" {
+		return kv.listExpiring(defaultCtx, kvStore, path, expiringWithin, format)
+	}
+
+	var pathNames []string
+
+	if rawSelector != "" {
+		pathNames, err = kvStore.Select(defaultCtx, path, selector)
+	} else {
+		pathNames, err = kvStore.List(defaultCtx, path, recursive)
+	}
 
 	if err != nil {
-		kv.ui.Error(fmt.Sprintf("failed to validate existing barrier: %s", err.Error()))
+		kv.ui.Error(fmt.Sprintf("failed to list paths at prefix %s", err.Error()))
 		return 1
 	}
 
-	if !initialized {
-		kv.ui.Warn("keyring not initialized. Run keyring init.")
-		return 1
+	output := &kvListOutput{
+		Folder: path,
+		Keys:   pathNames,
 	}
 
-	err = barrier.Initialize(defaultCtx, string(rootTokenBytes))
+	err = outputFormatted(kv.ui, format, output, func() {
+		for _, pathName := range pathNames {
+			kv.ui.Output(pathName)
+		}
+	})
 
 	if err != nil {
-		kv.ui.Error(fmt.Sprintf("failed to initialize existing barrier: %s", err.Error()))
+		kv.ui.Error(err.Error())
 		return 1
 	}
 
-	pathNames, err := barrier.List(defaultCtx, path)
+	return 0
+}
+
+// kvListOutput is the json and yaml schema of keyring list.
+type kvListOutput struct {
+	Folder string   `json:"folder"`
+	Keys   []string `json:"keys"`
+}
+
+// listExpiring lists the paths within the folder that expire within
+// the duration.
+func (kv *KVListCommand) listExpiring(ctx context.Context, kvStore *internal.KV, folder string, within time.Duration, format string) int {
+
+	expiring, err := kvStore.Expiring(ctx, folder, within)
 
 	if err != nil {
-		kv.ui.Error(fmt.Sprintf("failed to list paths at prefix %s", err.Error()))
+		kv.ui.Error(fmt.Sprintf("failed to list expiring paths at prefix %s", err.Error()))
 		return 1
 	}
 
-	for _, pathName := range pathNames {
-		kv.ui.Output(pathName)
+	output := &kvListExpiringOutput{
+		Folder: folder,
+		Keys:   make([]*kvExpirationOutput, 0, len(expiring)),
+	}
+
+	for _, expiration := range expiring {
+		output.Keys = append(output.Keys, &kvExpirationOutput{
+			Path:       expiration.Path,
+			ExpireTime: formatTime(expiration.ExpireTime),
+		})
+	}
+
+	err = outputFormatted(kv.ui, format, output, func() {
+
+		if len(expiring) == 0 {
+			return
+		}
+
+		kv.ui.Output("path\t\texpire_time")
+		kv.ui.Output("----\t\t-----------")
+
+		for _, expiration := range output.Keys {
+			kv.ui.Output(fmt.Sprintf("%s\t\t%s", expiration.Path, expiration.ExpireTime))
+		}
+	})
+
+	if err != nil {
+		kv.ui.Error(err.Error())
+		return 1
 	}
 
 	return 0
 }
+
+// kvListExpiringOutput is the json and yaml schema of
+// keyring list -expiring-within.
+type kvListExpiringOutput struct {
+	Folder string                `json:"folder"`
+	Keys   []*kvExpirationOutput `json:"keys"`
+}
+
+type kvExpirationOutput struct {
+	Path       string `json:"path"`
+	ExpireTime string `json:"expire_time"`
+}
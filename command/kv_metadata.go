@@ -0,0 +1,401 @@
+package command
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/cli"
+	"github.com/woodrufj4/keyring-practice/internal"
+)
+
+type KVMetadataCommand struct {
+	ui cli.Ui
+}
+
+func (kv KVMetadataCommand) Synopsis() string {
+	return "Interacts with the metadata of the key-value pairs at a path"
+}
+
+func (kv KVMetadataCommand) Help() string {
+	helpText := `
+Usage: keying metadata [options] <subcommand>
+
+  Reads and configures the metadata tracked for each path, such as
+  the stored versions and the number of versions to retain. The last
+  read time records reads made with keyring get.
+
+  Please see individual subcommand help for detailed usage information.`
+	return helpText
+}
+
+func (kv KVMetadataCommand) Run(args []string) int {
+	return cli.RunResultHelp
+}
+
+type KVMetadataGetCommand struct {
+	ui cli.Ui
+}
+
+func (kv KVMetadataGetCommand) Synopsis() string {
+	return "Retrieves the metadata at the provided path"
+}
+
+func (kv KVMetadataGetCommand) Help() string {
+	helpText := `
+Usage: keying metadata get [options] <path>
+
+  Retrieves the metadata and version history at the provided path.
+
+  Example:
+
+    $ keyring metadata get secret/foo
+
+  Options:
+
+    -format=<string>
+      The output format: table, json or yaml. Defaults to table,
+      or the '%s' environment variable when set.
+
+    -root-token=<string>
+      The root token to access the keyring.
+      If not provided here, the '%s' environment
+      variable will be used.
+
+  Backend Options:
+
+    -backend-type=<string>
+      The type of backend to use.
+      Currently, only the 'file' type backend is supported,
+      and is also the default.
+
+    File Backend Options:
+
+      -filepath=<string>
+        The file path where your secrets will be persisted to disc.
+`
+	return fmt.Sprintf(helpText, EnvFormat, internal.DefaultEnvRootToken)
+}
+
+func (kv *KVMetadataGetCommand) Run(args []string) int {
+
+	defaultCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+
+	defer cancel()
+
+	var format string
+
+	fs := flag.NewFlagSet("metadata get", flag.ContinueOnError)
+	formatFlag(fs, &format)
+
+	config, err := internal.ReadFlagSetConfig(fs, args)
+
+	if err != nil {
+		kv.ui.Error(fmt.Sprintf("not able to read config: %s", err.Error()))
+		return 1
+	}
+
+	if err := validateFormat(format); err != nil {
+		kv.ui.Error(err.Error())
+		return 1
+	}
+
+	path := fs.Arg(0)
+
+	if path == "" {
+		kv.ui.Error("missing path")
+		return 1
+	}
+
+	if strings.HasPrefix(path, internal.KeyringPrefix) {
+		kv.ui.Warn(fmt.Sprintf("paths prefixed with %s are restricted", internal.KeyringPrefix))
+		return 1
+	}
+
+	barrier, cleanup, ok := unlockBarrier(defaultCtx, kv.ui, config)
+
+	if !ok {
+		return 1
+	}
+
+	defer cleanup()
+
+	kvStore, err := internal.NewKV(barrier)
+
+	if err != nil {
+		kv.ui.Error(fmt.Sprintf("failed to instantiate kv store: %s", err.Error()))
+		return 1
+	}
+
+	metadata, err := kvStore.Metadata(defaultCtx, path)
+
+	if err != nil {
+		kv.ui.Error(fmt.Sprintf("failed to retrieve metadata at path '%s': %s", path, err.Error()))
+		return 1
+	}
+
+	if metadata == nil {
+		kv.ui.Error(fmt.Sprintf("No metadata found at %s", path))
+		return 1
+	}
+
+	output := &kvMetadataOutput{
+		Path:           path,
+		CreatedTime:    formatTime(metadata.CreatedTime),
+		UpdatedTime:    formatTime(metadata.UpdatedTime),
+		UpdatedBy:      metadata.UpdatedBy,
+		LastReadTime:   formatTime(metadata.LastReadTime),
+		ExpireTime:     formatTime(metadata.ExpireTime),
+		CurrentVersion: metadata.CurrentVersion,
+		OldestVersion:  metadata.OldestVersion,
+		MaxVersions:    metadata.MaxVersions,
+		CASRequired:    metadata.CASRequired,
+		CustomMetadata: customMetadataOutput(metadata.CustomMetadata),
+		Labels:         customMetadataOutput(metadata.Labels),
+		Versions:       make([]*kvVersionOutput, 0, len(metadata.Versions)),
+	}
+
+	for _, version := range metadata.SortedVersions() {
+		output.Versions = append(output.Versions, newKVVersionOutput(version, metadata.Versions[version]))
+	}
+
+	err = outputFormatted(kv.ui, format, output, func() {
+		kv.ui.Output("========== Metadata ==========")
+		kv.ui.Output("key\t\t\tValue")
+		kv.ui.Output("---\t\t\t-----")
+		kv.ui.Output(fmt.Sprintf("created_time\t\t%s", formatTime(metadata.CreatedTime)))
+		kv.ui.Output(fmt.Sprintf("updated_time\t\t%s", formatTime(metadata.UpdatedTime)))
+		kv.ui.Output(fmt.Sprintf("updated_by\t\t%s", metadata.UpdatedBy))
+		kv.ui.Output(fmt.Sprintf("last_read_time\t\t%s", formatTime(metadata.LastReadTime)))
+		kv.ui.Output(fmt.Sprintf("expire_time\t\t%s", formatTime(metadata.ExpireTime)))
+		kv.ui.Output(fmt.Sprintf("current_version\t\t%d", metadata.CurrentVersion))
+		kv.ui.Output(fmt.Sprintf("oldest_version\t\t%d", metadata.OldestVersion))
+		kv.ui.Output(fmt.Sprintf("max_versions\t\t%d", metadata.MaxVersions))
+		kv.ui.Output(fmt.Sprintf("cas_required\t\t%t", metadata.CASRequired))
+		kv.ui.Output(fmt.Sprintf("custom_metadata\t\t%s", mapFlag(metadata.CustomMetadata)))
+		kv.ui.Output(fmt.Sprintf("labels\t\t\t%s", mapFlag(metadata.Labels)))
+
+		kv.ui.Output("")
+		kv.ui.Output("========== Versions ==========")
+		kv.ui.Output("version\t\tcreated_time\t\tcreated_by\t\tdeletion_time\t\tdestroyed")
+		kv.ui.Output("-------\t\t------------\t\t----------\t\t-------------\t\t---------")
+
+		for _, version := range metadata.SortedVersions() {
+
+			versionMetadata := metadata.Versions[version]
+
+			kv.ui.Output(fmt.Sprintf("%d\t\t%s\t\t%s\t\t%s\t\t%t",
+				version,
+				formatTime(versionMetadata.CreatedTime),
+				versionMetadata.CreatedBy,
+				formatTime(versionMetadata.DeletionTime),
+				versionMetadata.Destroyed,
+			))
+		}
+	})
+
+	if err != nil {
+		kv.ui.Error(err.Error())
+		return 1
+	}
+
+	return 0
+}
+
+type KVMetadataPutCommand struct {
+	ui cli.Ui
+}
+
+func (kv KVMetadataPutCommand) Synopsis() string {
+	return "Configures the metadata at the provided path"
+}
+
+func (kv KVMetadataPutCommand) Help() string {
+	helpText := `
+Usage: keying metadata put [options] <path>
+
+  Configures the metadata at the provided path. The metadata is created
+  if nothing has been written to the path yet.
+
+  Example:
+
+    $ keyring metadata put -max-versions=5 secret/foo
+
+    $ keyring metadata put -custom-metadata=owner=payments secret/foo
+
+    $ keyring metadata put -label=env=prod -label=team=payments secret/foo
+
+  Options:
+
+    -max-versions=<int>
+      The number of versions to retain at the path. Older versions
+      are permanently removed. Setting this to 0 uses the default
+      of %d versions.
+
+    -cas-required=<bool>
+      Require every write to the path to provide a -cas version.
+
+    -ttl=<duration>
+      How long the path is valid for from now, such as 72h or 7d.
+      Setting this to 0 removes the expiration.
+
+    -custom-metadata=<key=value>
+      A custom key-value label to attach to the path. This can be
+      specified multiple times. Providing an empty value removes
+      the label.
+
+    -label=<key=value>
+      A label to attach to the path, which paths can be selected by
+      with -selector, such as keyring list -selector=env=prod. This can
+      be specified multiple times. Providing an empty value removes
+      the label.
+
+    -root-token=<string>
+      The root token to access the keyring.
+      If not provided here, the '%s' environment
+      variable will be used.
+
+  Backend Options:
+
+    -backend-type=<string>
+      The type of backend to use.
+      Currently, only the 'file' type backend is supported,
+      and is also the default.
+
+    File Backend Options:
+
+      -filepath=<string>
+        The file path where your secrets will be persisted to disc.
+`
+	return fmt.Sprintf(helpText, internal.DefaultMaxVersions, internal.DefaultEnvRootToken)
+}
+
+func (kv *KVMetadataPutCommand) Run(args []string) int {
+
+	defaultCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+
+	defer cancel()
+
+	var maxVersions int
+	var casRequired bool
+	var rawTTL string
+
+	customMetadata := make(mapFlag)
+	labels := make(mapFlag)
+
+	fs := flag.NewFlagSet("metadata put", flag.ContinueOnError)
+	fs.IntVar(&maxVersions, "max-versions", -1, "The number of versions to retain")
+	fs.BoolVar(&casRequired, "cas-required", false, "Require a check-and-set version for writes")
+	fs.StringVar(&rawTTL, "ttl", "", "How long the path is valid for from now")
+	fs.Var(customMetadata, "custom-metadata", "A custom key-value label to attach to the path")
+	fs.Var(labels, "label", "A label to attach to the path")
+
+	config, err := internal.ReadFlagSetConfig(fs, args)
+
+	if err != nil {
+		kv.ui.Error(fmt.Sprintf("not able to read config: %s", err.Error()))
+		return 1
+	}
+
+	ttl, err := parseDuration(rawTTL)
+
+	if err != nil {
+		kv.ui.Error(fmt.Sprintf("not able to parse ttl: %s", err.Error()))
+		return 1
+	}
+
+	input := &internal.KVMetadataInput{}
+
+	if maxVersions >= 0 {
+		input.MaxVersions = &maxVersions
+	}
+
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "cas-required":
+			input.CASRequired = &casRequired
+		case "custom-metadata":
+			input.CustomMetadata = customMetadata
+		case "label":
+			input.Labels = labels
+		case "ttl":
+			input.TTL = &ttl
+		}
+	})
+
+	path := fs.Arg(0)
+
+	if path == "" {
+		kv.ui.Error("missing path")
+		return 1
+	}
+
+	if strings.HasPrefix(path, internal.KeyringPrefix) {
+		kv.ui.Warn(fmt.Sprintf("paths prefixed with %s are restricted", internal.KeyringPrefix))
+		return 1
+	}
+
+	barrier, cleanup, ok := unlockBarrier(defaultCtx, kv.ui, config)
+
+	if !ok {
+		return 1
+	}
+
+	defer cleanup()
+
+	kvStore, err := internal.NewKV(barrier)
+
+	if err != nil {
+		kv.ui.Error(fmt.Sprintf("failed to instantiate kv store: %s", err.Error()))
+		return 1
+	}
+
+	err = kvStore.PutMetadata(defaultCtx, path, input)
+
+	if err != nil {
+		kv.ui.Error(fmt.Sprintf("failed to update metadata at path '%s': %s", path, err.Error()))
+		return 1
+	}
+
+	kv.ui.Info(fmt.Sprintf("Success! Metadata written to: %s", path))
+
+	return 0
+}
+
+// kvMetadataOutput is the json and yaml schema of keyring metadata get.
+type kvMetadataOutput struct {
+	Path           string             `json:"path"`
+	CreatedTime    string             `json:"created_time"`
+	UpdatedTime    string             `json:"updated_time"`
+	UpdatedBy      string             `json:"updated_by"`
+	LastReadTime   string             `json:"last_read_time"`
+	ExpireTime     string             `json:"expire_time"`
+	CurrentVersion uint64             `json:"current_version"`
+	OldestVersion  uint64             `json:"oldest_version"`
+	MaxVersions    int                `json:"max_versions"`
+	CASRequired    bool               `json:"cas_required"`
+	CustomMetadata map[string]string  `json:"custom_metadata"`
+	Labels         map[string]string  `json:"labels"`
+	Versions       []*kvVersionOutput `json:"versions"`
+}
+
+// kvVersionOutput is the json and yaml schema of a single version.
+type kvVersionOutput struct {
+	Version      uint64 `json:"version"`
+	CreatedTime  string `json:"created_time"`
+	CreatedBy    string `json:"created_by"`
+	DeletionTime string `json:"deletion_time"`
+	Destroyed    bool   `json:"destroyed"`
+}
+
+func newKVVersionOutput(version uint64, metadata *internal.KVVersion) *kvVersionOutput {
+	return &kvVersionOutput{
+		Version:      version,
+		CreatedTime:  formatTime(metadata.CreatedTime),
+		CreatedBy:    metadata.CreatedBy,
+		DeletionTime: formatTime(metadata.DeletionTime),
+		Destroyed:    metadata.Destroyed,
+	}
+}
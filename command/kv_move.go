@@ -0,0 +1,67 @@
+package command
+
+import (
+	"fmt"
+
+	"github.com/mitchellh/cli"
+	"github.com/woodrufj4/keyring-practice/internal"
+)
+
+type KVMoveCommand struct {
+	ui cli.Ui
+}
+
+func (kv KVMoveCommand) Synopsis() string {
+	return "Moves the key-value pairs at a path to another path"
+}
+
+func (kv KVMoveCommand) Help() string {
+	helpText := `
+Usage: keying move [options] <source> <destination>
+
+  Moves every version and the metadata at the source path to the
+  destination path. The source is removed in the same transaction the
+  destination is written in, so the data is never lost midway. The moved
+  entries are re-encrypted under the active key term.
+
+  With -recursive, every path within the source prefix is moved to the
+  same relative path within the destination prefix.
+
+  Example:
+
+    $ keyring move secret/app-old secret/app
+
+    $ keyring move -recursive secret/legacy/ secret/archive/
+
+  Options:
+
+    -recursive
+      Move every path within the source prefix.
+
+    -force
+      Replace destination paths that already exist, including
+      their version history.
+
+    -root-token=<string>
+      The root token to access the keyring.
+      If not provided here, the '%s' environment
+      variable will be used.
+
+  Backend Options:
+
+    -backend-type=<string>
+      The type of backend to use.
+      Currently, only the 'file' type backend is supported,
+      and is also the default.
+
+    File Backend Options:
+
+      -filepath=<string>
+        The file path where your secrets will be persisted to disc.
+`
+	return fmt.Sprintf(helpText, internal.DefaultEnvRootToken)
+}
+
+func (kv *KVMoveCommand) Run(args []string) int {
+	return runRelocation(kv.ui, "move", args)
+}
@@ -0,0 +1,161 @@
+package command
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/cli"
+	"github.com/woodrufj4/keyring-practice/internal"
+)
+
+type KVPatchCommand struct {
+	ui cli.Ui
+}
+
+func (kv KVPatchCommand) Synopsis() string {
+	return "Merges key-value pairs into the existing data at the provided path"
+}
+
+func (kv KVPatchCommand) Help() string {
+	helpText := `
+Usage: keying patch [options] <path> [data]
+
+  This merges the key-value pairs into the current version at the given
+  path, writing the result as a new version. Existing keys that are not
+  provided are left unchanged. There must be existing data at the path.
+
+  There can be any number of key-value pairs. For example:
+
+    $ keyring patch secret/foo bar=updated
+
+  The data can also be consumed from a file on disk by prefixing with the "@"
+  symbol. For example:
+
+    $ keyring patch secret/foo @data.json
+
+  Values can be read from a file as raw bytes with the "@file:" prefix,
+  which is suited to certificates, keystores and other binary data. For
+  example:
+
+    $ keyring patch secret/tls cert=@file:cert.pem key=@file:key.pem
+
+  When a schema is bound to a prefix of the path, the merged key-value
+  pairs are validated against it, and the patch is refused when they do
+  not match.
+
+  Options:
+
+    -value-encoding=<string>
+      How the provided values are stored: json, raw or base64. Values
+      are stored as json by default. With raw, string values are stored
+      as is, while base64 values are decoded and stored as raw bytes.
+      Values read with "@file:" are always stored as raw bytes, and
+      are decoded first with the base64 encoding.
+
+    -cas=<int>
+      Only write if the current version at the path matches. This is
+      required for paths configured with -cas-required.
+
+    -root-token=<string>
+      The root token to use for encryption.
+      If not provided here, the '%s' environment
+      variable will be used.
+
+  Backend Options:
+
+    -backend-type=<string>
+      The type of backend to use.
+      Currently, only the 'file' type backend is supported,
+      and is also the default.
+
+    File Backend Options:
+
+      -filepath=<string>
+        The file path where your secrets will be persisted to disc.
+`
+	return fmt.Sprintf(helpText, internal.DefaultEnvRootToken)
+}
+
+func (kv *KVPatchCommand) Run(args []string) int {
+
+	defaultCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+
+	defer cancel()
+
+	var cas int64
+	var valueEncoding string
+
+	fs := flag.NewFlagSet("patch", flag.ContinueOnError)
+	fs.Int64Var(&cas, "cas", -1, "The version expected to be current at the path")
+	fs.StringVar(&valueEncoding, "value-encoding", internal.ValueEncodingJSON, "How the provided values are stored")
+
+	config, err := internal.ReadFlagSetConfig(fs, args)
+
+	if err != nil {
+		kv.ui.Error(fmt.Sprintf("not able to read config: %s", err.Error()))
+		return 1
+	}
+
+	if err := validateValueEncoding(valueEncoding); err != nil {
+		kv.ui.Error(err.Error())
+		return 1
+	}
+
+	path := fs.Arg(0)
+
+	if path == "" {
+		kv.ui.Error("missing path")
+		return 1
+	}
+
+	if strings.HasPrefix(path, internal.KeyringPrefix) {
+		kv.ui.Warn(fmt.Sprintf("paths prefixed with %s are restricted", internal.KeyringPrefix))
+		return 1
+	}
+
+	entries, err := parseKVEntries(os.Stdin, fs.Args()[1:], valueEncoding)
+
+	if err != nil {
+		kv.ui.Error(fmt.Sprintf("failed to parse key value pairs: %s", err.Error()))
+		return 1
+	}
+
+	barrier, cleanup, ok := unlockBarrier(defaultCtx, kv.ui, config)
+
+	if !ok {
+		return 1
+	}
+
+	defer cleanup()
+
+	kvStore, err := internal.NewKV(barrier)
+
+	if err != nil {
+		kv.ui.Error(fmt.Sprintf("failed to instantiate kv store: %s", err.Error()))
+		return 1
+	}
+
+	version, err := kvStore.Patch(defaultCtx, path, entries, kvPutOptions(cas))
+
+	if isCASError(err) {
+		kv.ui.Error(fmt.Sprintf("refusing to write to path '%s': %s", path, err.Error()))
+		kv.ui.Error("Run keyring get to read the current version, then retry with -cas=<version>.")
+		return 1
+	}
+
+	if reportSchemaError(kv.ui, err) {
+		return 1
+	}
+
+	if err != nil {
+		kv.ui.Error(fmt.Sprintf("failed to patch kv pairs at path '%s': %s", path, err.Error()))
+		return 1
+	}
+
+	kv.ui.Info(fmt.Sprintf("success! version: %d", version))
+	return 0
+}
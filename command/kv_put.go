@@ -2,8 +2,6 @@ package command
 
 import (
 	"context"
-	"encoding/base64"
-	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
@@ -11,8 +9,6 @@ import (
 	"time"
 
 	"github.com/mitchellh/cli"
-	"github.com/woodrufj4/keyring-practice/backend"
-	"github.com/woodrufj4/keyring-practice/backend/bbolt"
 	"github.com/woodrufj4/keyring-practice/internal"
 )
 
@@ -31,6 +27,11 @@ Usage: keying put [options] <path> [data]
   This stores and encrypts a key-value pairs at the given path.
   The path is used to lookup the key-value pairs during decryption.
 
+  Every put creates a new version at the path, replacing all the
+  key-value pairs of the previous version. Use keyring patch to merge
+  key-value pairs into the current version instead. Older versions are
+  retained up to the max versions configured in the path metadata.
+
   There can be any number of key-value pairs. For example:
 
     $ keyring put secret/foo bar=baz key=secret
@@ -40,8 +41,36 @@ Usage: keying put [options] <path> [data]
 
     $ keyring put secret/foo @data.json
 
+  Values can be read from a file as raw bytes with the "@file:" prefix,
+  which is suited to certificates, keystores and other binary data. For
+  example:
+
+    $ keyring put secret/tls cert=@file:cert.pem key=@file:key.pem
+
+  When a schema is bound to a prefix of the path with keyring schema put,
+  the key-value pairs are validated against it, and the write is refused
+  when they do not match.
+
   Options:
 
+    -value-encoding=<string>
+      How the provided values are stored: json, raw or base64. Values
+      are stored as json by default. With raw, string values are stored
+      as is, while base64 values are decoded and stored as raw bytes.
+      Values read with "@file:" are always stored as raw bytes, and
+      are decoded first with the base64 encoding.
+
+    -cas=<int>
+      Only write if the current version at the path matches. Setting
+      this to 0 only writes if nothing exists at the path yet. This is
+      required for paths configured with -cas-required.
+
+    -ttl=<duration>
+      How long the secret is valid for, such as 72h or 7d. Expired
+      secrets can not be read, and are removed by keyring operator reap.
+      By default, the existing expiration of the path is kept, unless
+      it has already passed.
+
     -root-token=<string>
       The root token to use for encryption.
       If not provided here, the '%s' environment
@@ -69,22 +98,31 @@ func (kv *KVPutCommand) Run(args []string) int {
 
 	defer cancel()
 
-	config, fs, err := kv.readConfig(args)
+	var cas int64
+	var valueEncoding string
+	var rawTTL string
+
+	fs := flag.NewFlagSet("put", flag.ContinueOnError)
+	fs.Int64Var(&cas, "cas", -1, "The version expected to be current at the path")
+	fs.StringVar(&valueEncoding, "value-encoding", internal.ValueEncodingJSON, "How the provided values are stored")
+	fs.StringVar(&rawTTL, "ttl", "", "How long the secret is valid for")
+
+	config, err := internal.ReadFlagSetConfig(fs, args)
 
 	if err != nil {
 		kv.ui.Error(fmt.Sprintf("not able to read config: %s", err.Error()))
 		return 1
 	}
 
-	if config.RootToken == "" {
-		kv.ui.Error("missing root token")
+	ttl, err := parseDuration(rawTTL)
+
+	if err != nil {
+		kv.ui.Error(fmt.Sprintf("not able to parse ttl: %s", err.Error()))
 		return 1
 	}
 
-	rootTokenBytes, err := base64.StdEncoding.DecodeString(config.RootToken)
-
-	if err != nil {
-		kv.ui.Error(fmt.Sprintf("not able to decode root token: %s", err.Error()))
+	if err := validateValueEncoding(valueEncoding); err != nil {
+		kv.ui.Error(err.Error())
 		return 1
 	}
 
@@ -100,151 +138,48 @@ func (kv *KVPutCommand) Run(args []string) int {
 		return 1
 	}
 
-	kvPairs, err := internal.ParseArgsData(os.Stdin, fs.Args()[1:])
+	entries, err := parseKVEntries(os.Stdin, fs.Args()[1:], valueEncoding)
 
 	if err != nil {
 		kv.ui.Error(fmt.Sprintf("failed to parse key value pairs: %s", err.Error()))
 		return 1
 	}
 
-	if len(kvPairs) == 0 {
-		kv.ui.Error("must provide key value data")
-		return 1
-	}
-
-	kvbackend, err := kv.setupBackend(defaultCtx, config)
+	barrier, cleanup, ok := unlockBarrier(defaultCtx, kv.ui, config)
 
-	if err != nil {
-		kv.ui.Error(fmt.Sprintf("failed to setup backend: %s", err.Error()))
+	if !ok {
 		return 1
 	}
 
-	defer func() {
-		cleanupErr := kvbackend.Cleanup(defaultCtx)
-		if cleanupErr != nil {
-			kv.ui.Warn(fmt.Sprintf("failed to gracefully shutdown backend: %s", cleanupErr.Error()))
-		}
-	}()
+	defer cleanup()
 
-	barrier, err := internal.NewBarrier(kvbackend)
+	kvStore, err := internal.NewKV(barrier)
 
 	if err != nil {
-		kv.ui.Error(fmt.Sprintf("failed to instantiate barrier: %s", err.Error()))
+		kv.ui.Error(fmt.Sprintf("failed to instantiate kv store: %s", err.Error()))
 		return 1
 	}
 
-	initialized, err := barrier.KeyringPersisted(defaultCtx)
+	opts := kvPutOptions(cas)
+	opts.TTL = ttl
 
-	if err != nil {
-		kv.ui.Error(fmt.Sprintf("failed to validate existing barrier: %s", err.Error()))
-		return 1
-	}
+	version, err := kvStore.Put(defaultCtx, path, entries, opts)
 
-	if !initialized {
-		kv.ui.Warn("keyring not initialized. Run keyring init.")
+	if isCASError(err) {
+		kv.ui.Error(fmt.Sprintf("refusing to write to path '%s': %s", path, err.Error()))
+		kv.ui.Error("Run keyring get to read the current version, then retry with -cas=<version>.")
 		return 1
 	}
 
-	err = barrier.Initialize(defaultCtx, string(rootTokenBytes))
-
-	if err != nil {
-		kv.ui.Error(fmt.Sprintf("failed to initialize existing barrier: %s", err.Error()))
+	if reportSchemaError(kv.ui, err) {
 		return 1
 	}
 
-	entries := make([]*backend.BackendEntry, 0)
-
-	for key, pair := range kvPairs {
-
-		entryBytes, err := json.Marshal(pair)
-
-		if err != nil {
-			kv.ui.Error(fmt.Sprintf("failed to convert key value into bytes: %s", err.Error()))
-			return 1
-		}
-
-		entries = append(entries, &backend.BackendEntry{
-			Key:   key,
-			Value: entryBytes,
-		})
-	}
-
-	err = barrier.Put(defaultCtx, path, entries)
-
 	if err != nil {
 		kv.ui.Error(fmt.Sprintf("failed to persist kv pairs at path '%s': %s", path, err.Error()))
 		return 1
 	}
 
-	kv.ui.Info("success!")
+	kv.ui.Info(fmt.Sprintf("success! version: %d", version))
 	return 0
 }
-
-func (kv *KVPutCommand) readConfig(args []string) (*internal.GeneralConfig, *flag.FlagSet, error) {
-
-	config := &internal.GeneralConfig{
-		Backend: &internal.BackendConfig{},
-	}
-
-	fs := flag.NewFlagSet("put", flag.ContinueOnError)
-	fs.StringVar(&config.RootToken, "root-token", "", "The root token to use for encryption")
-	fs.StringVar(&config.Backend.Type, "backend-type", "", "The type of backend to use")
-
-	// filebackend type
-	fileConfig := &bbolt.Config{}
-	fs.StringVar(&fileConfig.Path, "filepath", "", "The file path to the local datastore")
-
-	if err := fs.Parse(args); err != nil {
-		return nil, nil, err
-	}
-
-	config = internal.DefaultGeneralConfig().Merge(config)
-
-	backendType, err := config.Backend.GetBackendType()
-
-	if err != nil {
-		return nil, nil, err
-	}
-
-	if backendType == backend.FileBackend {
-		config.Backend.Options = bbolt.DefaultConfig().Merge(fileConfig)
-	} else {
-		return nil, nil, fmt.Errorf("backend options not setup for backend type '%s'", backendType)
-	}
-
-	return config, fs, nil
-}
-
-func (kv *KVPutCommand) setupBackend(ctx context.Context, config *internal.GeneralConfig) (backend.Backend, error) {
-
-	backendType, err := config.Backend.GetBackendType()
-
-	if err != nil {
-		return nil, err
-	}
-
-	if backendType == backend.FileBackend {
-
-		backendConfig, ok := config.Backend.Options.(*bbolt.Config)
-
-		if !ok {
-			return nil, fmt.Errorf("failed to cast backend config to file backend config")
-		}
-
-		fileBackend, err := bbolt.NewBoltBackend(backendConfig)
-
-		if err != nil {
-			return nil, err
-		}
-
-		err = fileBackend.Setup(ctx)
-
-		if err != nil {
-			return nil, err
-		}
-
-		return fileBackend, nil
-	}
-
-	return nil, nil
-}
@@ -0,0 +1,134 @@
+package command
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/cli"
+	"github.com/woodrufj4/keyring-practice/internal"
+)
+
+type KVRollbackCommand struct {
+	ui cli.Ui
+}
+
+func (kv KVRollbackCommand) Synopsis() string {
+	return "Rolls back the key-value pairs at the provided path to a previous version"
+}
+
+func (kv KVRollbackCommand) Help() string {
+	helpText := `
+Usage: keying rollback [options] <path>
+
+  Writes the key-value pairs of a previous version as a new version
+  at the provided path. No versions are removed.
+
+  Example:
+
+    $ keyring rollback -version=2 secret/foo
+
+  Options:
+
+    -version=<int>
+      The version to roll back to. This is required.
+
+    -cas=<int>
+      Only write if the current version at the path matches. This is
+      required for paths configured with -cas-required.
+
+    -root-token=<string>
+      The root token to access the keyring.
+      If not provided here, the '%s' environment
+      variable will be used.
+
+  Backend Options:
+
+    -backend-type=<string>
+      The type of backend to use.
+      Currently, only the 'file' type backend is supported,
+      and is also the default.
+
+    File Backend Options:
+
+      -filepath=<string>
+        The file path where your secrets will be persisted to disc.
+`
+	return fmt.Sprintf(helpText, internal.DefaultEnvRootToken)
+}
+
+func (kv *KVRollbackCommand) Run(args []string) int {
+
+	defaultCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+
+	defer cancel()
+
+	var version uint64
+	var cas int64
+
+	fs := flag.NewFlagSet("rollback", flag.ContinueOnError)
+	fs.Uint64Var(&version, "version", 0, "The version to roll back to")
+	fs.Int64Var(&cas, "cas", -1, "The version expected to be current at the path")
+
+	config, err := internal.ReadFlagSetConfig(fs, args)
+
+	if err != nil {
+		kv.ui.Error(fmt.Sprintf("not able to read config: %s", err.Error()))
+		return 1
+	}
+
+	if version == 0 {
+		kv.ui.Error("missing version")
+		return 1
+	}
+
+	path := fs.Arg(0)
+
+	if path == "" {
+		kv.ui.Error("missing path")
+		return 1
+	}
+
+	if strings.HasPrefix(path, internal.KeyringPrefix) {
+		kv.ui.Warn(fmt.Sprintf("paths prefixed with %s are restricted", internal.KeyringPrefix))
+		return 1
+	}
+
+	barrier, cleanup, ok := unlockBarrier(defaultCtx, kv.ui, config)
+
+	if !ok {
+		return 1
+	}
+
+	defer cleanup()
+
+	kvStore, err := internal.NewKV(barrier)
+
+	if err != nil {
+		kv.ui.Error(fmt.Sprintf("failed to instantiate kv store: %s", err.Error()))
+		return 1
+	}
+
+	newVersion, err := kvStore.Rollback(defaultCtx, path, version, kvPutOptions(cas))
+
+	if isCASError(err) {
+		kv.ui.Error(fmt.Sprintf("refusing to write to path '%s': %s", path, err.Error()))
+		kv.ui.Error("Run keyring get to read the current version, then retry with -cas=<version>.")
+		return 1
+	}
+
+	if reportSchemaError(kv.ui, err) {
+		return 1
+	}
+
+	if err != nil {
+		kv.ui.Error(fmt.Sprintf("failed to roll back path '%s' to version %d: %s", path, version, err.Error()))
+		return 1
+	}
+
+	kv.ui.Info(fmt.Sprintf("success! version: %d", newVersion))
+
+	return 0
+}
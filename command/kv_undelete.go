@@ -0,0 +1,125 @@
+package command
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/cli"
+	"github.com/woodrufj4/keyring-practice/internal"
+)
+
+type KVUndeleteCommand struct {
+	ui cli.Ui
+}
+
+func (kv KVUndeleteCommand) Synopsis() string {
+	return "Restores deleted versions of the key-value pairs at the provided path"
+}
+
+func (kv KVUndeleteCommand) Help() string {
+	helpText := `
+Usage: keying undelete [options] <path>
+
+  Restores soft deleted versions of the key-value pairs at the provided
+  path. Destroyed versions cannot be restored.
+
+  Example:
+
+    $ keyring undelete -versions=2,3 secret/foo
+
+  Options:
+
+    -versions=<int,...>
+      The versions to restore. This is required.
+
+    -root-token=<string>
+      The root token to access the keyring.
+      If not provided here, the '%s' environment
+      variable will be used.
+
+  Backend Options:
+
+    -backend-type=<string>
+      The type of backend to use.
+      Currently, only the 'file' type backend is supported,
+      and is also the default.
+
+    File Backend Options:
+
+      -filepath=<string>
+        The file path where your secrets will be persisted to disc.
+`
+	return fmt.Sprintf(helpText, internal.DefaultEnvRootToken)
+}
+
+func (kv *KVUndeleteCommand) Run(args []string) int {
+
+	defaultCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+
+	defer cancel()
+
+	var rawVersions string
+
+	fs := flag.NewFlagSet("undelete", flag.ContinueOnError)
+	fs.StringVar(&rawVersions, "versions", "", "The versions to restore")
+
+	config, err := internal.ReadFlagSetConfig(fs, args)
+
+	if err != nil {
+		kv.ui.Error(fmt.Sprintf("not able to read config: %s", err.Error()))
+		return 1
+	}
+
+	versions, err := parseVersions(rawVersions)
+
+	if err != nil {
+		kv.ui.Error(fmt.Sprintf("not able to parse versions: %s", err.Error()))
+		return 1
+	}
+
+	if len(versions) == 0 {
+		kv.ui.Error("missing versions")
+		return 1
+	}
+
+	path := fs.Arg(0)
+
+	if path == "" {
+		kv.ui.Error("missing path")
+		return 1
+	}
+
+	if strings.HasPrefix(path, internal.KeyringPrefix) {
+		kv.ui.Warn(fmt.Sprintf("paths prefixed with %s are restricted", internal.KeyringPrefix))
+		return 1
+	}
+
+	barrier, cleanup, ok := unlockBarrier(defaultCtx, kv.ui, config)
+
+	if !ok {
+		return 1
+	}
+
+	defer cleanup()
+
+	kvStore, err := internal.NewKV(barrier)
+
+	if err != nil {
+		kv.ui.Error(fmt.Sprintf("failed to instantiate kv store: %s", err.Error()))
+		return 1
+	}
+
+	err = kvStore.Undelete(defaultCtx, path, versions)
+
+	if err != nil {
+		kv.ui.Error(fmt.Sprintf("failed to undelete secrets at path %s: %s", path, err.Error()))
+		return 1
+	}
+
+	kv.ui.Info(fmt.Sprintf("Success! Data restored (if it existed) at: %s", path))
+
+	return 0
+}
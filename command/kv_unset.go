@@ -0,0 +1,131 @@
+package command
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/cli"
+	"github.com/woodrufj4/keyring-practice/internal"
+)
+
+type KVUnsetCommand struct {
+	ui cli.Ui
+}
+
+func (kv KVUnsetCommand) Synopsis() string {
+	return "Removes individual keys from the data at the provided path"
+}
+
+func (kv KVUnsetCommand) Help() string {
+	helpText := `
+Usage: keying unset [options] <path> <key>...
+
+  This removes the keys from the current version at the given path,
+  writing the result as a new version. There must be existing data
+  at the path.
+
+  Example:
+
+    $ keyring unset secret/foo bar key
+
+  Options:
+
+    -cas=<int>
+      Only write if the current version at the path matches. This is
+      required for paths configured with -cas-required.
+
+    -root-token=<string>
+      The root token to use for encryption.
+      If not provided here, the '%s' environment
+      variable will be used.
+
+  Backend Options:
+
+    -backend-type=<string>
+      The type of backend to use.
+      Currently, only the 'file' type backend is supported,
+      and is also the default.
+
+    File Backend Options:
+
+      -filepath=<string>
+        The file path where your secrets will be persisted to disc.
+`
+	return fmt.Sprintf(helpText, internal.DefaultEnvRootToken)
+}
+
+func (kv *KVUnsetCommand) Run(args []string) int {
+
+	defaultCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+
+	defer cancel()
+
+	var cas int64
+
+	fs := flag.NewFlagSet("unset", flag.ContinueOnError)
+	fs.Int64Var(&cas, "cas", -1, "The version expected to be current at the path")
+
+	config, err := internal.ReadFlagSetConfig(fs, args)
+
+	if err != nil {
+		kv.ui.Error(fmt.Sprintf("not able to read config: %s", err.Error()))
+		return 1
+	}
+
+	path := fs.Arg(0)
+
+	if path == "" {
+		kv.ui.Error("missing path")
+		return 1
+	}
+
+	if strings.HasPrefix(path, internal.KeyringPrefix) {
+		kv.ui.Warn(fmt.Sprintf("paths prefixed with %s are restricted", internal.KeyringPrefix))
+		return 1
+	}
+
+	keys := fs.Args()[1:]
+
+	if len(keys) == 0 {
+		kv.ui.Error("must provide at least one key")
+		return 1
+	}
+
+	barrier, cleanup, ok := unlockBarrier(defaultCtx, kv.ui, config)
+
+	if !ok {
+		return 1
+	}
+
+	defer cleanup()
+
+	kvStore, err := internal.NewKV(barrier)
+
+	if err != nil {
+		kv.ui.Error(fmt.Sprintf("failed to instantiate kv store: %s", err.Error()))
+		return 1
+	}
+
+	version, err := kvStore.Unset(defaultCtx, path, keys, kvPutOptions(cas))
+
+	if isCASError(err) {
+		kv.ui.Error(fmt.Sprintf("refusing to write to path '%s': %s", path, err.Error()))
+		kv.ui.Error("Run keyring get to read the current version, then retry with -cas=<version>.")
+		return 1
+	}
+
+	if reportSchemaError(kv.ui, err) {
+		return 1
+	}
+
+	if err != nil {
+		kv.ui.Error(fmt.Sprintf("failed to unset keys at path '%s': %s", path, err.Error()))
+		return 1
+	}
+
+	kv.ui.Info(fmt.Sprintf("success! version: %d", version))
+	return 0
+}
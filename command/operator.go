@@ -0,0 +1,182 @@
+package command
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/mitchellh/cli"
+	"github.com/woodrufj4/keyring-practice/internal"
+)
+
+type OperatorCommand struct {
+	ui cli.Ui
+}
+
+func (oc OperatorCommand) Synopsis() string {
+	return "Performs maintenance operations on the keyring"
+}
+
+func (oc OperatorCommand) Help() string {
+	helpText := `
+Usage: keying operator [options] <subcommand>
+
+  Performs maintenance operations on the keyring, such as removing
+  expired secrets or changing its storage options.
+
+  Please see individual subcommand help for detailed usage information.`
+	return helpText
+}
+
+func (oc OperatorCommand) Run(args []string) int {
+	return cli.RunResultHelp
+}
+
+type OperatorReapCommand struct {
+	ui cli.Ui
+}
+
+func (oc OperatorReapCommand) Synopsis() string {
+	return "Permanently removes expired secrets"
+}
+
+func (oc OperatorReapCommand) Help() string {
+	helpText := `
+Usage: keying operator reap [options]
+
+  Permanently removes every path that has expired, along with all of
+  its versions and metadata. Expirations are set with the -ttl option
+  of keyring put.
+
+  By default, expired paths are removed once. With -interval, the reaper
+  keeps running until interrupted. The backend is only opened while
+  reaping, so other commands can use the keyring in between.
+
+  Example:
+
+    $ keyring operator reap
+
+    $ keyring operator reap -interval=1h
+
+  Options:
+
+    -interval=<duration>
+      Keep reaping expired paths at the interval, such as 1h or 1d.
+
+    -root-token=<string>
+      The root token to access the keyring.
+      If not provided here, the '%s' environment
+      variable will be used.
+
+  Backend Options:
+
+    -backend-type=<string>
+      The type of backend to use.
+      Currently, only the 'file' type backend is supported,
+      and is also the default.
+
+    File Backend Options:
+
+      -filepath=<string>
+        The file path where your secrets will be persisted to disc.
+`
+	return fmt.Sprintf(helpText, internal.DefaultEnvRootToken)
+}
+
+func (oc *OperatorReapCommand) Run(args []string) int {
+
+	var rawInterval string
+
+	fs := flag.NewFlagSet("operator reap", flag.ContinueOnError)
+	fs.StringVar(&rawInterval, "interval", "", "Keep reaping expired paths at the interval")
+
+	config, err := internal.ReadFlagSetConfig(fs, args)
+
+	if err != nil {
+		oc.ui.Error(fmt.Sprintf("not able to read config: %s", err.Error()))
+		return 1
+	}
+
+	interval, err := parseDuration(rawInterval)
+
+	if err != nil {
+		oc.ui.Error(fmt.Sprintf("not able to parse interval: %s", err.Error()))
+		return 1
+	}
+
+	if interval < 0 {
+		oc.ui.Error("interval must not be negative")
+		return 1
+	}
+
+	if interval == 0 {
+		return oc.reap(config)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	oc.ui.Info(fmt.Sprintf("Reaping expired secrets every %s", interval))
+
+	for {
+
+		// Failures are reported, but do not stop the reaper,
+		// so the next interval can retry.
+		oc.reap(config)
+
+		select {
+
+		case <-ticker.C:
+
+		case sig := <-signals:
+			oc.ui.Info(fmt.Sprintf("Received %s, shutting down the reaper", sig))
+			return 0
+		}
+	}
+}
+
+// reap permanently removes the expired paths once.
+func (oc *OperatorReapCommand) reap(config *internal.GeneralConfig) int {
+
+	defaultCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+
+	defer cancel()
+
+	barrier, cleanup, ok := unlockBarrier(defaultCtx, oc.ui, config)
+
+	if !ok {
+		return 1
+	}
+
+	defer cleanup()
+
+	kvStore, err := internal.NewKV(barrier)
+
+	if err != nil {
+		oc.ui.Error(fmt.Sprintf("failed to instantiate kv store: %s", err.Error()))
+		return 1
+	}
+
+	reaped, err := kvStore.Reap(defaultCtx)
+
+	for _, path := range reaped {
+		oc.ui.Output(fmt.Sprintf("Removed expired path: %s", path))
+	}
+
+	if err != nil {
+		oc.ui.Error(fmt.Sprintf("failed to reap expired paths: %s", err.Error()))
+		return 1
+	}
+
+	oc.ui.Info(fmt.Sprintf("Success! Reaped %d expired paths", len(reaped)))
+
+	return 0
+}
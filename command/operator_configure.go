@@ -0,0 +1,224 @@
+package command
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/cli"
+	"github.com/woodrufj4/keyring-practice/internal"
+)
+
+type OperatorConfigureCommand struct {
+	ui cli.Ui
+}
+
+func (oc OperatorConfigureCommand) Synopsis() string {
+	return "Reads or changes the storage options of the keyring"
+}
+
+func (oc OperatorConfigureCommand) Help() string {
+	helpText := `
+Usage: keying operator configure [options]
+
+  Changes the storage options of the keyring, and then prints them.
+  Without any options, the current storage options are printed. Changes
+  only apply to values written afterwards, while existing values remain
+  readable.
+
+  Example:
+
+    $ keyring operator configure -padding=padme
+
+    $ keyring operator configure -compression=zstd -compression-exclude=secret/tokens/
+
+    $ keyring operator configure -blind-index
+
+  Options:
+
+    -padding=<string>
+      The scheme values are padded with before encryption, hiding their
+      length: none, buckets or padme. With buckets, values are padded to
+      64, 256, 1024 or 4096 bytes, or a multiple of 4096 bytes.
+
+    -compression=<string>
+      The scheme values are compressed with before encryption: none,
+      gzip or zstd. Values are compressed before they are padded.
+
+    -compression-threshold=<int>
+      The size in bytes of the smallest value to compress. Defaults
+      to 256 bytes.
+
+    -compression-exclude=<string>
+      A comma separated list of path prefixes which are never compressed,
+      replacing the current list. Exclude secrets which are stored
+      alongside attacker controlled data, as the compressed length can
+      reveal them.
+
+    -blind-index
+      Maintain an index of keyed hashes of the key names and values of
+      every secret, so they can be found with keyring search. Enabling
+      it indexes every stored secret, while disabling it removes the
+      index. The index reveals which secrets share a key name or value.
+
+    -format=<string>
+      The output format: table, json or yaml. Defaults to table,
+      or the '%s' environment variable when set.
+
+    -root-token=<string>
+      The root token to access the keyring.
+      If not provided here, the '%s' environment
+      variable will be used.
+
+  Backend Options:
+
+    -backend-type=<string>
+      The type of backend to use.
+      Currently, only the 'file' type backend is supported,
+      and is also the default.
+
+    File Backend Options:
+
+      -filepath=<string>
+        The file path where your secrets will be persisted to disc.
+`
+	return fmt.Sprintf(helpText, EnvFormat, internal.DefaultEnvRootToken)
+}
+
+func (oc *OperatorConfigureCommand) Run(args []string) int {
+
+	defaultCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+
+	defer cancel()
+
+	var padding, compression, compressionExclude, format string
+	var compressionThreshold int
+	var blindIndex bool
+
+	fs := flag.NewFlagSet("operator configure", flag.ContinueOnError)
+	fs.StringVar(&padding, "padding", "", "The scheme values are padded with")
+	fs.StringVar(&compression, "compression", "", "The scheme values are compressed with")
+	fs.IntVar(&compressionThreshold, "compression-threshold", 0, "The size of the smallest value to compress")
+	fs.StringVar(&compressionExclude, "compression-exclude", "", "Path prefixes which are never compressed")
+	fs.BoolVar(&blindIndex, "blind-index", false, "Maintain a blind index of key names and values")
+	formatFlag(fs, &format)
+
+	config, err := internal.ReadFlagSetConfig(fs, args)
+
+	if err != nil {
+		oc.ui.Error(fmt.Sprintf("not able to read config: %s", err.Error()))
+		return 1
+	}
+
+	if err := validateFormat(format); err != nil {
+		oc.ui.Error(err.Error())
+		return 1
+	}
+
+	barrier, cleanup, ok := unlockBarrier(defaultCtx, oc.ui, config)
+
+	if !ok {
+		return 1
+	}
+
+	defer cleanup()
+
+	barrierConfig, err := barrier.Config()
+
+	if err != nil {
+		oc.ui.Error(fmt.Sprintf("failed to read storage options: %s", err.Error()))
+		return 1
+	}
+
+	changed := false
+
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "padding":
+			barrierConfig.Padding = padding
+			changed = true
+		case "compression":
+			barrierConfig.Compression = compression
+			changed = true
+		case "compression-threshold":
+			barrierConfig.CompressionThreshold = compressionThreshold
+			changed = true
+		case "compression-exclude":
+			barrierConfig.CompressionExclude = splitPrefixes(compressionExclude)
+			changed = true
+		case "blind-index":
+			barrierConfig.BlindIndex = blindIndex
+			changed = true
+		}
+	})
+
+	if changed {
+		if err := barrier.Configure(defaultCtx, barrierConfig); err != nil {
+			oc.ui.Error(fmt.Sprintf("failed to change storage options: %s", err.Error()))
+			return 1
+		}
+	}
+
+	output := newBarrierConfigOutput(barrierConfig)
+
+	err = outputFormatted(oc.ui, format, output, func() {
+		oc.ui.Output("key\t\t\tValue")
+		oc.ui.Output("---\t\t\t-----")
+		oc.ui.Output(fmt.Sprintf("confidential_names\t%t", output.ConfidentialNames))
+		oc.ui.Output(fmt.Sprintf("padding\t\t\t%s", output.Padding))
+		oc.ui.Output(fmt.Sprintf("compression\t\t%s", output.Compression))
+		oc.ui.Output(fmt.Sprintf("compression_threshold\t%d", output.CompressionThreshold))
+		oc.ui.Output(fmt.Sprintf("compression_exclude\t%s", strings.Join(output.CompressionExclude, ",")))
+		oc.ui.Output(fmt.Sprintf("blind_index\t\t%t", output.BlindIndex))
+	})
+
+	if err != nil {
+		oc.ui.Error(err.Error())
+		return 1
+	}
+
+	return 0
+}
+
+// barrierConfigOutput is the json and yaml schema of keyring operator
+// configure.
+type barrierConfigOutput struct {
+	ConfidentialNames    bool     `json:"confidential_names"`
+	Padding              string   `json:"padding"`
+	Compression          string   `json:"compression"`
+	CompressionThreshold int      `json:"compression_threshold"`
+	CompressionExclude   []string `json:"compression_exclude"`
+	BlindIndex           bool     `json:"blind_index"`
+}
+
+func newBarrierConfigOutput(config *internal.BarrierConfig) *barrierConfigOutput {
+
+	output := &barrierConfigOutput{
+		ConfidentialNames:    config.ConfidentialNames,
+		Padding:              config.Padding,
+		Compression:          config.Compression,
+		CompressionThreshold: config.CompressionThreshold,
+		CompressionExclude:   config.CompressionExclude,
+		BlindIndex:           config.BlindIndex,
+	}
+
+	if output.Padding == "" {
+		output.Padding = internal.PaddingNone
+	}
+
+	if output.Compression == "" {
+		output.Compression = internal.CompressionNone
+	}
+
+	if output.CompressionThreshold == 0 {
+		output.CompressionThreshold = internal.DefaultCompressionThreshold
+	}
+
+	if output.CompressionExclude == nil {
+		output.CompressionExclude = []string{}
+	}
+
+	return output
+}
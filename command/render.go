@@ -0,0 +1,373 @@
+package command
+
+import (
+	"context"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/cli"
+	"github.com/woodrufj4/keyring-practice/internal"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	RenderFormatK8sSecret = "k8s-secret"
+	RenderFormatDotenv    = "dotenv"
+	RenderFormatDockerEnv = "docker-env"
+)
+
+// k8sSecretKeyRegex matches the keys kubernetes allows in secret data.
+var k8sSecretKeyRegex = regexp.MustCompile(`^[-._a-zA-Z0-9]+$`)
+
+type RenderCommand struct {
+	ui cli.Ui
+}
+
+func (rc RenderCommand) Synopsis() string {
+	return "Renders the key-value pairs at paths for deployment tooling"
+}
+
+func (rc RenderCommand) Help() string {
+	helpText := `
+Usage: keying render [options] -format=<format> <path>...
+
+  Renders the key-value pairs at the paths to stdout, in a format consumed
+  by deployment tooling. The key-value pairs of every path are merged.
+  Keys which render the same, such as the same key at two paths, are
+  refused rather than replaced.
+
+  The following formats are supported:
+
+    k8s-secret
+      A kubernetes Secret manifest, with the values base64 encoded
+      in data. Requires -name.
+
+    dotenv
+      KEY="value" lines, with quotes, backslashes and new lines escaped.
+
+    docker-env
+      KEY=value lines, as read by docker run --env-file. Values can not
+      contain new lines.
+
+  String values are rendered as is, while any other value is rendered as
+  json. For the dotenv and docker-env formats, any character of a key
+  other than letters, digits and underscores is replaced by an underscore.
+
+  Example:
+
+    $ keyring render -format=k8s-secret -name=app -namespace=prod secret/app
+
+    $ keyring render -format=docker-env -upper-case -prefix=APP_ secret/app
+
+  Options:
+
+    -format=<string>
+      The format to render: k8s-secret, dotenv or docker-env. Required.
+
+    -prefix=<string>
+      A prefix added to every key.
+
+    -upper-case
+      Upper-case every key, after adding the prefix.
+
+    -name=<string>
+      The name of the kubernetes Secret.
+
+    -namespace=<string>
+      The namespace of the kubernetes Secret.
+
+    -label=<key=value>
+      A label to add to the kubernetes Secret. This can be specified
+      multiple times.
+
+    -root-token=<string>
+      The root token to access the keyring.
+      If not provided here, the '%s' environment
+      variable will be used.
+
+  Backend Options:
+
+    -backend-type=<string>
+      The type of backend to use.
+      Currently, only the 'file' type backend is supported,
+      and is also the default.
+
+    File Backend Options:
+
+      -filepath=<string>
+        The file path where your secrets will be persisted to disc.
+`
+	return fmt.Sprintf(helpText, internal.DefaultEnvRootToken)
+}
+
+func (rc *RenderCommand) Run(args []string) int {
+
+	defaultCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+
+	defer cancel()
+
+	var format, prefix, name, namespace string
+	var upperCase bool
+
+	labels := make(mapFlag)
+
+	fs := flag.NewFlagSet("render", flag.ContinueOnError)
+	fs.StringVar(&format, "format", "", "The format to render")
+	fs.StringVar(&prefix, "prefix", "", "A prefix added to every key")
+	fs.BoolVar(&upperCase, "upper-case", false, "Upper-case every key")
+	fs.StringVar(&name, "name", "", "The name of the kubernetes Secret")
+	fs.StringVar(&namespace, "namespace", "", "The namespace of the kubernetes Secret")
+	fs.Var(labels, "label", "A label to add to the kubernetes Secret")
+
+	config, err := internal.ReadFlagSetConfig(fs, args)
+
+	if err != nil {
+		rc.ui.Error(fmt.Sprintf("not able to read config: %s", err.Error()))
+		return 1
+	}
+
+	switch format {
+
+	case RenderFormatK8sSecret:
+
+		if name == "" {
+			rc.ui.Error("-name is required for the k8s-secret format")
+			return 1
+		}
+
+	case RenderFormatDotenv, RenderFormatDockerEnv:
+
+		if name != "" || namespace != "" || len(labels) > 0 {
+			rc.ui.Error("-name, -namespace and -label are only supported for the k8s-secret format")
+			return 1
+		}
+
+	case "":
+		rc.ui.Error("missing format")
+		return 1
+
+	default:
+		rc.ui.Error(fmt.Sprintf("unsupported render format '%s'", format))
+		return 1
+	}
+
+	paths := fs.Args()
+
+	if len(paths) == 0 {
+		rc.ui.Error("missing path")
+		return 1
+	}
+
+	for _, path := range paths {
+		if strings.HasPrefix(path, internal.KeyringPrefix) {
+			rc.ui.Warn(fmt.Sprintf("paths prefixed with %s are restricted", internal.KeyringPrefix))
+			return 1
+		}
+	}
+
+	barrier, cleanup, ok := unlockBarrier(defaultCtx, rc.ui, config)
+
+	if !ok {
+		return 1
+	}
+
+	defer cleanup()
+
+	kvStore, err := internal.NewKV(barrier)
+
+	if err != nil {
+		rc.ui.Error(fmt.Sprintf("failed to instantiate kv store: %s", err.Error()))
+		return 1
+	}
+
+	values := make(map[string]string)
+
+	// The key and path each rendered key was read from, so keys which
+	// render the same are refused rather than replaced.
+	sources := make(map[string]string)
+
+	for _, path := range paths {
+
+		_, data, err := readKVData(defaultCtx, kvStore, path)
+
+		if err != nil {
+			rc.ui.Error(fmt.Sprintf("failed to retrieve secrets at path '%s': %s", path, err.Error()))
+			return 1
+		}
+
+		for key, value := range data {
+
+			raw, err := rawFieldValue(value)
+
+			if err != nil {
+				rc.ui.Error(fmt.Sprintf("failed to format field '%s': %s", key, err.Error()))
+				return 1
+			}
+
+			name := prefix + key
+
+			if upperCase {
+				name = strings.ToUpper(name)
+			}
+
+			source := fmt.Sprintf("'%s' at %s", key, path)
+
+			if other, ok := sources[name]; ok {
+				rc.ui.Error(fmt.Sprintf("failed to render %s: keys %s and %s both render as %s", format, other, source, name))
+				return 1
+			}
+
+			sources[name] = source
+			values[name] = raw
+		}
+	}
+
+	var rendered string
+
+	switch format {
+
+	case RenderFormatK8sSecret:
+		rendered, err = renderK8sSecret(values, name, namespace, labels)
+
+	case RenderFormatDotenv:
+		rendered, err = renderDotenv(values)
+
+	case RenderFormatDockerEnv:
+		rendered, err = renderDockerEnv(values)
+	}
+
+	if err != nil {
+		rc.ui.Error(fmt.Sprintf("failed to render %s: %s", format, err.Error()))
+		return 1
+	}
+
+	rc.ui.Output(rendered)
+
+	return 0
+}
+
+// k8sSecret is the manifest of a kubernetes Secret.
+type k8sSecret struct {
+	APIVersion string            `yaml:"apiVersion"`
+	Kind       string            `yaml:"kind"`
+	Metadata   k8sSecretMetadata `yaml:"metadata"`
+	Type       string            `yaml:"type"`
+	Data       map[string]string `yaml:"data"`
+}
+
+type k8sSecretMetadata struct {
+	Name      string            `yaml:"name"`
+	Namespace string            `yaml:"namespace,omitempty"`
+	Labels    map[string]string `yaml:"labels,omitempty"`
+}
+
+func renderK8sSecret(values map[string]string, name string, namespace string, labels map[string]string) (string, error) {
+
+	secret := &k8sSecret{
+		APIVersion: "v1",
+		Kind:       "Secret",
+		Metadata: k8sSecretMetadata{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    labels,
+		},
+		Type: "Opaque",
+		Data: make(map[string]string, len(values)),
+	}
+
+	for key, value := range values {
+
+		if !k8sSecretKeyRegex.MatchString(key) {
+			return "", fmt.Errorf("key '%s' is not a valid kubernetes secret key", key)
+		}
+
+		secret.Data[key] = base64.StdEncoding.EncodeToString([]byte(value))
+	}
+
+	var out strings.Builder
+
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+
+	if err := encoder.Encode(secret); err != nil {
+		return "", err
+	}
+
+	if err := encoder.Close(); err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(out.String(), "\n"), nil
+}
+
+func renderDotenv(values map[string]string) (string, error) {
+
+	escaper := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`)
+
+	keys, err := sortedEnvKeys(values)
+
+	if err != nil {
+		return "", err
+	}
+
+	lines := make([]string, 0, len(keys))
+
+	for _, key := range keys {
+		lines = append(lines, fmt.Sprintf(`%s="%s"`, sanitizeEnvName(key), escaper.Replace(values[key])))
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+func renderDockerEnv(values map[string]string) (string, error) {
+
+	keys, err := sortedEnvKeys(values)
+
+	if err != nil {
+		return "", err
+	}
+
+	lines := make([]string, 0, len(keys))
+
+	for _, key := range keys {
+
+		value := values[key]
+
+		if strings.ContainsAny(value, "\r\n") {
+			return "", fmt.Errorf("value of key '%s' contains a new line", key)
+		}
+
+		lines = append(lines, sanitizeEnvName(key)+"="+value)
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+// sortedEnvKeys orders the keys, refusing keys that collide once sanitized
+// into environment variable names.
+func sortedEnvKeys(values map[string]string) ([]string, error) {
+
+	keys := make([]string, 0, len(values))
+	sanitized := make(map[string]string, len(values))
+
+	for key := range values {
+
+		name := sanitizeEnvName(key)
+
+		if other, ok := sanitized[name]; ok {
+			return nil, fmt.Errorf("keys '%s' and '%s' both render as %s", other, key, name)
+		}
+
+		sanitized[name] = key
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys, nil
+}
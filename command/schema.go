@@ -0,0 +1,531 @@
+package command
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/cli"
+	"github.com/woodrufj4/keyring-practice/internal"
+)
+
+type SchemaCommand struct {
+	ui cli.Ui
+}
+
+func (sc SchemaCommand) Synopsis() string {
+	return "Interacts with the schemas secrets are validated against"
+}
+
+func (sc SchemaCommand) Help() string {
+	helpText := `
+Usage: keying schema [options] <subcommand>
+
+  Binds JSON schemas to path prefixes. Every new version written to a
+  path, such as with keyring put, patch, unset, generate, import,
+  rollback, copy or move, is validated against the schema of the longest
+  prefix of the path, as a JSON object of the keys and their values.
+  Writes which do not match are refused.
+
+  Please see individual subcommand help for detailed usage information.`
+	return helpText
+}
+
+func (sc SchemaCommand) Run(args []string) int {
+	return cli.RunResultHelp
+}
+
+type SchemaPutCommand struct {
+	ui cli.Ui
+}
+
+func (sc SchemaPutCommand) Synopsis() string {
+	return "Binds a JSON schema to a path prefix"
+}
+
+func (sc SchemaPutCommand) Help() string {
+	helpText := `
+Usage: keying schema put [options] <prefix> <file>
+
+  Binds the JSON schema within the file to the path prefix, replacing any
+  existing schema of the prefix. If the file is "-", the schema is read
+  from stdin. Existing secrets are not validated.
+
+  The prefix is treated as a folder, so a schema bound to secret/db
+  applies to secret/db and the paths within it, but not to secret/db2.
+
+  Example:
+
+    $ keyring schema put secret/db/ db.schema.json
+
+  With db.schema.json holding:
+
+    {
+      "type": "object",
+      "required": ["user", "password"],
+      "properties": {
+        "user": {"type": "string"},
+        "password": {"type": "string", "minLength": 16}
+      },
+      "additionalProperties": false
+    }
+
+  Options:
+
+    -root-token=<string>
+      The root token to access the keyring.
+      If not provided here, the '%s' environment
+      variable will be used.
+
+  Backend Options:
+
+    -backend-type=<string>
+      The type of backend to use.
+      Currently, only the 'file' type backend is supported,
+      and is also the default.
+
+    File Backend Options:
+
+      -filepath=<string>
+        The file path where your secrets will be persisted to disc.
+`
+	return fmt.Sprintf(helpText, internal.DefaultEnvRootToken)
+}
+
+func (sc *SchemaPutCommand) Run(args []string) int {
+
+	defaultCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+
+	defer cancel()
+
+	fs := flag.NewFlagSet("schema put", flag.ContinueOnError)
+
+	config, err := internal.ReadFlagSetConfig(fs, args)
+
+	if err != nil {
+		sc.ui.Error(fmt.Sprintf("not able to read config: %s", err.Error()))
+		return 1
+	}
+
+	prefix, file := fs.Arg(0), fs.Arg(1)
+
+	if prefix == "" || file == "" {
+		sc.ui.Error("missing prefix or schema file")
+		return 1
+	}
+
+	prefix = kvFolder(prefix)
+
+	if strings.HasPrefix(prefix, internal.KeyringPrefix) {
+		sc.ui.Warn(fmt.Sprintf("paths prefixed with %s are restricted", internal.KeyringPrefix))
+		return 1
+	}
+
+	var schema []byte
+
+	if file == "-" {
+		schema, err = io.ReadAll(os.Stdin)
+	} else {
+		schema, err = os.ReadFile(file)
+	}
+
+	if err != nil {
+		sc.ui.Error(fmt.Sprintf("failed to read schema: %s", err.Error()))
+		return 1
+	}
+
+	barrier, cleanup, ok := unlockBarrier(defaultCtx, sc.ui, config)
+
+	if !ok {
+		return 1
+	}
+
+	defer cleanup()
+
+	schemas, err := internal.NewSchemas(barrier)
+
+	if err != nil {
+		sc.ui.Error(fmt.Sprintf("failed to instantiate schemas: %s", err.Error()))
+		return 1
+	}
+
+	if err := schemas.Put(defaultCtx, prefix, schema); err != nil {
+		sc.ui.Error(fmt.Sprintf("failed to put schema for prefix '%s': %s", prefix, err.Error()))
+		return 1
+	}
+
+	sc.ui.Info(fmt.Sprintf("Success! Schema written for: %s", prefix))
+
+	return 0
+}
+
+type SchemaGetCommand struct {
+	ui cli.Ui
+}
+
+func (sc SchemaGetCommand) Synopsis() string {
+	return "Retrieves the JSON schema of a path prefix"
+}
+
+func (sc SchemaGetCommand) Help() string {
+	helpText := `
+Usage: keying schema get [options] <prefix>
+
+  Retrieves the JSON schema bound to the path prefix.
+
+  Example:
+
+    $ keyring schema get secret/db/
+
+  Options:
+
+    -format=<string>
+      The output format: table, json or yaml. Defaults to table,
+      or the '%s' environment variable when set.
+
+    -root-token=<string>
+      The root token to access the keyring.
+      If not provided here, the '%s' environment
+      variable will be used.
+
+  Backend Options:
+
+    -backend-type=<string>
+      The type of backend to use.
+      Currently, only the 'file' type backend is supported,
+      and is also the default.
+
+    File Backend Options:
+
+      -filepath=<string>
+        The file path where your secrets will be persisted to disc.
+`
+	return fmt.Sprintf(helpText, EnvFormat, internal.DefaultEnvRootToken)
+}
+
+func (sc *SchemaGetCommand) Run(args []string) int {
+
+	defaultCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+
+	defer cancel()
+
+	var format string
+
+	fs := flag.NewFlagSet("schema get", flag.ContinueOnError)
+	formatFlag(fs, &format)
+
+	config, err := internal.ReadFlagSetConfig(fs, args)
+
+	if err != nil {
+		sc.ui.Error(fmt.Sprintf("not able to read config: %s", err.Error()))
+		return 1
+	}
+
+	if err := validateFormat(format); err != nil {
+		sc.ui.Error(err.Error())
+		return 1
+	}
+
+	prefix := fs.Arg(0)
+
+	if prefix == "" {
+		sc.ui.Error("missing prefix")
+		return 1
+	}
+
+	prefix = kvFolder(prefix)
+
+	barrier, cleanup, ok := unlockBarrier(defaultCtx, sc.ui, config)
+
+	if !ok {
+		return 1
+	}
+
+	defer cleanup()
+
+	schemas, err := internal.NewSchemas(barrier)
+
+	if err != nil {
+		sc.ui.Error(fmt.Sprintf("failed to instantiate schemas: %s", err.Error()))
+		return 1
+	}
+
+	schema, err := schemas.Get(defaultCtx, prefix)
+
+	if errors.Is(err, internal.ErrSchemaNotFound) {
+		sc.ui.Error(fmt.Sprintf("No schema found for %s", prefix))
+		return 1
+	}
+
+	if err != nil {
+		sc.ui.Error(fmt.Sprintf("failed to retrieve schema for prefix '%s': %s", prefix, err.Error()))
+		return 1
+	}
+
+	output := &schemaOutput{
+		Prefix: prefix,
+		Schema: json.RawMessage(schema),
+	}
+
+	err = outputFormatted(sc.ui, format, output, func() {
+
+		var indented bytes.Buffer
+
+		if err := json.Indent(&indented, schema, "", "  "); err != nil {
+			sc.ui.Output(string(schema))
+			return
+		}
+
+		sc.ui.Output(indented.String())
+	})
+
+	if err != nil {
+		sc.ui.Error(err.Error())
+		return 1
+	}
+
+	return 0
+}
+
+// schemaOutput is the json and yaml schema of keyring schema get.
+type schemaOutput struct {
+	Prefix string          `json:"prefix"`
+	Schema json.RawMessage `json:"schema"`
+}
+
+type SchemaListCommand struct {
+	ui cli.Ui
+}
+
+func (sc SchemaListCommand) Synopsis() string {
+	return "Lists the path prefixes with a JSON schema"
+}
+
+func (sc SchemaListCommand) Help() string {
+	helpText := `
+Usage: keying schema list [options]
+
+  Lists the path prefixes with a JSON schema.
+
+  Example:
+
+    $ keyring schema list
+
+  Options:
+
+    -format=<string>
+      The output format: table, json or yaml. Defaults to table,
+      or the '%s' environment variable when set.
+
+    -root-token=<string>
+      The root token to access the keyring.
+      If not provided here, the '%s' environment
+      variable will be used.
+
+  Backend Options:
+
+    -backend-type=<string>
+      The type of backend to use.
+      Currently, only the 'file' type backend is supported,
+      and is also the default.
+
+    File Backend Options:
+
+      -filepath=<string>
+        The file path where your secrets will be persisted to disc.
+`
+	return fmt.Sprintf(helpText, EnvFormat, internal.DefaultEnvRootToken)
+}
+
+func (sc *SchemaListCommand) Run(args []string) int {
+
+	defaultCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+
+	defer cancel()
+
+	var format string
+
+	fs := flag.NewFlagSet("schema list", flag.ContinueOnError)
+	formatFlag(fs, &format)
+
+	config, err := internal.ReadFlagSetConfig(fs, args)
+
+	if err != nil {
+		sc.ui.Error(fmt.Sprintf("not able to read config: %s", err.Error()))
+		return 1
+	}
+
+	if err := validateFormat(format); err != nil {
+		sc.ui.Error(err.Error())
+		return 1
+	}
+
+	barrier, cleanup, ok := unlockBarrier(defaultCtx, sc.ui, config)
+
+	if !ok {
+		return 1
+	}
+
+	defer cleanup()
+
+	schemas, err := internal.NewSchemas(barrier)
+
+	if err != nil {
+		sc.ui.Error(fmt.Sprintf("failed to instantiate schemas: %s", err.Error()))
+		return 1
+	}
+
+	prefixes, err := schemas.List(defaultCtx)
+
+	if err != nil {
+		sc.ui.Error(fmt.Sprintf("failed to list schemas: %s", err.Error()))
+		return 1
+	}
+
+	output := &schemaListOutput{
+		Prefixes: prefixes,
+	}
+
+	err = outputFormatted(sc.ui, format, output, func() {
+		for _, prefix := range prefixes {
+			sc.ui.Output(prefix)
+		}
+	})
+
+	if err != nil {
+		sc.ui.Error(err.Error())
+		return 1
+	}
+
+	return 0
+}
+
+// schemaListOutput is the json and yaml schema of keyring schema list.
+type schemaListOutput struct {
+	Prefixes []string `json:"prefixes"`
+}
+
+type SchemaDeleteCommand struct {
+	ui cli.Ui
+}
+
+func (sc SchemaDeleteCommand) Synopsis() string {
+	return "Removes the JSON schema of a path prefix"
+}
+
+func (sc SchemaDeleteCommand) Help() string {
+	helpText := `
+Usage: keying schema delete [options] <prefix>
+
+  Removes the JSON schema bound to the path prefix. Writes to the prefix
+  are then validated against the schema of the next longest prefix, if
+  there is one.
+
+  Example:
+
+    $ keyring schema delete secret/db/
+
+  Options:
+
+    -root-token=<string>
+      The root token to access the keyring.
+      If not provided here, the '%s' environment
+      variable will be used.
+
+  Backend Options:
+
+    -backend-type=<string>
+      The type of backend to use.
+      Currently, only the 'file' type backend is supported,
+      and is also the default.
+
+    File Backend Options:
+
+      -filepath=<string>
+        The file path where your secrets will be persisted to disc.
+`
+	return fmt.Sprintf(helpText, internal.DefaultEnvRootToken)
+}
+
+func (sc *SchemaDeleteCommand) Run(args []string) int {
+
+	defaultCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+
+	defer cancel()
+
+	fs := flag.NewFlagSet("schema delete", flag.ContinueOnError)
+
+	config, err := internal.ReadFlagSetConfig(fs, args)
+
+	if err != nil {
+		sc.ui.Error(fmt.Sprintf("not able to read config: %s", err.Error()))
+		return 1
+	}
+
+	prefix := fs.Arg(0)
+
+	if prefix == "" {
+		sc.ui.Error("missing prefix")
+		return 1
+	}
+
+	prefix = kvFolder(prefix)
+
+	barrier, cleanup, ok := unlockBarrier(defaultCtx, sc.ui, config)
+
+	if !ok {
+		return 1
+	}
+
+	defer cleanup()
+
+	schemas, err := internal.NewSchemas(barrier)
+
+	if err != nil {
+		sc.ui.Error(fmt.Sprintf("failed to instantiate schemas: %s", err.Error()))
+		return 1
+	}
+
+	err = schemas.Delete(defaultCtx, prefix)
+
+	if errors.Is(err, internal.ErrSchemaNotFound) {
+		sc.ui.Error(fmt.Sprintf("No schema found for %s", prefix))
+		return 1
+	}
+
+	if err != nil {
+		sc.ui.Error(fmt.Sprintf("failed to delete schema for prefix '%s': %s", prefix, err.Error()))
+		return 1
+	}
+
+	sc.ui.Info(fmt.Sprintf("Success! Schema deleted for: %s", prefix))
+
+	return 0
+}
+
+// reportSchemaError reports every violation when the write was refused
+// because the key-value pairs do not match the schema of the path. This
+// reports false for any other error.
+func reportSchemaError(ui cli.Ui, err error) bool {
+
+	var invalid *internal.SchemaValidationError
+
+	if !errors.As(err, &invalid) {
+		return false
+	}
+
+	ui.Error(fmt.Sprintf("refusing to write to path '%s': the key-value pairs do not match the schema for '%s'", invalid.Path, invalid.Prefix))
+
+	for _, violation := range invalid.Violations {
+		ui.Error(fmt.Sprintf("  %s: %s", violation.Location, violation.Message))
+	}
+
+	return true
+}
@@ -0,0 +1,172 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/cli"
+	"github.com/woodrufj4/keyring-practice/internal"
+)
+
+type SearchCommand struct {
+	ui cli.Ui
+}
+
+func (sc SearchCommand) Synopsis() string {
+	return "Finds the paths holding a key or a value"
+}
+
+func (sc SearchCommand) Help() string {
+	helpText := `
+Usage: keying search [options]
+
+  Finds the paths holding an entry with the key name, the value, or both,
+  without decrypting the stored secrets. Every retained version is
+  searched, so paths which held the value in an earlier version are
+  also reported.
+
+  Searching requires the blind index, which is enabled with
+  keyring operator configure -blind-index.
+
+  Example:
+
+    $ keyring search -value=sk_live_51H
+
+    $ keyring search -key=password
+
+    $ pbpaste | keyring search -value=-
+
+  Options:
+
+    -key=<string>
+      Only report paths holding an entry with the key name.
+
+    -value=<string>
+      Only report paths holding an entry with the value. If the value
+      is "-", it is read from stdin, keeping it out of the shell history.
+
+    -format=<string>
+      The output format: table, json or yaml. Defaults to table,
+      or the '%s' environment variable when set.
+
+    -root-token=<string>
+      The root token to access the keyring.
+      If not provided here, the '%s' environment
+      variable will be used.
+
+  Backend Options:
+
+    -backend-type=<string>
+      The type of backend to use.
+      Currently, only the 'file' type backend is supported,
+      and is also the default.
+
+    File Backend Options:
+
+      -filepath=<string>
+        The file path where your secrets will be persisted to disc.
+`
+	return fmt.Sprintf(helpText, EnvFormat, internal.DefaultEnvRootToken)
+}
+
+func (sc *SearchCommand) Run(args []string) int {
+
+	defaultCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+
+	defer cancel()
+
+	var key, value, format string
+
+	fs := flag.NewFlagSet("search", flag.ContinueOnError)
+	fs.StringVar(&key, "key", "", "Only report paths holding the key name")
+	fs.StringVar(&value, "value", "", "Only report paths holding the value")
+	formatFlag(fs, &format)
+
+	config, err := internal.ReadFlagSetConfig(fs, args)
+
+	if err != nil {
+		sc.ui.Error(fmt.Sprintf("not able to read config: %s", err.Error()))
+		return 1
+	}
+
+	if err := validateFormat(format); err != nil {
+		sc.ui.Error(err.Error())
+		return 1
+	}
+
+	query := &internal.SearchQuery{}
+
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "key":
+			query.Key = &key
+		case "value":
+			query.Value = &value
+		}
+	})
+
+	if query.Key == nil && query.Value == nil {
+		sc.ui.Error("a -key or -value to search for is required")
+		return 1
+	}
+
+	if value == "-" {
+
+		stdinValue, err := io.ReadAll(os.Stdin)
+
+		if err != nil {
+			sc.ui.Error(fmt.Sprintf("failed to read value from stdin: %s", err.Error()))
+			return 1
+		}
+
+		value = strings.TrimRight(string(stdinValue), "\r\n")
+	}
+
+	barrier, cleanup, ok := unlockBarrier(defaultCtx, sc.ui, config)
+
+	if !ok {
+		return 1
+	}
+
+	defer cleanup()
+
+	paths, err := barrier.Search(defaultCtx, query)
+
+	if errors.Is(err, internal.ErrBlindIndexDisabled) {
+		sc.ui.Error("searching requires the blind index. Enable it with keyring operator configure -blind-index")
+		return 1
+	}
+
+	if err != nil {
+		sc.ui.Error(fmt.Sprintf("failed to search: %s", err.Error()))
+		return 1
+	}
+
+	output := &searchOutput{
+		Paths: paths,
+	}
+
+	err = outputFormatted(sc.ui, format, output, func() {
+		for _, path := range paths {
+			sc.ui.Output(path)
+		}
+	})
+
+	if err != nil {
+		sc.ui.Error(err.Error())
+		return 1
+	}
+
+	return 0
+}
+
+// searchOutput is the json and yaml schema of keyring search.
+type searchOutput struct {
+	Paths []string `json:"paths"`
+}
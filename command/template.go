@@ -0,0 +1,386 @@
+package command
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/template"
+	"time"
+
+	"github.com/mitchellh/cli"
+	"github.com/woodrufj4/keyring-practice/internal"
+)
+
+type TemplateCommand struct {
+	ui cli.Ui
+}
+
+func (tc TemplateCommand) Synopsis() string {
+	return "Renders a file from a template of stored secrets"
+}
+
+func (tc TemplateCommand) Help() string {
+	helpText := `
+Usage: keying template [options] -in=<file> -out=<file>
+
+  Renders the template to the output file, using Go's text/template
+  syntax. The output file is replaced atomically, so readers never
+  observe a partially written file.
+
+  The following functions are available to the template:
+
+    secret "<path>" "<key>"
+      The value of the key at the path. String values are rendered
+      as is, while any other value is rendered as json.
+
+    secrets "<path>"
+      All the key-value pairs at the path, for use with range.
+
+  Example:
+
+    $ keyring template -in=app.tmpl -out=app.conf -perms=0600
+
+  With a template such as:
+
+    password = {{ secret "secret/app" "password" }}
+    {{ range $key, $value := secrets "secret/env" }}{{ $key }}={{ $value }}
+    {{ end }}
+
+  Options:
+
+    -in=<string>
+      The template file to render. Required.
+
+    -out=<string>
+      The file to write the output to. By default, the output is
+      written to stdout.
+
+    -perms=<octal>
+      The permissions of the output file. Defaults to 0600.
+
+    -watch
+      Keep running until interrupted, re-rendering the output whenever
+      the template or any of the paths it reads change. Requires -out.
+
+    -interval=<duration>
+      How often -watch checks for changes. Defaults to 5s.
+
+    -root-token=<string>
+      The root token to access the keyring.
+      If not provided here, the '%s' environment
+      variable will be used.
+
+  Backend Options:
+
+    -backend-type=<string>
+      The type of backend to use.
+      Currently, only the 'file' type backend is supported,
+      and is also the default.
+
+    File Backend Options:
+
+      -filepath=<string>
+        The file path where your secrets will be persisted to disc.
+`
+	return fmt.Sprintf(helpText, internal.DefaultEnvRootToken)
+}
+
+func (tc *TemplateCommand) Run(args []string) int {
+
+	var in, out, rawPerms, rawInterval string
+	var watch bool
+
+	fs := flag.NewFlagSet("template", flag.ContinueOnError)
+	fs.StringVar(&in, "in", "", "The template file to render")
+	fs.StringVar(&out, "out", "", "The file to write the output to")
+	fs.StringVar(&rawPerms, "perms", "0600", "The permissions of the output file")
+	fs.BoolVar(&watch, "watch", false, "Re-render the output when the template or paths change")
+	fs.StringVar(&rawInterval, "interval", "5s", "How often -watch checks for changes")
+
+	config, err := internal.ReadFlagSetConfig(fs, args)
+
+	if err != nil {
+		tc.ui.Error(fmt.Sprintf("not able to read config: %s", err.Error()))
+		return 1
+	}
+
+	if in == "" {
+		tc.ui.Error("missing template file")
+		return 1
+	}
+
+	perms, err := strconv.ParseUint(rawPerms, 8, 32)
+
+	if err != nil || perms > 0777 {
+		tc.ui.Error(fmt.Sprintf("invalid perms '%s'", rawPerms))
+		return 1
+	}
+
+	interval, err := parseDuration(rawInterval)
+
+	if err != nil {
+		tc.ui.Error(fmt.Sprintf("not able to parse interval: %s", err.Error()))
+		return 1
+	}
+
+	if watch && out == "" {
+		tc.ui.Error("-watch requires -out")
+		return 1
+	}
+
+	if watch && interval <= 0 {
+		tc.ui.Error("interval must be positive")
+		return 1
+	}
+
+	renderer := &templateRenderer{
+		ui:     tc.ui,
+		config: config,
+		in:     in,
+		out:    out,
+		perms:  os.FileMode(perms),
+	}
+
+	if !renderer.render() {
+		return 1
+	}
+
+	if !watch {
+		return 0
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	tc.ui.Info(fmt.Sprintf("Watching %s for changes every %s", in, interval))
+
+	for {
+
+		select {
+
+		case <-ticker.C:
+
+			// Failures are reported, but do not stop watching,
+			// so the next interval can retry.
+			if renderer.changed() {
+				renderer.render()
+			}
+
+		case sig := <-signals:
+			tc.ui.Info(fmt.Sprintf("Received %s, no longer watching %s", sig, in))
+			return 0
+		}
+	}
+}
+
+// templateRenderer renders a template, tracking the paths it reads so
+// changes can be detected.
+type templateRenderer struct {
+	ui     cli.Ui
+	config *internal.GeneralConfig
+	in     string
+	out    string
+	perms  os.FileMode
+
+	// modTime is the modification time of the template when last rendered.
+	modTime time.Time
+
+	// sources are the versions of the paths read by the last render.
+	sources map[string]*templateSource
+
+	// rendered reports if the last render succeeded.
+	rendered bool
+}
+
+// templateSource is the version of a path read by the template.
+type templateSource struct {
+	version     uint64
+	updatedTime time.Time
+}
+
+// render renders the template and writes the output, reporting any
+// failure to the ui.
+func (tr *templateRenderer) render() bool {
+
+	defaultCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+
+	defer cancel()
+
+	tr.rendered = false
+
+	info, err := os.Stat(tr.in)
+
+	if err != nil {
+		tr.ui.Error(fmt.Sprintf("failed to read template: %s", err.Error()))
+		return false
+	}
+
+	barrier, cleanup, ok := unlockBarrier(defaultCtx, tr.ui, tr.config)
+
+	if !ok {
+		return false
+	}
+
+	defer cleanup()
+
+	kvStore, err := internal.NewKV(barrier)
+
+	if err != nil {
+		tr.ui.Error(fmt.Sprintf("failed to instantiate kv store: %s", err.Error()))
+		return false
+	}
+
+	sources := make(map[string]*templateSource)
+	cache := make(map[string]map[string]interface{})
+
+	secrets := func(path string) (map[string]interface{}, error) {
+
+		if strings.HasPrefix(path, internal.KeyringPrefix) {
+			return nil, fmt.Errorf("paths prefixed with %s are restricted", internal.KeyringPrefix)
+		}
+
+		if data, ok := cache[path]; ok {
+			return data, nil
+		}
+
+		secret, data, err := readKVData(defaultCtx, kvStore, path)
+
+		// Deleted versions are tracked too, so undeleting them
+		// re-renders the output.
+		if secret != nil {
+			sources[path] = &templateSource{
+				version:     secret.Version,
+				updatedTime: secret.PathMetadata.UpdatedTime,
+			}
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		cache[path] = data
+
+		return data, nil
+	}
+
+	funcs := template.FuncMap{
+		"secrets": secrets,
+		"secret": func(path string, key string) (string, error) {
+
+			data, err := secrets(path)
+
+			if err != nil {
+				return "", err
+			}
+
+			value, ok := data[key]
+
+			if !ok {
+				return "", fmt.Errorf("field '%s' not present at %s", key, path)
+			}
+
+			return rawFieldValue(value)
+		},
+	}
+
+	tmpl, err := template.New(filepath.Base(tr.in)).Option("missingkey=error").Funcs(funcs).ParseFiles(tr.in)
+
+	if err != nil {
+		tr.ui.Error(fmt.Sprintf("failed to parse template: %s", err.Error()))
+		return false
+	}
+
+	var rendered bytes.Buffer
+
+	if err := tmpl.Execute(&rendered, nil); err != nil {
+		tr.ui.Error(fmt.Sprintf("failed to render template: %s", err.Error()))
+		return false
+	}
+
+	tr.modTime = info.ModTime()
+	tr.sources = sources
+
+	if tr.out == "" {
+		os.Stdout.Write(rendered.Bytes())
+		tr.rendered = true
+		return true
+	}
+
+	if err := writeFileAtomic(tr.out, rendered.Bytes(), tr.perms); err != nil {
+		tr.ui.Error(fmt.Sprintf("failed to write output: %s", err.Error()))
+		return false
+	}
+
+	tr.ui.Info(fmt.Sprintf("Success! Rendered %s to %s", tr.in, tr.out))
+
+	tr.rendered = true
+
+	return true
+}
+
+// changed reports if the template, or any of the paths read by the last
+// render, changed since. A failed render is always retried. Failures are
+// reported to the ui and treated as unchanged.
+func (tr *templateRenderer) changed() bool {
+
+	if !tr.rendered {
+		return true
+	}
+
+	defaultCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+
+	defer cancel()
+
+	info, err := os.Stat(tr.in)
+
+	if err != nil {
+		tr.ui.Error(fmt.Sprintf("failed to read template: %s", err.Error()))
+		return false
+	}
+
+	if !info.ModTime().Equal(tr.modTime) {
+		return true
+	}
+
+	barrier, cleanup, ok := unlockBarrier(defaultCtx, tr.ui, tr.config)
+
+	if !ok {
+		return false
+	}
+
+	defer cleanup()
+
+	kvStore, err := internal.NewKV(barrier)
+
+	if err != nil {
+		tr.ui.Error(fmt.Sprintf("failed to instantiate kv store: %s", err.Error()))
+		return false
+	}
+
+	for path, source := range tr.sources {
+
+		metadata, err := kvStore.Metadata(defaultCtx, path)
+
+		if err != nil {
+			tr.ui.Error(fmt.Sprintf("failed to retrieve metadata at path '%s': %s", path, err.Error()))
+			return false
+		}
+
+		if metadata == nil || metadata.CurrentVersion != source.version || !metadata.UpdatedTime.Equal(source.updatedTime) {
+			return true
+		}
+	}
+
+	return false
+}
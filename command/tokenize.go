@@ -0,0 +1,249 @@
+package command
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/mitchellh/cli"
+	"github.com/woodrufj4/keyring-practice/internal"
+)
+
+type TokenizeCommand struct {
+	ui cli.Ui
+}
+
+func (tc TokenizeCommand) Synopsis() string {
+	return "Maps a sensitive value to an opaque token"
+}
+
+func (tc TokenizeCommand) Help() string {
+	helpText := `
+Usage: keyring tokenize [options] <role> <value>
+
+  This stores the encrypted value and returns a random token that maps
+  to it. The token can be exchanged for the value with keyring detokenize.
+
+  Example:
+
+    $ keyring tokenize -ttl=24h cards 4111-1111-1111-1111
+
+  Options:
+
+    -ttl=<duration>
+      How long the token is valid for. By default, tokens never expire.
+
+    -convergent
+      Map the same value to the same token within the role.
+
+    -format=<string>
+      The output format: table, json or yaml. Defaults to table,
+      or the '%s' environment variable when set.
+
+    -root-token=<string>
+      The root token to access the keyring.
+      If not provided here, the '%s' environment
+      variable will be used.
+
+  Backend Options:
+
+    -backend-type=<string>
+      The type of backend to use.
+      Currently, only the 'file' type backend is supported,
+      and is also the default.
+
+    File Backend Options:
+
+      -filepath=<string>
+        The file path where your secrets will be persisted to disc.
+`
+	return fmt.Sprintf(helpText, EnvFormat, internal.DefaultEnvRootToken)
+}
+
+func (tc *TokenizeCommand) Run(args []string) int {
+
+	defaultCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+
+	defer cancel()
+
+	var format string
+
+	opts := &internal.TokenizeOptions{}
+
+	fs := flag.NewFlagSet("tokenize", flag.ContinueOnError)
+	fs.DurationVar(&opts.TTL, "ttl", 0, "How long the token is valid for")
+	fs.BoolVar(&opts.Convergent, "convergent", false, "Map the same value to the same token")
+	formatFlag(fs, &format)
+
+	config, err := internal.ReadFlagSetConfig(fs, args)
+
+	if err != nil {
+		tc.ui.Error(fmt.Sprintf("not able to read config: %s", err.Error()))
+		return 1
+	}
+
+	if err := validateFormat(format); err != nil {
+		tc.ui.Error(err.Error())
+		return 1
+	}
+
+	if len(fs.Args()) != 2 {
+		tc.ui.Error("expected a role and a value")
+		return 1
+	}
+
+	role, value := fs.Arg(0), fs.Arg(1)
+
+	barrier, cleanup, ok := unlockBarrier(defaultCtx, tc.ui, config)
+
+	if !ok {
+		return 1
+	}
+
+	defer cleanup()
+
+	tokenizer, err := internal.NewTokenizer(barrier)
+
+	if err != nil {
+		tc.ui.Error(fmt.Sprintf("failed to instantiate tokenizer: %s", err.Error()))
+		return 1
+	}
+
+	token, err := tokenizer.Tokenize(defaultCtx, role, []byte(value), opts)
+
+	if err != nil {
+		tc.ui.Error(fmt.Sprintf("failed to tokenize value for role '%s': %s", role, err.Error()))
+		return 1
+	}
+
+	err = outputFormatted(tc.ui, format, &tokenizeOutput{Token: token}, func() {
+		tc.ui.Info(fmt.Sprintf("token: %s", token))
+	})
+
+	if err != nil {
+		tc.ui.Error(err.Error())
+		return 1
+	}
+
+	return 0
+}
+
+// tokenizeOutput is the json and yaml schema of keyring tokenize.
+type tokenizeOutput struct {
+	Token string `json:"token"`
+}
+
+type DetokenizeCommand struct {
+	ui cli.Ui
+}
+
+func (dc DetokenizeCommand) Synopsis() string {
+	return "Exchanges a token for the value it maps to"
+}
+
+func (dc DetokenizeCommand) Help() string {
+	helpText := `
+Usage: keyring detokenize [options] <role> <token>
+
+  This retrieves and decrypts the value a token maps to.
+  Expired tokens are removed.
+
+  Example:
+
+    $ keyring detokenize cards <token>
+
+  Options:
+
+    -format=<string>
+      The output format: table, json or yaml. Defaults to table,
+      or the '%s' environment variable when set.
+
+    -root-token=<string>
+      The root token to access the keyring.
+      If not provided here, the '%s' environment
+      variable will be used.
+
+  Backend Options:
+
+    -backend-type=<string>
+      The type of backend to use.
+      Currently, only the 'file' type backend is supported,
+      and is also the default.
+
+    File Backend Options:
+
+      -filepath=<string>
+        The file path where your secrets will be persisted to disc.
+`
+	return fmt.Sprintf(helpText, EnvFormat, internal.DefaultEnvRootToken)
+}
+
+func (dc *DetokenizeCommand) Run(args []string) int {
+
+	defaultCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+
+	defer cancel()
+
+	var format string
+
+	fs := flag.NewFlagSet("detokenize", flag.ContinueOnError)
+	formatFlag(fs, &format)
+
+	config, err := internal.ReadFlagSetConfig(fs, args)
+
+	if err != nil {
+		dc.ui.Error(fmt.Sprintf("not able to read config: %s", err.Error()))
+		return 1
+	}
+
+	if err := validateFormat(format); err != nil {
+		dc.ui.Error(err.Error())
+		return 1
+	}
+
+	if len(fs.Args()) != 2 {
+		dc.ui.Error("expected a role and a token")
+		return 1
+	}
+
+	role, token := fs.Arg(0), fs.Arg(1)
+
+	barrier, cleanup, ok := unlockBarrier(defaultCtx, dc.ui, config)
+
+	if !ok {
+		return 1
+	}
+
+	defer cleanup()
+
+	tokenizer, err := internal.NewTokenizer(barrier)
+
+	if err != nil {
+		dc.ui.Error(fmt.Sprintf("failed to instantiate tokenizer: %s", err.Error()))
+		return 1
+	}
+
+	value, err := tokenizer.Detokenize(defaultCtx, role, token)
+
+	if err != nil {
+		dc.ui.Error(fmt.Sprintf("failed to detokenize token for role '%s': %s", role, err.Error()))
+		return 1
+	}
+
+	err = outputFormatted(dc.ui, format, &detokenizeOutput{Value: string(value)}, func() {
+		dc.ui.Output(fmt.Sprintf("value: %s", value))
+	})
+
+	if err != nil {
+		dc.ui.Error(err.Error())
+		return 1
+	}
+
+	return 0
+}
+
+// detokenizeOutput is the json and yaml schema of keyring detokenize.
+type detokenizeOutput struct {
+	Value string `json:"value"`
+}
@@ -4,8 +4,12 @@ go 1.18
 
 require (
 	github.com/hashicorp/go-secure-stdlib/kv-builder v0.1.2
+	github.com/klauspost/compress v1.16.7
 	github.com/mitchellh/cli v1.1.3
+	github.com/santhosh-tekuri/jsonschema/v5 v5.3.0
 	go.etcd.io/bbolt v1.3.6
+	golang.org/x/crypto v0.24.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -28,6 +32,5 @@ require (
 	github.com/posener/complete v1.1.1 // indirect
 	github.com/shopspring/decimal v1.2.0 // indirect
 	github.com/spf13/cast v1.3.1 // indirect
-	golang.org/x/crypto v0.0.0-20200820211705-5c72a883971a // indirect
-	golang.org/x/sys v0.0.0-20200923182605-d9f96fdee20d // indirect
+	golang.org/x/sys v0.21.0 // indirect
 )
@@ -0,0 +1,494 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/scrypt"
+)
+
+// An archive is a stream of records, encrypted independently of the keyring
+// so it can be imported into a keyring with a different root token.
+//
+// The archive starts with a plaintext header describing how the archive key
+// is derived, followed by segments of json encoded records. Each segment is
+// sealed with AES-GCM under the archive key, using the header as additional
+// data. The nonce of a segment holds its counter and whether it is the last
+// segment, so reordered, dropped or truncated segments fail to open.
+const (
+	archiveMagic = "KRARCHV1"
+
+	archiveModePassphrase byte = 1
+	archiveModeRecipient  byte = 2
+
+	archiveSaltSize        = 16
+	archiveKeySize         = 32
+	archiveNoncePrefixSize = 7
+	archiveSegmentSize     = 64 * 1024
+
+	archiveScryptN = 1 << 15
+	archiveScryptR = 8
+	archiveScryptP = 1
+
+	archiveKeyInfo = "keyring archive"
+)
+
+var (
+	ErrArchiveInvalid            = errors.New("archive is invalid")
+	ErrArchiveTruncated          = errors.New("archive is truncated")
+	ErrArchivePassphraseRequired = errors.New("archive is encrypted with a passphrase")
+	ErrArchiveIdentityRequired   = errors.New("archive is encrypted for a recipient key")
+)
+
+// ArchiveRecord holds the key value pairs of a single path.
+type ArchiveRecord struct {
+	Path string            `json:"path"`
+	Data map[string][]byte `json:"data"`
+}
+
+// ArchiveKeys holds the secrets able to open an archive. Only the secret
+// matching how the archive was encrypted is required.
+type ArchiveKeys struct {
+	Passphrase []byte
+
+	// Identity is the private key of the recipient the archive
+	// was encrypted for.
+	Identity []byte
+}
+
+// GenerateArchiveIdentity generates a key pair to encrypt archives with.
+// The recipient is shared with whoever exports the archive, while the
+// identity is kept private to import it.
+func GenerateArchiveIdentity() (identity []byte, recipient []byte, err error) {
+
+	identity = make([]byte, curve25519.ScalarSize)
+
+	if _, err := rand.Read(identity); err != nil {
+		return nil, nil, err
+	}
+
+	recipient, err = curve25519.X25519(identity, curve25519.Basepoint)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return identity, recipient, nil
+}
+
+// ArchiveWriter encrypts records into an archive.
+type ArchiveWriter struct {
+	w      io.Writer
+	aead   cipher.AEAD
+	header []byte
+	prefix []byte
+
+	counter uint32
+	buf     bytes.Buffer
+	closed  bool
+}
+
+// NewPassphraseArchiveWriter starts an archive encrypted with a key
+// derived from the passphrase.
+func NewPassphraseArchiveWriter(w io.Writer, passphrase []byte) (*ArchiveWriter, error) {
+
+	if len(passphrase) == 0 {
+		return nil, ErrArchivePassphraseRequired
+	}
+
+	salt := make([]byte, archiveSaltSize)
+
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	header := append([]byte(archiveMagic), archiveModePassphrase)
+	header = append(header, salt...)
+
+	key, err := scrypt.Key(passphrase, salt, archiveScryptN, archiveScryptR, archiveScryptP, archiveKeySize)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive archive key: %s", err.Error())
+	}
+
+	return newArchiveWriter(w, header, key)
+}
+
+// NewRecipientArchiveWriter starts an archive only the identity of the
+// recipient can open.
+func NewRecipientArchiveWriter(w io.Writer, recipient []byte) (*ArchiveWriter, error) {
+
+	if len(recipient) != curve25519.PointSize {
+		return nil, fmt.Errorf("recipient key must be %d bytes", curve25519.PointSize)
+	}
+
+	ephemeral, ephemeralPublic, err := GenerateArchiveIdentity()
+
+	if err != nil {
+		return nil, err
+	}
+
+	header := append([]byte(archiveMagic), archiveModeRecipient)
+	header = append(header, ephemeralPublic...)
+
+	key, err := recipientArchiveKey(ephemeral, recipient, ephemeralPublic, recipient)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return newArchiveWriter(w, header, key)
+}
+
+func newArchiveWriter(w io.Writer, header []byte, key []byte) (*ArchiveWriter, error) {
+
+	aead, err := archiveAEAD(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := make([]byte, archiveNoncePrefixSize)
+
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, err
+	}
+
+	header = append(header, prefix...)
+
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+
+	return &ArchiveWriter{
+		w:      w,
+		aead:   aead,
+		header: header,
+		prefix: prefix,
+	}, nil
+}
+
+// Write adds the record to the archive.
+func (aw *ArchiveWriter) Write(record *ArchiveRecord) error {
+
+	if aw.closed {
+		return fmt.Errorf("archive is closed")
+	}
+
+	recordBytes, err := json.Marshal(record)
+
+	if err != nil {
+		return fmt.Errorf("failed to encode record: %s", err.Error())
+	}
+
+	aw.buf.Write(recordBytes)
+	aw.buf.WriteByte('\n')
+
+	for aw.buf.Len() > archiveSegmentSize {
+		if err := aw.seal(aw.buf.Next(archiveSegmentSize), false); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Close seals the last segment. The archive is not valid until closed.
+func (aw *ArchiveWriter) Close() error {
+
+	if aw.closed {
+		return nil
+	}
+
+	aw.closed = true
+
+	return aw.seal(aw.buf.Next(aw.buf.Len()), true)
+}
+
+func (aw *ArchiveWriter) seal(segment []byte, last bool) error {
+
+	ciphertext := aw.aead.Seal(nil, archiveNonce(aw.prefix, aw.counter, last), segment, aw.header)
+
+	aw.counter++
+
+	size := make([]byte, 4)
+	binary.BigEndian.PutUint32(size, uint32(len(ciphertext)))
+
+	if _, err := aw.w.Write(size); err != nil {
+		return err
+	}
+
+	_, err := aw.w.Write(ciphertext)
+
+	return err
+}
+
+// ArchiveReader decrypts the records of an archive.
+type ArchiveReader struct {
+	r      io.Reader
+	aead   cipher.AEAD
+	header []byte
+	prefix []byte
+
+	counter uint32
+	last    bool
+	pending []byte
+	records *bufio.Reader
+}
+
+// OpenArchive reads the archive header, deriving the archive key from
+// the matching secret in the keys.
+func OpenArchive(r io.Reader, keys *ArchiveKeys) (*ArchiveReader, error) {
+
+	if keys == nil {
+		keys = &ArchiveKeys{}
+	}
+
+	header := make([]byte, len(archiveMagic)+1)
+
+	if _, err := io.ReadFull(r, header); err != nil || string(header[:len(archiveMagic)]) != archiveMagic {
+		return nil, ErrArchiveInvalid
+	}
+
+	var key []byte
+
+	switch header[len(archiveMagic)] {
+
+	case archiveModePassphrase:
+
+		salt := make([]byte, archiveSaltSize)
+
+		if _, err := io.ReadFull(r, salt); err != nil {
+			return nil, ErrArchiveInvalid
+		}
+
+		header = append(header, salt...)
+
+		if len(keys.Passphrase) == 0 {
+			return nil, ErrArchivePassphraseRequired
+		}
+
+		derived, err := scrypt.Key(keys.Passphrase, salt, archiveScryptN, archiveScryptR, archiveScryptP, archiveKeySize)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive archive key: %s", err.Error())
+		}
+
+		key = derived
+
+	case archiveModeRecipient:
+
+		ephemeralPublic := make([]byte, curve25519.PointSize)
+
+		if _, err := io.ReadFull(r, ephemeralPublic); err != nil {
+			return nil, ErrArchiveInvalid
+		}
+
+		header = append(header, ephemeralPublic...)
+
+		if len(keys.Identity) == 0 {
+			return nil, ErrArchiveIdentityRequired
+		}
+
+		recipient, err := curve25519.X25519(keys.Identity, curve25519.Basepoint)
+
+		if err != nil {
+			return nil, fmt.Errorf("identity is invalid: %s", err.Error())
+		}
+
+		derived, err := recipientArchiveKey(keys.Identity, ephemeralPublic, ephemeralPublic, recipient)
+
+		if err != nil {
+			return nil, err
+		}
+
+		key = derived
+
+	default:
+		return nil, ErrArchiveInvalid
+	}
+
+	prefix := make([]byte, archiveNoncePrefixSize)
+
+	if _, err := io.ReadFull(r, prefix); err != nil {
+		return nil, ErrArchiveInvalid
+	}
+
+	header = append(header, prefix...)
+
+	aead, err := archiveAEAD(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ar := &ArchiveReader{
+		r:      r,
+		aead:   aead,
+		header: header,
+		prefix: prefix,
+	}
+
+	ar.records = bufio.NewReader(readerFunc(ar.readPlaintext))
+
+	return ar, nil
+}
+
+// Next reports the next record in the archive, or io.EOF once every
+// record has been read.
+func (ar *ArchiveReader) Next() (*ArchiveRecord, error) {
+
+	line, err := ar.records.ReadBytes('\n')
+
+	if err == io.EOF && len(line) == 0 {
+		return nil, io.EOF
+	}
+
+	if err == io.EOF {
+		return nil, ErrArchiveTruncated
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var record ArchiveRecord
+
+	if err := json.Unmarshal(line, &record); err != nil {
+		return nil, fmt.Errorf("failed to decode record: %s", err.Error())
+	}
+
+	return &record, nil
+}
+
+// readPlaintext reads the decrypted segments as a single stream.
+func (ar *ArchiveReader) readPlaintext(p []byte) (int, error) {
+
+	for len(ar.pending) == 0 {
+
+		if ar.last {
+			return 0, io.EOF
+		}
+
+		segment, err := ar.openSegment()
+
+		if err != nil {
+			return 0, err
+		}
+
+		ar.pending = segment
+	}
+
+	n := copy(p, ar.pending)
+	ar.pending = ar.pending[n:]
+
+	return n, nil
+}
+
+// openSegment reads and decrypts the next segment.
+func (ar *ArchiveReader) openSegment() ([]byte, error) {
+
+	size := make([]byte, 4)
+
+	if _, err := io.ReadFull(ar.r, size); err != nil {
+		return nil, ErrArchiveTruncated
+	}
+
+	ciphertext := make([]byte, binary.BigEndian.Uint32(size))
+
+	if len(ciphertext) > archiveSegmentSize+ar.aead.Overhead() {
+		return nil, ErrArchiveInvalid
+	}
+
+	if _, err := io.ReadFull(ar.r, ciphertext); err != nil {
+		return nil, ErrArchiveTruncated
+	}
+
+	// Only the last segment opens with the last flag set,
+	// so a truncated archive never appears complete.
+	plaintext, err := ar.aead.Open(nil, archiveNonce(ar.prefix, ar.counter, false), ciphertext, ar.header)
+
+	if err != nil {
+
+		plaintext, err = ar.aead.Open(nil, archiveNonce(ar.prefix, ar.counter, true), ciphertext, ar.header)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt archive. Is the passphrase or identity correct?")
+		}
+
+		// Data appended after the last segment is not authenticated,
+		// so it is refused rather than ignored.
+		if _, err := io.ReadFull(ar.r, make([]byte, 1)); err != io.EOF {
+			return nil, fmt.Errorf("%w: unexpected data after the last segment", ErrArchiveInvalid)
+		}
+
+		ar.last = true
+	}
+
+	ar.counter++
+
+	return plaintext, nil
+}
+
+type readerFunc func(p []byte) (int, error)
+
+func (f readerFunc) Read(p []byte) (int, error) {
+	return f(p)
+}
+
+// recipientArchiveKey derives the archive key from the x25519 shared secret
+// between the ephemeral key and the recipient.
+func recipientArchiveKey(scalar []byte, point []byte, ephemeralPublic []byte, recipient []byte) ([]byte, error) {
+
+	shared, err := curve25519.X25519(scalar, point)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive archive key: %s", err.Error())
+	}
+
+	salt := append(append([]byte{}, ephemeralPublic...), recipient...)
+
+	key := make([]byte, archiveKeySize)
+
+	if _, err := io.ReadFull(hkdf.New(sha256.New, shared, salt, []byte(archiveKeyInfo)), key); err != nil {
+		return nil, fmt.Errorf("failed to derive archive key: %s", err.Error())
+	}
+
+	return key, nil
+}
+
+func archiveAEAD(key []byte) (cipher.AEAD, error) {
+
+	block, err := aes.NewCipher(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func archiveNonce(prefix []byte, counter uint32, last bool) []byte {
+
+	nonce := make([]byte, archiveNoncePrefixSize+5)
+
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[archiveNoncePrefixSize:], counter)
+
+	if last {
+		nonce[len(nonce)-1] = 1
+	}
+
+	return nonce
+}
@@ -0,0 +1,228 @@
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestArchivePassphrase(t *testing.T) {
+
+	records := testArchiveRecords(200)
+
+	var buf bytes.Buffer
+
+	writer, err := NewPassphraseArchiveWriter(&buf, []byte("correct horse"))
+
+	if err != nil {
+		t.Fatalf("failed to start archive: %s", err.Error())
+	}
+
+	writeArchiveRecords(t, writer, records)
+
+	archive, err := OpenArchive(bytes.NewReader(buf.Bytes()), &ArchiveKeys{Passphrase: []byte("correct horse")})
+
+	if err != nil {
+		t.Fatalf("failed to open archive: %s", err.Error())
+	}
+
+	if read := readArchiveRecords(t, archive); !reflect.DeepEqual(read, records) {
+		t.Fatalf("expected %d records to round trip, but got %d", len(records), len(read))
+	}
+
+	if _, err := OpenArchive(bytes.NewReader(buf.Bytes()), nil); !errors.Is(err, ErrArchivePassphraseRequired) {
+		t.Fatalf("expected passphrase required error, but got %v", err)
+	}
+
+	archive, err = OpenArchive(bytes.NewReader(buf.Bytes()), &ArchiveKeys{Passphrase: []byte("wrong horse")})
+
+	if err != nil {
+		t.Fatalf("failed to open archive: %s", err.Error())
+	}
+
+	if _, err := archive.Next(); err == nil {
+		t.Fatalf("expected the wrong passphrase to fail decryption")
+	}
+
+}
+
+func TestArchiveRecipient(t *testing.T) {
+
+	identity, recipient, err := GenerateArchiveIdentity()
+
+	if err != nil {
+		t.Fatalf("failed to generate identity: %s", err.Error())
+	}
+
+	records := testArchiveRecords(3)
+
+	var buf bytes.Buffer
+
+	writer, err := NewRecipientArchiveWriter(&buf, recipient)
+
+	if err != nil {
+		t.Fatalf("failed to start archive: %s", err.Error())
+	}
+
+	writeArchiveRecords(t, writer, records)
+
+	archive, err := OpenArchive(bytes.NewReader(buf.Bytes()), &ArchiveKeys{Identity: identity})
+
+	if err != nil {
+		t.Fatalf("failed to open archive: %s", err.Error())
+	}
+
+	if read := readArchiveRecords(t, archive); !reflect.DeepEqual(read, records) {
+		t.Fatalf("expected %d records to round trip, but got %d", len(records), len(read))
+	}
+
+	otherIdentity, _, err := GenerateArchiveIdentity()
+
+	if err != nil {
+		t.Fatalf("failed to generate identity: %s", err.Error())
+	}
+
+	archive, err = OpenArchive(bytes.NewReader(buf.Bytes()), &ArchiveKeys{Identity: otherIdentity})
+
+	if err != nil {
+		t.Fatalf("failed to open archive: %s", err.Error())
+	}
+
+	if _, err := archive.Next(); err == nil {
+		t.Fatalf("expected another identity to fail decryption")
+	}
+
+}
+
+func TestArchiveTruncated(t *testing.T) {
+
+	var buf bytes.Buffer
+
+	writer, err := NewPassphraseArchiveWriter(&buf, []byte("correct horse"))
+
+	if err != nil {
+		t.Fatalf("failed to start archive: %s", err.Error())
+	}
+
+	// Enough records to span several segments.
+	writeArchiveRecords(t, writer, testArchiveRecords(2000))
+
+	// Drop the last segment.
+	truncated := buf.Bytes()[:buf.Len()-100]
+
+	for len(truncated) > 0 {
+
+		archive, err := OpenArchive(bytes.NewReader(truncated), &ArchiveKeys{Passphrase: []byte("correct horse")})
+
+		if err != nil {
+			t.Fatalf("failed to open archive: %s", err.Error())
+		}
+
+		for {
+			_, err = archive.Next()
+
+			if err != nil {
+				break
+			}
+		}
+
+		if err == io.EOF {
+			t.Fatalf("expected truncated archive of %d bytes to fail", len(truncated))
+		}
+
+		truncated = truncated[:len(truncated)/2]
+
+		if len(truncated) < 64 {
+			break
+		}
+	}
+
+}
+
+func TestArchiveTrailingData(t *testing.T) {
+
+	var buf bytes.Buffer
+
+	writer, err := NewPassphraseArchiveWriter(&buf, []byte("correct horse"))
+
+	if err != nil {
+		t.Fatalf("failed to start archive: %s", err.Error())
+	}
+
+	writeArchiveRecords(t, writer, testArchiveRecords(10))
+
+	buf.WriteString("appended")
+
+	archive, err := OpenArchive(&buf, &ArchiveKeys{Passphrase: []byte("correct horse")})
+
+	if err != nil {
+		t.Fatalf("failed to open archive: %s", err.Error())
+	}
+
+	for {
+		_, err = archive.Next()
+
+		if err != nil {
+			break
+		}
+	}
+
+	if !errors.Is(err, ErrArchiveInvalid) {
+		t.Fatalf("expected an archive with trailing data to be invalid, but got %v", err)
+	}
+
+}
+
+func testArchiveRecords(count int) []*ArchiveRecord {
+
+	records := make([]*ArchiveRecord, 0, count)
+
+	for i := 0; i < count; i++ {
+		records = append(records, &ArchiveRecord{
+			Path: "secret/app/" + strings.Repeat("x", i%50),
+			Data: map[string][]byte{
+				"password": []byte(`"` + strings.Repeat("p", i) + `"`),
+			},
+		})
+	}
+
+	return records
+}
+
+func writeArchiveRecords(t *testing.T, writer *ArchiveWriter, records []*ArchiveRecord) {
+	t.Helper()
+
+	for _, record := range records {
+		if err := writer.Write(record); err != nil {
+			t.Fatalf("failed to write record: %s", err.Error())
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatalf("failed to close archive: %s", err.Error())
+	}
+}
+
+func readArchiveRecords(t *testing.T, archive *ArchiveReader) []*ArchiveRecord {
+	t.Helper()
+
+	records := make([]*ArchiveRecord, 0)
+
+	for {
+
+		record, err := archive.Next()
+
+		if err == io.EOF {
+			return records
+		}
+
+		if err != nil {
+			t.Fatalf("failed to read record: %s", err.Error())
+		}
+
+		records = append(records, record)
+	}
+}
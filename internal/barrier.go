@@ -10,7 +10,7 @@ import (
 	"errors"
 	"fmt"
 	"math"
-	"strings"
+	"sort"
 	"sync"
 
 	"github.com/woodrufj4/keyring-practice/backend"
@@ -44,6 +44,14 @@ type Barrier struct {
 	backend     backend.Backend
 	keyring     *Keyring
 	sync        sync.RWMutex
+
+	// chunkSize is the largest value sealed as a single ciphertext.
+	// Larger values are split into chunks of this size.
+	chunkSize int
+
+	// config holds the storage options, which are loaded along with
+	// the keyring.
+	config *BarrierConfig
 }
 
 // NewBarrier instantiates a new barrier
@@ -56,14 +64,20 @@ func NewBarrier(backend backend.Backend) (*Barrier, error) {
 	return &Barrier{
 		initialized: false,
 		backend:     backend,
+		chunkSize:   DefaultChunkSize,
+		config:      &BarrierConfig{},
 	}, nil
 
 }
 
 // Initialize sets up the barrier an initializes a keyring.
 //
+// If no keyring exists yet, a new keyring is persisted along with the
+// configuration within a single transaction. A nil configuration uses the
+// defaults. The configuration of an existing keyring is loaded instead.
+//
 // If the barrier has already been initialized, this does nothting.
-func (b *Barrier) Initialize(ctx context.Context, rootKey string) error {
+func (b *Barrier) Initialize(ctx context.Context, rootKey string, config *BarrierConfig) error {
 
 	if b.initialized {
 		return nil
@@ -72,24 +86,19 @@ func (b *Barrier) Initialize(ctx context.Context, rootKey string) error {
 	if err := b.loadKeyring(ctx, rootKey); err != nil {
 
 		if err == ErrKeyringNotFound {
-
-			// generate a new keyring
-			if err := b.initKeyring(rootKey); err != nil {
-				return err
-			}
-
-			// persist the new keyring
-			if err := b.persistKeyring(ctx); err != nil {
-				return err
-			}
-
-		} else {
-			return err
+			return b.createKeyring(ctx, rootKey, config)
 		}
+
+		return err
 	}
 
 	b.initialized = true
 
+	if err := b.loadConfig(ctx); err != nil {
+		b.initialized = false
+		return err
+	}
+
 	return nil
 }
 
@@ -167,41 +176,77 @@ func (b *Barrier) aesFromTerm(term uint32) (cipher.AEAD, error) {
 
 }
 
-// persistKeyring encrypts and then stores the keyring within the backend.
-func (b *Barrier) persistKeyring(ctx context.Context) error {
+// createKeyring generates a new keyring, and then persists it along with
+// the configuration within a single transaction, so a keyring is never
+// stored without its configuration.
+func (b *Barrier) createKeyring(ctx context.Context, rootKey string, config *BarrierConfig) error {
 
-	if b.keyring == nil {
-		return ErrKeyringNotSet
+	if config == nil {
+		config = &BarrierConfig{}
+	}
+
+	if err := config.validate(); err != nil {
+		return err
+	}
+
+	if err := b.initKeyring(rootKey); err != nil {
+		return err
 	}
 
 	keyringBytes, err := b.keyring.Serialize()
 
 	if err != nil {
+		b.keyring = nil
 		return fmt.Errorf("failed to serialize keyring: %s", err.Error())
 	}
 
 	gcm, err := b.aesFromKey(b.keyring.rootKey)
 
 	if err != nil {
+		b.keyring = nil
 		return err
 	}
 
 	keyringCipher, err := b.encrypt(gcm, 0, keyringBytes)
 
 	if err != nil {
+		b.keyring = nil
 		return fmt.Errorf("failed to encrypt keyring: %s", err.Error())
 	}
 
-	// encrypt and perist initial keyring
-	entries := []*backend.BackendEntry{
-		{
-			Key:   keyringCipherKey,
-			Value: keyringCipher,
-		},
+	created := *config
+	created.CompressionExclude = append([]string{}, config.CompressionExclude...)
+
+	// The configuration is encrypted with the new keyring.
+	b.initialized = true
+	b.config = &created
+
+	configTxn, err := b.configTxn(ctx, &created)
+
+	if err == nil {
+		err = b.backend.Transaction(ctx, []*backend.TxnEntry{
+			{
+				Operation: backend.PutOperation,
+				Path:      keyringPath,
+				Entries: []*backend.BackendEntry{
+					{
+						Key:   keyringCipherKey,
+						Value: keyringCipher,
+					},
+				},
+			},
+			configTxn,
+		})
 	}
 
-	return b.backend.Put(ctx, keyringPath, entries)
+	if err != nil {
+		b.initialized = false
+		b.keyring = nil
+		b.config = &BarrierConfig{}
+		return err
+	}
 
+	return nil
 }
 
 // loadKeyring attempts to retrieve the encrypted keyring from the backend.
@@ -315,6 +360,16 @@ func (b *Barrier) Keyring() (*Keyring, error) {
 	return b.keyring.Clone(), nil
 }
 
+// deriveKey derives a purpose specific key from the keyring.
+func (b *Barrier) deriveKey(purpose string) ([]byte, error) {
+
+	if !b.initialized {
+		return nil, ErrKeyringNotSet
+	}
+
+	return b.keyring.DeriveKey(purpose), nil
+}
+
 // Encrypt performs encryption and persistance of secrets
 func (b *Barrier) Encrypt(ctx context.Context, plaintext []byte) ([]byte, error) {
 
@@ -329,6 +384,14 @@ func (b *Barrier) Encrypt(ctx context.Context, plaintext []byte) ([]byte, error)
 
 // encrypt performs encryption on plain text
 func (b *Barrier) encrypt(gcm cipher.AEAD, term uint32, plain []byte) ([]byte, error) {
+	return b.seal(gcm, term, valueKindPlain, plain, nil)
+}
+
+// seal encrypts the plain text, recording the key term and the kind of
+// value in the header of the cipher text. Unless the kind is zero, as for
+// values written before it was recorded, the header is authenticated along
+// with the additional data.
+func (b *Barrier) seal(gcm cipher.AEAD, term uint32, kind byte, plain []byte, additionalData []byte) ([]byte, error) {
 
 	overhead := gcm.NonceSize() + gcm.Overhead()
 
@@ -345,6 +408,8 @@ func (b *Barrier) encrypt(gcm cipher.AEAD, term uint32, plain []byte) ([]byte, e
 	// Add the key term to the dst output
 	binary.BigEndian.PutUint32(out[:termSize], term)
 
+	out[termSize-1] = kind
+
 	nonce := out[termSize : termSize+gcm.NonceSize()]
 
 	n, err := rand.Read(nonce)
@@ -357,7 +422,11 @@ func (b *Barrier) encrypt(gcm cipher.AEAD, term uint32, plain []byte) ([]byte, e
 		return nil, fmt.Errorf("unable to read enough random bytes to fill gcm nonce")
 	}
 
-	return gcm.Seal(out, nonce, plain, nil), nil
+	if kind != 0 {
+		additionalData = append(append([]byte{}, out[:termSize]...), additionalData...)
+	}
+
+	return gcm.Seal(out, nonce, plain, additionalData), nil
 }
 
 func (b *Barrier) Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error) {
@@ -375,8 +444,13 @@ func (b *Barrier) Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error
 
 // decrypt only perform decryptions on cipher texts.
 func (b *Barrier) decrypt(gcm cipher.AEAD, cipher []byte) ([]byte, error) {
+	return b.open(gcm, cipher, nil)
+}
 
-	if len(cipher) < gcm.NonceSize() {
+// open decrypts cipher texts sealed with seal.
+func (b *Barrier) open(gcm cipher.AEAD, cipher []byte, additionalData []byte) ([]byte, error) {
+
+	if len(cipher) < termSize+gcm.NonceSize() {
 		return nil, fmt.Errorf("length of ciphertext is invalid")
 	}
 
@@ -388,79 +462,149 @@ func (b *Barrier) decrypt(gcm cipher.AEAD, cipher []byte) ([]byte, error) {
 
 	raw := cipher[termSize+gcm.NonceSize():]
 
-	return gcm.Open(out, nonce, raw, nil)
+	if cipher[termSize-1] != 0 {
+		additionalData = append(append([]byte{}, cipher[:termSize]...), additionalData...)
+	}
+
+	return gcm.Open(out, nonce, raw, additionalData)
 }
 
+// Put encrypts and persists the entries at the path. Values larger than
+// the chunk size are split into chunks.
 func (b *Barrier) Put(ctx context.Context, path string, entries []*backend.BackendEntry) error {
 
-	b.sync.Lock()
-	defer b.sync.Unlock()
-
-	for _, entry := range entries {
+	return b.Transaction(ctx, []*backend.TxnEntry{
+		{
+			Operation: backend.PutOperation,
+			Path:      path,
+			Entries:   entries,
+		},
+	})
+}
 
-		cipher, err := b.Encrypt(ctx, entry.Value)
+// Get retrieves and decrypts the entries at the path, reassembling any
+// chunked values.
+func (b *Barrier) Get(ctx context.Context, path string) ([]*backend.BackendEntry, error) {
 
-		if err != nil {
-			return err
-		}
+	b.sync.RLock()
+	defer b.sync.RUnlock()
 
-		entry.Value = cipher
+	if !b.initialized {
+		return nil, ErrKeyringNotSet
 	}
 
-	return b.backend.Put(ctx, path, entries)
+	return b.get(ctx, path)
 }
 
-func (b *Barrier) Get(ctx context.Context, path string) ([]*backend.BackendEntry, error) {
-
-	b.sync.RLock()
-	defer b.sync.RUnlock()
+func (b *Barrier) get(ctx context.Context, path string) ([]*backend.BackendEntry, error) {
 
-	entries, err := b.backend.Get(ctx, path)
+	entries, err := b.backend.Get(ctx, b.storedPath(path))
 
 	if err != nil {
 		return nil, err
 	}
 
+	if entries == nil {
+		return nil, nil
+	}
+
+	chunks := make(map[string][]byte)
+
 	for _, entry := range entries {
-		plaintext, err := b.Decrypt(ctx, entry.Value)
+		if isChunkKey(entry.Key) {
+			chunks[entry.Key] = entry.Value
+		}
+	}
+
+	plainEntries := make([]*backend.BackendEntry, 0, len(entries)-len(chunks))
+
+	for _, entry := range entries {
+
+		if isChunkKey(entry.Key) {
+			continue
+		}
+
+		plaintext, err := b.openEntry(entry.Key, entry.Value, chunks)
 
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("failed to decrypt '%s' at path '%s': %w", entry.Key, path, err)
+		}
+
+		key := entry.Key
+
+		if b.confidentialPath(path) {
+
+			key, plaintext, err = decodeNamedValue(plaintext)
+
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode '%s' at path '%s': %w", entry.Key, path, err)
+			}
 		}
 
-		entry.Value = plaintext
+		plainEntries = append(plainEntries, &backend.BackendEntry{
+			Key:   key,
+			Value: plaintext,
+		})
 	}
 
-	return entries, nil
+	// Stored keys are in the order of their HMACs with confidential names.
+	sort.Slice(plainEntries, func(i, j int) bool {
+		return plainEntries[i].Key < plainEntries[j].Key
+	})
 
+	return plainEntries, nil
 }
 
+// List reports the existing paths that start with the prefix.
 func (b *Barrier) List(ctx context.Context, pathPrefix string) ([]string, error) {
 
 	b.sync.RLock()
 	defer b.sync.RUnlock()
 
-	list, err := b.backend.List(ctx)
-
-	if err != nil {
-		return nil, err
+	if b.config.ConfidentialNames {
+		return b.listIndex(ctx, pathPrefix)
 	}
 
-	filteredList := make([]string, 0)
+	return b.backend.List(ctx, pathPrefix)
+}
 
-	for _, str := range list {
-		if strings.HasPrefix(str, pathPrefix) {
-			filteredList = append(filteredList, str)
-		}
-	}
+// Delete removes all the entries at the path.
+func (b *Barrier) Delete(ctx context.Context, path string) error {
 
-	return filteredList, nil
+	return b.Transaction(ctx, []*backend.TxnEntry{
+		{
+			Operation: backend.DeleteOperation,
+			Path:      path,
+		},
+	})
 }
 
-func (b *Barrier) Delete(ctx context.Context, path string) error {
+// Transaction encrypts the entries of any put operations before atomically
+// performing all the operations within the backend. The chunks of values
+// which are overwritten or deleted are removed, and the blind index is
+// updated, within the same transaction.
+func (b *Barrier) Transaction(ctx context.Context, txns []*backend.TxnEntry) error {
 
 	b.sync.Lock()
 	defer b.sync.Unlock()
 
-	return b.backend.Delete(ctx, path)
+	if !b.initialized {
+		return ErrKeyringNotSet
+	}
+
+	sealed, err := b.sealTxns(ctx, txns)
+
+	if err != nil {
+		return err
+	}
+
+	indexTxns, err := b.blindIndexTxns(ctx, txns)
+
+	if err != nil {
+		return err
+	}
+
+	sealed = append(sealed, indexTxns...)
+
+	return b.backend.Transaction(ctx, sealed)
 }
@@ -0,0 +1,225 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/woodrufj4/keyring-practice/backend"
+)
+
+const (
+
+	// barrierConfigPath is the location of the barrier configuration,
+	// encrypted by the keyring.
+	barrierConfigPath = "core/barrier"
+	barrierConfigKey  = "config"
+)
+
+// ErrBarrierConfigLocked is returned when changing an option which can
+// only be set before anything is stored.
+var ErrBarrierConfigLocked = errors.New("option can only be changed before any secrets are stored")
+
+// BarrierConfig holds the storage options of the barrier.
+type BarrierConfig struct {
+
+	// ConfidentialNames stores paths and entry keys as keyed HMACs, so the
+	// backend reveals no names. Paths are listed from an encrypted index.
+	ConfidentialNames bool `json:"confidential_names"`
+
+	// Padding is the scheme values are padded with before sealing, hiding
+	// their length. Values record whether they were padded, so changing
+	// the scheme leaves existing values readable.
+	Padding string `json:"padding,omitempty"`
+
+	// Compression is the scheme values are compressed with before padding
+	// and sealing. Values smaller than the threshold, or stored under an
+	// excluded path prefix, are not compressed.
+	Compression          string   `json:"compression,omitempty"`
+	CompressionThreshold int      `json:"compression_threshold,omitempty"`
+	CompressionExclude   []string `json:"compression_exclude,omitempty"`
+
+	// BlindIndex maintains an index of keyed HMACs of the key names and
+	// values of every entry, so paths can be searched without decrypting
+	// them. The index reveals which entries share a key name or a value.
+	BlindIndex bool `json:"blind_index,omitempty"`
+}
+
+// Config provides a copy of the barrier configuration.
+func (b *Barrier) Config() (*BarrierConfig, error) {
+
+	b.sync.RLock()
+	defer b.sync.RUnlock()
+
+	if !b.initialized {
+		return nil, ErrKeyringNotSet
+	}
+
+	config := *b.config
+	config.CompressionExclude = append([]string{}, b.config.CompressionExclude...)
+
+	return &config, nil
+}
+
+// Configure changes and persists the barrier configuration. Confidential
+// names can only be changed while nothing is stored outside of core/.
+// Enabling the blind index indexes every stored path, while disabling it
+// removes the index. The index is changed within the same transaction as
+// the configuration, so the two never disagree.
+func (b *Barrier) Configure(ctx context.Context, config *BarrierConfig) error {
+
+	b.sync.Lock()
+	defer b.sync.Unlock()
+
+	if !b.initialized {
+		return ErrKeyringNotSet
+	}
+
+	if err := config.validate(); err != nil {
+		return err
+	}
+
+	if config.ConfidentialNames != b.config.ConfidentialNames {
+
+		paths, err := b.backend.List(ctx, "")
+
+		if err != nil {
+			return err
+		}
+
+		for _, path := range paths {
+			if !isBarrierPath(path) {
+				return fmt.Errorf("confidential names: %w", ErrBarrierConfigLocked)
+			}
+		}
+	}
+
+	configTxn, err := b.configTxn(ctx, config)
+
+	if err != nil {
+		return err
+	}
+
+	txns := []*backend.TxnEntry{configTxn}
+
+	rebuild := config.BlindIndex != b.config.BlindIndex
+
+	updated := *config
+	updated.CompressionExclude = append([]string{}, config.CompressionExclude...)
+
+	// The index is built with the new configuration, which is only kept
+	// once the transaction succeeds.
+	previous := b.config
+	b.config = &updated
+
+	if rebuild {
+
+		indexTxns, err := b.rebuildBlindIndexTxns(ctx)
+
+		if err != nil {
+			b.config = previous
+			return err
+		}
+
+		txns = append(txns, indexTxns...)
+	}
+
+	if err := b.backend.Transaction(ctx, txns); err != nil {
+		b.config = previous
+		return err
+	}
+
+	return nil
+}
+
+// validate checks the options of the configuration are supported.
+func (c *BarrierConfig) validate() error {
+
+	if err := ValidatePadding(c.Padding); err != nil {
+		return err
+	}
+
+	if err := ValidateCompression(c.Compression); err != nil {
+		return err
+	}
+
+	if c.CompressionThreshold < 0 {
+		return fmt.Errorf("compression threshold must not be negative")
+	}
+
+	return nil
+}
+
+// configTxn reports the operation persisting the encrypted configuration.
+func (b *Barrier) configTxn(ctx context.Context, config *BarrierConfig) (*backend.TxnEntry, error) {
+
+	configBytes, err := json.Marshal(config)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode barrier config: %s", err.Error())
+	}
+
+	configCipher, err := b.Encrypt(ctx, configBytes)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt barrier config: %s", err.Error())
+	}
+
+	return &backend.TxnEntry{
+		Operation: backend.PutOperation,
+		Path:      barrierConfigPath,
+		Entries: []*backend.BackendEntry{
+			{
+				Key:   barrierConfigKey,
+				Value: configCipher,
+			},
+		},
+	}, nil
+}
+
+// loadConfig reads the barrier configuration. Keyrings created before the
+// configuration existed use the defaults.
+func (b *Barrier) loadConfig(ctx context.Context) error {
+
+	entries, err := b.backend.Get(ctx, barrierConfigPath)
+
+	if err != nil {
+		return err
+	}
+
+	config := &BarrierConfig{}
+
+	for _, entry := range entries {
+
+		if entry.Key != barrierConfigKey {
+			continue
+		}
+
+		configBytes, err := b.Decrypt(ctx, entry.Value)
+
+		if err != nil {
+			return fmt.Errorf("failed to decrypt barrier config: %s", err.Error())
+		}
+
+		if err := json.Unmarshal(configBytes, config); err != nil {
+			return fmt.Errorf("failed to decode barrier config: %s", err.Error())
+		}
+	}
+
+	b.config = config
+
+	return nil
+}
+
+// isBarrierPath reports if the path is managed by the barrier itself,
+// rather than stored through it.
+func isBarrierPath(path string) bool {
+
+	if strings.HasPrefix(path, blindIndexPrefix) || strings.HasPrefix(path, blindEntriesPrefix) {
+		return true
+	}
+
+	return path == keyringPath || path == barrierConfigPath || path == barrierIndexPath
+}
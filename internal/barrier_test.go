@@ -3,7 +3,10 @@ package internal
 import (
 	"bytes"
 	"context"
+	"errors"
 	"os"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -47,7 +50,7 @@ func TestBarrierInit(t *testing.T) {
 		t.Fatalf("failed to generate random token: %s", err.Error())
 	}
 
-	err = barrier.Initialize(context.Background(), string(initialKey))
+	err = barrier.Initialize(context.Background(), string(initialKey), nil)
 
 	if err != nil {
 		t.Fatalf("failed to initialize barrier: %s", err.Error())
@@ -59,6 +62,86 @@ func TestBarrierInit(t *testing.T) {
 
 }
 
+func TestBarrierInitConfig(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("to slow for testing.Short. IO operations")
+	}
+
+	ctx := context.Background()
+
+	fileBackend := setupBackend(t)
+
+	t.Cleanup(func() {
+
+		if shutdownErr := fileBackend.Cleanup(context.Background()); shutdownErr != nil {
+			t.Errorf("failed to cleanly shutdown the backend: %s", shutdownErr.Error())
+		}
+
+		if removeErr := os.Remove(DefaultTestKeyringPath); removeErr != nil {
+			t.Errorf("failed to remove backend artifact: %s", removeErr.Error())
+		}
+
+	})
+
+	config := &BarrierConfig{Padding: PaddingPADME, Compression: CompressionGzip}
+
+	// A keyring is never persisted without its configuration.
+	failing, err := NewBarrier(&failingTxnBackend{Backend: fileBackend})
+
+	if err != nil {
+		t.Fatalf("failed to instantiate barrier: %s", err.Error())
+	}
+
+	if err := failing.Initialize(ctx, "first-root-key-of-32-bytes-long!", config); err == nil {
+		t.Fatalf("expected initialize to fail with the failing backend")
+	}
+
+	if failing.Initialized() {
+		t.Fatalf("expected the failed barrier to not be initialized")
+	}
+
+	if persisted, err := failing.KeyringPersisted(ctx); err != nil || persisted {
+		t.Fatalf("expected no keyring to be persisted, but got %v", err)
+	}
+
+	barrier, err := NewBarrier(fileBackend)
+
+	if err != nil {
+		t.Fatalf("failed to instantiate barrier: %s", err.Error())
+	}
+
+	initialKey, err := barrier.GenerateKey()
+
+	if err != nil {
+		t.Fatalf("failed to generate random token: %s", err.Error())
+	}
+
+	if err := barrier.Initialize(ctx, string(initialKey), config); err != nil {
+		t.Fatalf("failed to initialize barrier: %s", err.Error())
+	}
+
+	reopened, err := NewBarrier(fileBackend)
+
+	if err != nil {
+		t.Fatalf("failed to instantiate barrier: %s", err.Error())
+	}
+
+	if err := reopened.Initialize(ctx, string(initialKey), nil); err != nil {
+		t.Fatalf("failed to initialize barrier: %s", err.Error())
+	}
+
+	loaded, err := reopened.Config()
+
+	if err != nil {
+		t.Fatalf("failed to read config: %s", err.Error())
+	}
+
+	if loaded.Padding != config.Padding || loaded.Compression != config.Compression {
+		t.Fatalf("expected the configuration to be persisted with the keyring, but got %#v", loaded)
+	}
+}
+
 func TestBarrierReInit(t *testing.T) {
 
 	if testing.Short() {
@@ -91,7 +174,7 @@ func TestBarrierReInit(t *testing.T) {
 		t.Fatalf("failed to generate random token: %s", err.Error())
 	}
 
-	err = barrier.Initialize(context.Background(), string(initialKey))
+	err = barrier.Initialize(context.Background(), string(initialKey), nil)
 
 	if err != nil {
 		t.Fatalf("failed to initialize barrier: %s", err.Error())
@@ -113,7 +196,7 @@ func TestBarrierReInit(t *testing.T) {
 		t.Fatalf("failed to instantiate second test barrier: %s", err.Error())
 	}
 
-	err = barrier2.Initialize(context.Background(), string(initialKey))
+	err = barrier2.Initialize(context.Background(), string(initialKey), nil)
 
 	if err != nil {
 		t.Fatalf("failed to initialize second test barrier: %s", err.Error())
@@ -170,7 +253,7 @@ func TestBarrierExists(t *testing.T) {
 		t.Fatalf("failed to generate random token: %s", err.Error())
 	}
 
-	err = barrier.Initialize(context.Background(), string(initialKey))
+	err = barrier.Initialize(context.Background(), string(initialKey), nil)
 
 	if err != nil {
 		t.Fatalf("failed to initialize barrier: %s", err.Error())
@@ -214,3 +297,658 @@ func setupBackend(t *testing.T) backend.Backend {
 
 	return fileBackend
 }
+
+// setupBarrier provides an initialized barrier backed by a file backend
+// that is removed once the test completes.
+func setupBarrier(t *testing.T) *Barrier {
+	t.Helper()
+
+	fileBackend := setupBackend(t)
+
+	t.Cleanup(func() {
+
+		if shutdownErr := fileBackend.Cleanup(context.Background()); shutdownErr != nil {
+			t.Errorf("failed to cleanly shutdown the backend: %s", shutdownErr.Error())
+		}
+
+		if removeErr := os.Remove(DefaultTestKeyringPath); removeErr != nil {
+			t.Errorf("failed to remove backend artifact: %s", removeErr.Error())
+		}
+
+	})
+
+	barrier, err := NewBarrier(fileBackend)
+
+	if err != nil {
+		t.Fatalf("failed to instantiate barrier: %s", err.Error())
+	}
+
+	initialKey, err := barrier.GenerateKey()
+
+	if err != nil {
+		t.Fatalf("failed to generate random token: %s", err.Error())
+	}
+
+	err = barrier.Initialize(context.Background(), string(initialKey), nil)
+
+	if err != nil {
+		t.Fatalf("failed to initialize barrier: %s", err.Error())
+	}
+
+	return barrier
+}
+
+func TestBarrierChunking(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("to slow for testing.Short. IO operations")
+	}
+
+	ctx := context.Background()
+
+	barrier := setupBarrier(t)
+	barrier.chunkSize = 16
+
+	large := bytes.Repeat([]byte("0123456789"), 10)
+
+	err := barrier.Put(ctx, "secret/artifact", []*backend.BackendEntry{
+		{Key: "dump", Value: large},
+		{Key: "small", Value: []byte("small")},
+	})
+
+	if err != nil {
+		t.Fatalf("failed to put chunked value: %s", err.Error())
+	}
+
+	stored, err := barrier.backend.Get(ctx, "secret/artifact")
+
+	if err != nil {
+		t.Fatalf("failed to read backend: %s", err.Error())
+	}
+
+	// The manifest, 7 chunks and the small value.
+	if len(stored) != 9 {
+		t.Fatalf("expected 9 stored entries, but got %d", len(stored))
+	}
+
+	assertBarrierValue(t, barrier, "secret/artifact", "dump", large)
+	assertBarrierValue(t, barrier, "secret/artifact", "small", []byte("small"))
+
+	// Overwriting with fewer chunks removes the stale chunks.
+	err = barrier.Put(ctx, "secret/artifact", []*backend.BackendEntry{
+		{Key: "dump", Value: large[:40]},
+	})
+
+	if err != nil {
+		t.Fatalf("failed to overwrite chunked value: %s", err.Error())
+	}
+
+	stored, _ = barrier.backend.Get(ctx, "secret/artifact")
+
+	if len(stored) != 5 {
+		t.Fatalf("expected 5 stored entries after overwrite, but got %d", len(stored))
+	}
+
+	assertBarrierValue(t, barrier, "secret/artifact", "dump", large[:40])
+
+	// Swapping chunks is detected.
+	err = barrier.backend.Put(ctx, "secret/artifact", []*backend.BackendEntry{
+		{Key: chunkKey("dump", 0), Value: storedValue(stored, chunkKey("dump", 1))},
+		{Key: chunkKey("dump", 1), Value: storedValue(stored, chunkKey("dump", 0))},
+	})
+
+	if err != nil {
+		t.Fatalf("failed to swap chunks: %s", err.Error())
+	}
+
+	if _, err := barrier.Get(ctx, "secret/artifact"); !errors.Is(err, ErrChunkInvalid) {
+		t.Fatalf("expected reordered chunks to be invalid, but got %v", err)
+	}
+
+	// Dropping the last chunk is detected.
+	err = barrier.backend.Put(ctx, "secret/artifact", []*backend.BackendEntry{
+		{Key: chunkKey("dump", 0), Value: storedValue(stored, chunkKey("dump", 0))},
+		{Key: chunkKey("dump", 1), Value: storedValue(stored, chunkKey("dump", 1))},
+	})
+
+	if err != nil {
+		t.Fatalf("failed to restore chunks: %s", err.Error())
+	}
+
+	assertBarrierValue(t, barrier, "secret/artifact", "dump", large[:40])
+
+	err = barrier.backend.Transaction(ctx, []*backend.TxnEntry{
+		{
+			Operation: backend.DeleteOperation,
+			Path:      "secret/artifact",
+			Entries:   []*backend.BackendEntry{{Key: chunkKey("dump", 2)}},
+		},
+	})
+
+	if err != nil {
+		t.Fatalf("failed to drop chunk: %s", err.Error())
+	}
+
+	if _, err := barrier.Get(ctx, "secret/artifact"); !errors.Is(err, ErrChunkMissing) {
+		t.Fatalf("expected truncated chunks to be missing, but got %v", err)
+	}
+
+	// Deleting the value removes its chunks.
+	err = barrier.Transaction(ctx, []*backend.TxnEntry{
+		{
+			Operation: backend.DeleteOperation,
+			Path:      "secret/artifact",
+			Entries:   []*backend.BackendEntry{{Key: "dump"}},
+		},
+	})
+
+	if err != nil {
+		t.Fatalf("failed to delete chunked value: %s", err.Error())
+	}
+
+	stored, _ = barrier.backend.Get(ctx, "secret/artifact")
+
+	if len(stored) != 1 || stored[0].Key != "small" {
+		t.Fatalf("expected only the small value to remain, but got %d entries", len(stored))
+	}
+
+	err = barrier.Put(ctx, "secret/artifact", []*backend.BackendEntry{
+		{Key: "bad\x00key", Value: []byte("value")},
+	})
+
+	if !errors.Is(err, ErrReservedKey) {
+		t.Fatalf("expected keys with a NUL byte to be reserved, but got %v", err)
+	}
+}
+
+func assertBarrierValue(t *testing.T, barrier *Barrier, path string, key string, value []byte) {
+	t.Helper()
+
+	entries, err := barrier.Get(context.Background(), path)
+
+	if err != nil {
+		t.Fatalf("failed to get entries at %s: %s", path, err.Error())
+	}
+
+	if !bytes.Equal(storedValue(entries, key), value) {
+		t.Fatalf("expected %s at %s to be %q, but got %q", key, path, value, storedValue(entries, key))
+	}
+}
+
+func storedValue(entries []*backend.BackendEntry, key string) []byte {
+
+	for _, entry := range entries {
+		if entry.Key == key {
+			return entry.Value
+		}
+	}
+
+	return nil
+}
+
+func TestBarrierConfidentialNames(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("to slow for testing.Short. IO operations")
+	}
+
+	ctx := context.Background()
+
+	barrier := setupBarrier(t)
+
+	if err := barrier.Configure(ctx, &BarrierConfig{ConfidentialNames: true}); err != nil {
+		t.Fatalf("failed to configure barrier: %s", err.Error())
+	}
+
+	kv, err := NewKV(barrier)
+
+	if err != nil {
+		t.Fatalf("failed to instantiate kv: %s", err.Error())
+	}
+
+	if _, err := kv.Put(ctx, "secret/prod/stripe", testEntries("api_key", "sk_live"), nil); err != nil {
+		t.Fatalf("failed to put secret: %s", err.Error())
+	}
+
+	if _, err := kv.Put(ctx, "secret/prod/github", testEntries("token", "ghp"), nil); err != nil {
+		t.Fatalf("failed to put secret: %s", err.Error())
+	}
+
+	storedPaths, err := barrier.backend.List(ctx, "")
+
+	if err != nil {
+		t.Fatalf("failed to list backend: %s", err.Error())
+	}
+
+	for _, storedPath := range storedPaths {
+
+		if strings.Contains(storedPath, "secret") {
+			t.Fatalf("expected stored path %s to not reveal the path", storedPath)
+		}
+
+		entries, _ := barrier.backend.Get(ctx, storedPath)
+
+		for _, entry := range entries {
+			if strings.Contains(entry.Key, "api_key") || strings.Contains(entry.Key, "token") {
+				t.Fatalf("expected stored key %s to not reveal the key", entry.Key)
+			}
+		}
+	}
+
+	secret, err := kv.Get(ctx, "secret/prod/stripe")
+
+	if err != nil {
+		t.Fatalf("failed to get secret: %s", err.Error())
+	}
+
+	assertKVValue(t, secret, "api_key", "sk_live")
+
+	paths, err := kv.List(ctx, "secret/", true)
+
+	if err != nil {
+		t.Fatalf("failed to list secrets: %s", err.Error())
+	}
+
+	if !reflect.DeepEqual(paths, []string{"prod/github", "prod/stripe"}) {
+		t.Fatalf("expected both paths to be listed, but got %v", paths)
+	}
+
+	if err := barrier.Configure(ctx, &BarrierConfig{}); !errors.Is(err, ErrBarrierConfigLocked) {
+		t.Fatalf("expected confidential names to be locked, but got %v", err)
+	}
+
+	// The configuration is loaded when unlocking the barrier again.
+	reopened, err := NewBarrier(barrier.backend)
+
+	if err != nil {
+		t.Fatalf("failed to instantiate barrier: %s", err.Error())
+	}
+
+	if err := reopened.Initialize(ctx, string(barrier.keyring.RootKey()), nil); err != nil {
+		t.Fatalf("failed to initialize barrier: %s", err.Error())
+	}
+
+	if err := reopened.Delete(ctx, "secret/prod/github"); err != nil {
+		t.Fatalf("failed to delete path: %s", err.Error())
+	}
+
+	paths, err = reopened.List(ctx, "secret/")
+
+	if err != nil {
+		t.Fatalf("failed to list paths: %s", err.Error())
+	}
+
+	if !reflect.DeepEqual(paths, []string{"secret/prod/stripe"}) {
+		t.Fatalf("expected only the remaining path to be listed, but got %v", paths)
+	}
+
+	entries, err := reopened.Get(ctx, "secret/prod/github")
+
+	if err != nil || entries != nil {
+		t.Fatalf("expected the deleted path to be removed, but got %v, %v", entries, err)
+	}
+}
+
+func TestBarrierPadding(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("to slow for testing.Short. IO operations")
+	}
+
+	ctx := context.Background()
+
+	barrier := setupBarrier(t)
+
+	if err := barrier.Configure(ctx, &BarrierConfig{Padding: PaddingBuckets}); err != nil {
+		t.Fatalf("failed to configure barrier: %s", err.Error())
+	}
+
+	err := barrier.Put(ctx, "secret/padded", []*backend.BackendEntry{
+		{Key: "pin", Value: []byte("1234")},
+		{Key: "key", Value: bytes.Repeat([]byte("k"), 60)},
+	})
+
+	if err != nil {
+		t.Fatalf("failed to put padded values: %s", err.Error())
+	}
+
+	stored, _ := barrier.backend.Get(ctx, "secret/padded")
+
+	if len(storedValue(stored, "pin")) != len(storedValue(stored, "key")) {
+		t.Fatalf("expected padded values within a bucket to be indistinguishable")
+	}
+
+	// Values written before the padding is changed remain readable.
+	if err := barrier.Configure(ctx, &BarrierConfig{Padding: PaddingNone}); err != nil {
+		t.Fatalf("failed to configure barrier: %s", err.Error())
+	}
+
+	err = barrier.Put(ctx, "secret/padded", []*backend.BackendEntry{
+		{Key: "unpadded", Value: []byte("1234")},
+	})
+
+	if err != nil {
+		t.Fatalf("failed to put unpadded value: %s", err.Error())
+	}
+
+	assertBarrierValue(t, barrier, "secret/padded", "pin", []byte("1234"))
+	assertBarrierValue(t, barrier, "secret/padded", "key", bytes.Repeat([]byte("k"), 60))
+	assertBarrierValue(t, barrier, "secret/padded", "unpadded", []byte("1234"))
+
+	if err := barrier.Configure(ctx, &BarrierConfig{Padding: "random"}); err == nil {
+		t.Fatal("expected an unsupported padding to fail")
+	}
+}
+
+func TestBarrierCompression(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("to slow for testing.Short. IO operations")
+	}
+
+	ctx := context.Background()
+
+	barrier := setupBarrier(t)
+
+	config := &BarrierConfig{
+		Compression:          CompressionZstd,
+		CompressionThreshold: 64,
+		CompressionExclude:   []string{"secret/raw/"},
+		Padding:              PaddingPADME,
+	}
+
+	if err := barrier.Configure(ctx, config); err != nil {
+		t.Fatalf("failed to configure barrier: %s", err.Error())
+	}
+
+	bundle := bytes.Repeat([]byte("-----BEGIN CERTIFICATE-----\n"), 100)
+
+	for _, path := range []string{"secret/zstd", "secret/raw/bundle"} {
+
+		err := barrier.Put(ctx, path, []*backend.BackendEntry{
+			{Key: "bundle", Value: bundle},
+			{Key: "pin", Value: []byte("1234")},
+		})
+
+		if err != nil {
+			t.Fatalf("failed to put %s: %s", path, err.Error())
+		}
+	}
+
+	compressed, _ := barrier.backend.Get(ctx, "secret/zstd")
+	excluded, _ := barrier.backend.Get(ctx, "secret/raw/bundle")
+
+	if len(storedValue(compressed, "bundle")) >= len(bundle)/4 {
+		t.Fatalf("expected the bundle to be compressed, but stored %d bytes", len(storedValue(compressed, "bundle")))
+	}
+
+	if len(storedValue(excluded, "bundle")) <= len(bundle) {
+		t.Fatalf("expected the bundle under an excluded prefix to not be compressed")
+	}
+
+	if valueFlags(storedValue(compressed, "pin"))&valueCompressionMask != 0 {
+		t.Fatalf("expected a value below the threshold to not be compressed")
+	}
+
+	// Values compressed with another scheme remain readable.
+	config.Compression = CompressionGzip
+
+	if err := barrier.Configure(ctx, config); err != nil {
+		t.Fatalf("failed to configure barrier: %s", err.Error())
+	}
+
+	err := barrier.Put(ctx, "secret/gzip", []*backend.BackendEntry{
+		{Key: "bundle", Value: bundle},
+	})
+
+	if err != nil {
+		t.Fatalf("failed to put gzip value: %s", err.Error())
+	}
+
+	assertBarrierValue(t, barrier, "secret/zstd", "bundle", bundle)
+	assertBarrierValue(t, barrier, "secret/zstd", "pin", []byte("1234"))
+	assertBarrierValue(t, barrier, "secret/raw/bundle", "bundle", bundle)
+	assertBarrierValue(t, barrier, "secret/gzip", "bundle", bundle)
+
+	if err := barrier.Configure(ctx, &BarrierConfig{Compression: "lz4"}); err == nil {
+		t.Fatal("expected an unsupported compression to fail")
+	}
+}
+
+func TestBarrierBlindIndex(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("to slow for testing.Short. IO operations")
+	}
+
+	ctx := context.Background()
+
+	barrier := setupBarrier(t)
+
+	kv, err := NewKV(barrier)
+
+	if err != nil {
+		t.Fatalf("failed to instantiate kv: %s", err.Error())
+	}
+
+	if _, err := kv.Put(ctx, "secret/a", testEntries("api_key", `"sk_live_1"`), nil); err != nil {
+		t.Fatalf("failed to put secret/a: %s", err.Error())
+	}
+
+	value := "sk_live_1"
+
+	if _, err := barrier.Search(ctx, &SearchQuery{Value: &value}); !errors.Is(err, ErrBlindIndexDisabled) {
+		t.Fatalf("expected search to fail without a blind index, but got %v", err)
+	}
+
+	// Paths stored before the blind index is enabled are indexed.
+	if err := barrier.Configure(ctx, &BarrierConfig{BlindIndex: true}); err != nil {
+		t.Fatalf("failed to configure barrier: %s", err.Error())
+	}
+
+	entries := []*backend.BackendEntry{
+		{Key: "token", Value: EncodeRawValue([]byte("sk_live_1"))},
+		{Key: "user", Value: []byte(`"admin"`)},
+	}
+
+	if _, err := kv.Put(ctx, "secret/b", entries, nil); err != nil {
+		t.Fatalf("failed to put secret/b: %s", err.Error())
+	}
+
+	assertSearch := func(key string, value string, expected ...string) {
+		t.Helper()
+
+		query := &SearchQuery{}
+
+		if key != "" {
+			query.Key = &key
+		}
+
+		if value != "" {
+			query.Value = &value
+		}
+
+		paths, err := barrier.Search(ctx, query)
+
+		if err != nil {
+			t.Fatalf("failed to search: %s", err.Error())
+		}
+
+		if len(paths) != len(expected) || (len(paths) > 0 && !reflect.DeepEqual(paths, expected)) {
+			t.Fatalf("expected key %q and value %q to match %v, but got %v", key, value, expected, paths)
+		}
+	}
+
+	assertSearch("", "sk_live_1", "secret/a", "secret/b")
+	assertSearch("api_key", "", "secret/a")
+	assertSearch("token", "sk_live_1", "secret/b")
+	assertSearch("user", "sk_live_1")
+
+	// Earlier versions remain indexed while they are retained.
+	if _, err := kv.Put(ctx, "secret/b", testEntries("token", `"rotated"`), nil); err != nil {
+		t.Fatalf("failed to put secret/b: %s", err.Error())
+	}
+
+	assertSearch("", "sk_live_1", "secret/a", "secret/b")
+	assertSearch("", "rotated", "secret/b")
+
+	if err := kv.DestroyAll(ctx, "secret/b"); err != nil {
+		t.Fatalf("failed to destroy secret/b: %s", err.Error())
+	}
+
+	assertSearch("", "sk_live_1", "secret/a")
+	assertSearch("user", "")
+
+	if _, err := barrier.Search(ctx, &SearchQuery{}); !errors.Is(err, ErrSearchQueryEmpty) {
+		t.Fatalf("expected an empty query to fail, but got %v", err)
+	}
+
+	// Disabling the blind index removes it.
+	if err := barrier.Configure(ctx, &BarrierConfig{}); err != nil {
+		t.Fatalf("failed to configure barrier: %s", err.Error())
+	}
+
+	indexPaths, _ := barrier.backend.List(ctx, blindIndexPrefix)
+
+	if len(indexPaths) != 0 {
+		t.Fatalf("expected the blind index to be removed, but found %d terms", len(indexPaths))
+	}
+}
+
+func TestBarrierBlindIndexConfigureFailure(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("to slow for testing.Short. IO operations")
+	}
+
+	ctx := context.Background()
+
+	barrier := setupBarrier(t)
+
+	if err := barrier.Put(ctx, "secret/a", testEntries("k", "leaked")); err != nil {
+		t.Fatalf("failed to put secret/a: %s", err.Error())
+	}
+
+	stored := barrier.backend
+	barrier.backend = &failingTxnBackend{Backend: stored}
+
+	if err := barrier.Configure(ctx, &BarrierConfig{BlindIndex: true}); err == nil {
+		t.Fatalf("expected configure to fail with the failing backend")
+	}
+
+	barrier.backend = stored
+
+	config, err := barrier.Config()
+
+	if err != nil {
+		t.Fatalf("failed to read config: %s", err.Error())
+	}
+
+	if config.BlindIndex {
+		t.Fatalf("expected the failed configure to leave the blind index disabled")
+	}
+
+	if err := barrier.loadConfig(ctx); err != nil {
+		t.Fatalf("failed to load config: %s", err.Error())
+	}
+
+	if barrier.config.BlindIndex {
+		t.Fatalf("expected the failed configure to not persist the blind index")
+	}
+
+	if err := barrier.Configure(ctx, &BarrierConfig{BlindIndex: true}); err != nil {
+		t.Fatalf("failed to configure barrier: %s", err.Error())
+	}
+
+	value := "leaked"
+
+	paths, err := barrier.Search(ctx, &SearchQuery{Value: &value})
+
+	if err != nil {
+		t.Fatalf("failed to search: %s", err.Error())
+	}
+
+	if !reflect.DeepEqual(paths, []string{"secret/a"}) {
+		t.Fatalf("expected secret/a to be indexed, but got %v", paths)
+	}
+}
+
+// failingTxnBackend fails every transaction, while reads succeed.
+type failingTxnBackend struct {
+	backend.Backend
+}
+
+func (f *failingTxnBackend) Transaction(ctx context.Context, txns []*backend.TxnEntry) error {
+	return errors.New("transaction failed")
+}
+
+func TestBarrierBlindIndexTransaction(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("to slow for testing.Short. IO operations")
+	}
+
+	ctx := context.Background()
+
+	barrier := setupBarrier(t)
+
+	if err := barrier.Configure(ctx, &BarrierConfig{BlindIndex: true}); err != nil {
+		t.Fatalf("failed to configure barrier: %s", err.Error())
+	}
+
+	if err := barrier.Put(ctx, "secret/a", testEntries("k", "leaked")); err != nil {
+		t.Fatalf("failed to put secret/a: %s", err.Error())
+	}
+
+	// The refs of secret/b are added and removed within the transaction, so
+	// they were never stored in the terms shared with secret/a.
+	txns := []*backend.TxnEntry{
+		{
+			Operation: backend.PutOperation,
+			Path:      "secret/b",
+			Entries:   testEntries("k", "leaked"),
+		},
+		{
+			Operation: backend.PutOperation,
+			Path:      "secret/b",
+			Entries:   testEntries("k", "other"),
+		},
+		{
+			Operation: backend.PutOperation,
+			Path:      "secret/c",
+			Entries:   testEntries("k", "leaked"),
+		},
+		{
+			Operation: backend.DeleteOperation,
+			Path:      "secret/c",
+		},
+	}
+
+	if err := barrier.Transaction(ctx, txns); err != nil {
+		t.Fatalf("failed to apply transaction: %s", err.Error())
+	}
+
+	value := "leaked"
+
+	paths, err := barrier.Search(ctx, &SearchQuery{Value: &value})
+
+	if err != nil {
+		t.Fatalf("failed to search: %s", err.Error())
+	}
+
+	if !reflect.DeepEqual(paths, []string{"secret/a"}) {
+		t.Fatalf("expected only secret/a to hold the value, but got %v", paths)
+	}
+
+	key := "k"
+
+	paths, err = barrier.Search(ctx, &SearchQuery{Key: &key})
+
+	if err != nil {
+		t.Fatalf("failed to search: %s", err.Error())
+	}
+
+	if !reflect.DeepEqual(paths, []string{"secret/a", "secret/b"}) {
+		t.Fatalf("expected secret/a and secret/b to hold the key, but got %v", paths)
+	}
+}
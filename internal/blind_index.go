@@ -0,0 +1,452 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/woodrufj4/keyring-practice/backend"
+)
+
+const (
+
+	// blindIndexPrefix holds a path per indexed term, named by the keyed
+	// HMAC of the term. Each entry is an entry the term was found in,
+	// holding its encrypted path.
+	blindIndexPrefix = "core/blind/"
+
+	// blindEntriesPrefix holds the terms indexed for the entries of each
+	// path, so they can be removed once the entries are overwritten or
+	// deleted.
+	blindEntriesPrefix = "core/blind-entries/"
+
+	blindIndexPurpose = "barrier-blind-index"
+
+	blindTermKey   = "key"
+	blindTermValue = "value"
+)
+
+var (
+	ErrBlindIndexDisabled = errors.New("blind index is not enabled")
+	ErrSearchQueryEmpty   = errors.New("search requires a key or a value")
+)
+
+// SearchQuery selects entries from the blind index. Nil fields match any
+// entry, while an entry must match every field that is set.
+type SearchQuery struct {
+	Key   *string
+	Value *string
+}
+
+// Search reports the paths holding an entry which matches the query,
+// using the blind index rather than decrypting the stored values.
+//
+// Every retained version of a path is indexed, so paths which held the
+// value in an earlier version are also reported.
+func (b *Barrier) Search(ctx context.Context, query *SearchQuery) ([]string, error) {
+
+	b.sync.RLock()
+	defer b.sync.RUnlock()
+
+	if !b.initialized {
+		return nil, ErrKeyringNotSet
+	}
+
+	if !b.config.BlindIndex {
+		return nil, ErrBlindIndexDisabled
+	}
+
+	terms := make([]string, 0, 2)
+
+	if query.Key != nil {
+		terms = append(terms, b.blindTerm(blindTermKey, *query.Key))
+	}
+
+	if query.Value != nil {
+		terms = append(terms, b.blindTerm(blindTermValue, *query.Value))
+	}
+
+	if len(terms) == 0 {
+		return nil, ErrSearchQueryEmpty
+	}
+
+	var matches map[string][]byte
+
+	for _, term := range terms {
+
+		entries, err := b.backend.Get(ctx, blindIndexPrefix+term)
+
+		if err != nil {
+			return nil, err
+		}
+
+		termMatches := make(map[string][]byte, len(entries))
+
+		for _, entry := range entries {
+			if _, ok := matches[entry.Key]; ok || matches == nil {
+				termMatches[entry.Key] = entry.Value
+			}
+		}
+
+		matches = termMatches
+	}
+
+	seen := make(map[string]bool)
+	paths := make([]string, 0)
+
+	for _, pathCipher := range matches {
+
+		path, err := b.Decrypt(ctx, pathCipher)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt index entry: %s", err.Error())
+		}
+
+		if !seen[string(path)] {
+			seen[string(path)] = true
+			paths = append(paths, string(path))
+		}
+	}
+
+	sort.Strings(paths)
+
+	return paths, nil
+}
+
+// blindTerm is the name a term is indexed under.
+func (b *Barrier) blindTerm(kind string, term string) string {
+	return nameHMAC(b.keyring.DeriveKey(blindIndexPurpose), kind+"\x00"+term)
+}
+
+// blindTerms reports the indexed terms of an entry. Versioned entries are
+// indexed by the name of their key, and values by their decoded contents,
+// so searches match regardless of the version or the value encoding.
+func (b *Barrier) blindTerms(key string, value []byte) []string {
+
+	if key == kvMetadataKey {
+		return nil
+	}
+
+	name := key
+
+	if strings.HasPrefix(key, kvDataPrefix) {
+		if _, dataKey, err := parseKVDataKey(key); err == nil {
+			name = dataKey
+		}
+	}
+
+	return []string{
+		b.blindTerm(blindTermKey, name),
+		b.blindTerm(blindTermValue, blindIndexValue(value)),
+	}
+}
+
+// blindIndexValue is the contents of a stored value. Raw values and json
+// strings are indexed by their contents, and other json by its text.
+func blindIndexValue(value []byte) string {
+
+	if IsRawValue(value) {
+		return string(value[1:])
+	}
+
+	var str string
+
+	if err := json.Unmarshal(value, &str); err == nil {
+		return str
+	}
+
+	return string(value)
+}
+
+// blindRef is the entry of a term, identifying the entry it was found in.
+func blindRef(storedPath string, storedKey string) string {
+	return storedPath + "\x00" + storedKey
+}
+
+// blindIndexTxns reports the operations updating the blind index with the
+// put and delete operations. Paths under core/ are never indexed.
+func (b *Barrier) blindIndexTxns(ctx context.Context, txns []*backend.TxnEntry) ([]*backend.TxnEntry, error) {
+
+	if !b.config.BlindIndex {
+		return nil, nil
+	}
+
+	// The indexed terms of every entry of the paths, tracked as the
+	// operations are applied.
+	pathTerms := make(map[string]map[string][]string)
+
+	loadTerms := func(storedPath string) (map[string][]string, error) {
+
+		if terms, ok := pathTerms[storedPath]; ok {
+			return terms, nil
+		}
+
+		entries, err := b.backend.Get(ctx, blindEntriesPrefix+storedPath)
+
+		if err != nil {
+			return nil, err
+		}
+
+		terms := make(map[string][]string, len(entries))
+
+		for _, entry := range entries {
+			terms[entry.Key] = strings.Split(string(entry.Value), ",")
+		}
+
+		pathTerms[storedPath] = terms
+
+		return terms, nil
+	}
+
+	// The refs added to and removed from each term.
+	added := make(map[string]map[string][]byte)
+	removed := make(map[string]map[string]bool)
+
+	removeRef := func(term string, ref string) {
+
+		delete(added[term], ref)
+
+		if removed[term] == nil {
+			removed[term] = make(map[string]bool)
+		}
+
+		removed[term][ref] = true
+	}
+
+	addRef := func(term string, ref string, pathCipher []byte) {
+
+		delete(removed[term], ref)
+
+		if added[term] == nil {
+			added[term] = make(map[string][]byte)
+		}
+
+		added[term][ref] = pathCipher
+	}
+
+	for _, txn := range txns {
+
+		if strings.HasPrefix(txn.Path, KeyringPrefix) {
+			continue
+		}
+
+		storedPath := b.storedPath(txn.Path)
+
+		terms, err := loadTerms(storedPath)
+
+		if err != nil {
+			return nil, err
+		}
+
+		storedKeys := make([]string, 0, len(txn.Entries))
+
+		for _, entry := range txn.Entries {
+			storedKeys = append(storedKeys, b.storedKey(txn.Path, entry.Key))
+		}
+
+		if txn.Operation == backend.DeleteOperation && len(txn.Entries) == 0 {
+			for storedKey := range terms {
+				storedKeys = append(storedKeys, storedKey)
+			}
+		}
+
+		for _, storedKey := range storedKeys {
+
+			for _, term := range terms[storedKey] {
+				removeRef(term, blindRef(storedPath, storedKey))
+			}
+
+			delete(terms, storedKey)
+		}
+
+		if txn.Operation != backend.PutOperation {
+			continue
+		}
+
+		pathCipher, err := b.Encrypt(ctx, []byte(txn.Path))
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt index entry: %s", err.Error())
+		}
+
+		for i, entry := range txn.Entries {
+
+			entryTerms := b.blindTerms(entry.Key, entry.Value)
+
+			for _, term := range entryTerms {
+				addRef(term, blindRef(storedPath, storedKeys[i]), pathCipher)
+			}
+
+			if len(entryTerms) > 0 {
+				terms[storedKeys[i]] = entryTerms
+			}
+		}
+	}
+
+	indexTxns := make([]*backend.TxnEntry, 0)
+
+	for term, refs := range removed {
+
+		if len(refs) == 0 {
+			continue
+		}
+
+		existing, err := b.backend.Get(ctx, blindIndexPrefix+term)
+
+		if err != nil {
+			return nil, err
+		}
+
+		remaining := len(added[term])
+		deleteEntries := make([]*backend.BackendEntry, 0, len(refs))
+
+		for _, entry := range existing {
+			if refs[entry.Key] {
+				deleteEntries = append(deleteEntries, &backend.BackendEntry{Key: entry.Key})
+			} else if _, ok := added[term][entry.Key]; !ok {
+				remaining++
+			}
+		}
+
+		// Terms no longer found in any entry are removed entirely. A delete
+		// without entries removes the whole term, so terms whose removed
+		// refs were never stored are left alone.
+		if remaining == 0 {
+			deleteEntries = nil
+		} else if len(deleteEntries) == 0 {
+			continue
+		}
+
+		indexTxns = append(indexTxns, &backend.TxnEntry{
+			Operation: backend.DeleteOperation,
+			Path:      blindIndexPrefix + term,
+			Entries:   deleteEntries,
+		})
+	}
+
+	for term, refs := range added {
+
+		if len(refs) == 0 {
+			continue
+		}
+
+		putEntries := make([]*backend.BackendEntry, 0, len(refs))
+
+		for ref, pathCipher := range refs {
+			putEntries = append(putEntries, &backend.BackendEntry{
+				Key:   ref,
+				Value: pathCipher,
+			})
+		}
+
+		indexTxns = append(indexTxns, &backend.TxnEntry{
+			Operation: backend.PutOperation,
+			Path:      blindIndexPrefix + term,
+			Entries:   putEntries,
+		})
+	}
+
+	for storedPath, terms := range pathTerms {
+
+		indexTxns = append(indexTxns, &backend.TxnEntry{
+			Operation: backend.DeleteOperation,
+			Path:      blindEntriesPrefix + storedPath,
+		})
+
+		if len(terms) == 0 {
+			continue
+		}
+
+		putEntries := make([]*backend.BackendEntry, 0, len(terms))
+
+		for storedKey, entryTerms := range terms {
+			putEntries = append(putEntries, &backend.BackendEntry{
+				Key:   storedKey,
+				Value: []byte(strings.Join(entryTerms, ",")),
+			})
+		}
+
+		indexTxns = append(indexTxns, &backend.TxnEntry{
+			Operation: backend.PutOperation,
+			Path:      blindEntriesPrefix + storedPath,
+			Entries:   putEntries,
+		})
+	}
+
+	return indexTxns, nil
+}
+
+// rebuildBlindIndexTxns reports the operations removing the blind index,
+// followed by the operations indexing every stored path again if the blind
+// index is enabled.
+//
+// The removal comes first within the same transaction, so the index holds
+// only the refs of the stored paths once applied. Removing refs from terms
+// which were already removed has no effect.
+func (b *Barrier) rebuildBlindIndexTxns(ctx context.Context) ([]*backend.TxnEntry, error) {
+
+	barrierPaths, err := b.backend.List(ctx, KeyringPrefix)
+
+	if err != nil {
+		return nil, err
+	}
+
+	txns := make([]*backend.TxnEntry, 0)
+
+	for _, path := range barrierPaths {
+		if strings.HasPrefix(path, blindIndexPrefix) || strings.HasPrefix(path, blindEntriesPrefix) {
+			txns = append(txns, &backend.TxnEntry{
+				Operation: backend.DeleteOperation,
+				Path:      path,
+			})
+		}
+	}
+
+	if !b.config.BlindIndex {
+		return txns, nil
+	}
+
+	var paths []string
+
+	if b.config.ConfidentialNames {
+		paths, err = b.listIndex(ctx, "")
+	} else {
+		paths, err = b.backend.List(ctx, "")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	putTxns := make([]*backend.TxnEntry, 0, len(paths))
+
+	for _, path := range paths {
+
+		if strings.HasPrefix(path, KeyringPrefix) {
+			continue
+		}
+
+		entries, err := b.get(ctx, path)
+
+		if err != nil {
+			return nil, err
+		}
+
+		putTxns = append(putTxns, &backend.TxnEntry{
+			Operation: backend.PutOperation,
+			Path:      path,
+			Entries:   entries,
+		})
+	}
+
+	indexTxns, err := b.blindIndexTxns(ctx, putTxns)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to build blind index: %s", err.Error())
+	}
+
+	return append(txns, indexTxns...), nil
+}
@@ -0,0 +1,469 @@
+package internal
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/woodrufj4/keyring-practice/backend"
+)
+
+// DefaultChunkSize is the largest value sealed as a single ciphertext.
+// Larger values are split into chunks, as bbolt handles multi-megabyte
+// values poorly.
+const DefaultChunkSize = 1 << 20
+
+// The kind of value is recorded in the low bits of the last byte of the
+// cipher text header, along with flags describing how the value was encoded
+// in the high bits. The byte is always zero for values written before
+// chunking.
+const (
+	valueKindPlain    byte = 0
+	valueKindManifest byte = 1
+	valueKindChunk    byte = 2
+
+	valueKindMask byte = 0x0f
+
+	// valueFlagPadded marks values padded before sealing.
+	valueFlagPadded byte = 0x10
+)
+
+// chunkKeySeparator separates the key of a chunked value from the index of
+// the chunk. Keys containing it are reserved for chunks.
+const chunkKeySeparator = "\x00chunk\x00"
+
+// chunkIDSize is the size of the random identifier binding chunks to
+// their manifest.
+const chunkIDSize = 16
+
+var (
+	ErrChunkMissing = errors.New("chunked value is missing chunks")
+	ErrChunkInvalid = errors.New("chunked value is invalid")
+	ErrReservedKey  = errors.New("key is reserved")
+)
+
+// chunkManifest describes how a chunked value was split. The manifest is
+// stored encrypted in place of the value, while each chunk is stored under
+// its own key.
+//
+// Every chunk authenticates the manifest id, its index and the number of
+// chunks, so chunks which are reordered, replayed from another value or
+// dropped fail to decrypt.
+type chunkManifest struct {
+	ID     []byte `json:"id"`
+	Chunks int    `json:"chunks"`
+	Size   int    `json:"size"`
+}
+
+// chunkKey is the key of a chunk of the value stored at key.
+func chunkKey(key string, index int) string {
+	return key + chunkKeySeparator + strconv.Itoa(index)
+}
+
+func isChunkKey(key string) bool {
+	return strings.Contains(key, chunkKeySeparator)
+}
+
+// chunkKeys reports the keys of the existing chunks of the value at key.
+func chunkKeys(existing map[string]bool, key string) []string {
+
+	keys := make([]string, 0)
+
+	for existingKey := range existing {
+		if strings.HasPrefix(existingKey, key+chunkKeySeparator) {
+			keys = append(keys, existingKey)
+		}
+	}
+
+	return keys
+}
+
+// chunkAdditionalData binds a chunk to its manifest and position.
+func chunkAdditionalData(manifest *chunkManifest, index int) []byte {
+
+	additionalData := make([]byte, len(manifest.ID)+8)
+
+	copy(additionalData, manifest.ID)
+	binary.BigEndian.PutUint32(additionalData[len(manifest.ID):], uint32(index))
+	binary.BigEndian.PutUint32(additionalData[len(manifest.ID)+4:], uint32(manifest.Chunks))
+
+	return additionalData
+}
+
+// valueKind reports the kind of value from the cipher text header.
+func valueKind(cipher []byte) byte {
+	return valueHeader(cipher) & valueKindMask
+}
+
+// valueFlags reports the encoding flags from the cipher text header.
+func valueFlags(cipher []byte) byte {
+	return valueHeader(cipher) &^ valueKindMask
+}
+
+func valueHeader(cipher []byte) byte {
+
+	if len(cipher) < termSize {
+		return valueKindPlain
+	}
+
+	return cipher[termSize-1]
+}
+
+// sealEntry encrypts the value to store under the key at the path,
+// splitting values larger than the chunk size into a manifest and its
+// chunks. The value is compressed and then padded first when configured.
+func (b *Barrier) sealEntry(path string, key string, value []byte) ([]*backend.BackendEntry, error) {
+
+	var flags byte
+
+	if b.config.compressPath(path) && len(value) >= b.config.compressionThreshold() {
+
+		compressed, flag, err := compressValue(b.config.Compression, value)
+
+		if err != nil {
+			return nil, err
+		}
+
+		value = compressed
+		flags |= flag
+	}
+
+	if paddingEnabled(b.config.Padding) {
+		value = padValue(b.config.Padding, value)
+		flags |= valueFlagPadded
+	}
+
+	term := b.keyring.activeTerm
+
+	gcm, err := b.aesFromTerm(term)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(value) <= b.chunkSize {
+
+		cipher, err := b.seal(gcm, term, valueKindPlain|flags, value, nil)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return []*backend.BackendEntry{{Key: key, Value: cipher}}, nil
+	}
+
+	manifest := &chunkManifest{
+		ID:     make([]byte, chunkIDSize),
+		Chunks: (len(value) + b.chunkSize - 1) / b.chunkSize,
+		Size:   len(value),
+	}
+
+	if _, err := rand.Read(manifest.ID); err != nil {
+		return nil, fmt.Errorf("failed to generate chunk id: %s", err.Error())
+	}
+
+	manifestBytes, err := json.Marshal(manifest)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode chunk manifest: %s", err.Error())
+	}
+
+	manifestCipher, err := b.seal(gcm, term, valueKindManifest|flags, manifestBytes, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	sealed := make([]*backend.BackendEntry, 0, manifest.Chunks+1)
+
+	sealed = append(sealed, &backend.BackendEntry{
+		Key:   key,
+		Value: manifestCipher,
+	})
+
+	for index := 0; index < manifest.Chunks; index++ {
+
+		start := index * b.chunkSize
+		end := start + b.chunkSize
+
+		if end > len(value) {
+			end = len(value)
+		}
+
+		chunkCipher, err := b.seal(gcm, term, valueKindChunk, value[start:end], chunkAdditionalData(manifest, index))
+
+		if err != nil {
+			return nil, err
+		}
+
+		sealed = append(sealed, &backend.BackendEntry{
+			Key:   chunkKey(key, index),
+			Value: chunkCipher,
+		})
+	}
+
+	return sealed, nil
+}
+
+// openEntry decrypts the value stored under the key, reassembling chunked
+// values, stripping any padding and decompressing.
+func (b *Barrier) openEntry(key string, cipher []byte, chunks map[string][]byte) ([]byte, error) {
+
+	var plaintext []byte
+	var err error
+
+	switch valueKind(cipher) {
+
+	case valueKindPlain:
+		plaintext, err = b.openTracked(cipher, nil)
+
+	case valueKindManifest:
+		plaintext, err = b.openChunked(key, cipher, chunks)
+
+	default:
+		return nil, fmt.Errorf("%w: unexpected value kind %d", ErrChunkInvalid, valueKind(cipher))
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if valueFlags(cipher)&valueFlagPadded != 0 {
+
+		plaintext, err = unpadValue(plaintext)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return decompressValue(valueFlags(cipher), plaintext)
+}
+
+// openChunked decrypts the manifest stored at key, reassembling the value
+// from its chunks.
+func (b *Barrier) openChunked(key string, manifestCipher []byte, chunks map[string][]byte) ([]byte, error) {
+
+	manifestBytes, err := b.openTracked(manifestCipher, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest chunkManifest
+
+	if err := json.Unmarshal(manifestBytes, &manifest); err != nil {
+		return nil, fmt.Errorf("%w: failed to decode manifest: %s", ErrChunkInvalid, err.Error())
+	}
+
+	if manifest.Chunks <= 0 || manifest.Size < 0 {
+		return nil, fmt.Errorf("%w: manifest has %d chunks of %d bytes", ErrChunkInvalid, manifest.Chunks, manifest.Size)
+	}
+
+	plaintext := make([]byte, 0, manifest.Size)
+
+	for index := 0; index < manifest.Chunks; index++ {
+
+		chunkCipher, ok := chunks[chunkKey(key, index)]
+
+		if !ok {
+			return nil, fmt.Errorf("%w: chunk %d of %d not found", ErrChunkMissing, index+1, manifest.Chunks)
+		}
+
+		if valueKind(chunkCipher) != valueKindChunk {
+			return nil, fmt.Errorf("%w: chunk %d is not a chunk", ErrChunkInvalid, index+1)
+		}
+
+		chunk, err := b.openTracked(chunkCipher, chunkAdditionalData(&manifest, index))
+
+		if err != nil {
+			return nil, fmt.Errorf("%w: chunk %d of %d failed to decrypt: %s", ErrChunkInvalid, index+1, manifest.Chunks, err.Error())
+		}
+
+		plaintext = append(plaintext, chunk...)
+	}
+
+	if len(plaintext) != manifest.Size {
+		return nil, fmt.Errorf("%w: expected %d bytes, but reassembled %d", ErrChunkInvalid, manifest.Size, len(plaintext))
+	}
+
+	return plaintext, nil
+}
+
+// openTracked decrypts the cipher text with the key of the term recorded
+// in its header.
+func (b *Barrier) openTracked(cipher []byte, additionalData []byte) ([]byte, error) {
+
+	if len(cipher) < termSize {
+		return nil, fmt.Errorf("length of ciphertext is invalid")
+	}
+
+	gcm, err := b.aesFromTerm(binary.BigEndian.Uint32(cipher[:termSize]))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return b.open(gcm, cipher, additionalData)
+}
+
+// sealTxns encrypts the entries of the put operations, and removes the
+// chunks of any overwritten or deleted value. With confidential names, the
+// paths and keys are replaced by their stored names, and the encrypted
+// index is updated along with the paths.
+//
+// The existing keys at each path are read once, and then tracked as the
+// operations are applied, so the chunks of values written earlier within
+// the same transaction are also removed.
+func (b *Barrier) sealTxns(ctx context.Context, txns []*backend.TxnEntry) ([]*backend.TxnEntry, error) {
+
+	existing := make(map[string]map[string]bool)
+
+	existingKeys := func(path string) (map[string]bool, error) {
+
+		if keys, ok := existing[path]; ok {
+			return keys, nil
+		}
+
+		entries, err := b.backend.Get(ctx, path)
+
+		if err != nil {
+			return nil, err
+		}
+
+		keys := make(map[string]bool, len(entries))
+
+		for _, entry := range entries {
+			keys[entry.Key] = true
+		}
+
+		existing[path] = keys
+
+		return keys, nil
+	}
+
+	sealed := make([]*backend.TxnEntry, 0, len(txns))
+
+	for _, txn := range txns {
+
+		storedPath := b.storedPath(txn.Path)
+
+		if txn.Operation == backend.DeleteOperation && len(txn.Entries) == 0 {
+
+			existing[storedPath] = make(map[string]bool)
+
+			sealed = append(sealed, &backend.TxnEntry{
+				Operation: txn.Operation,
+				Path:      storedPath,
+			})
+
+			if b.confidentialPath(txn.Path) {
+				sealed = append(sealed, b.unindexTxn(txn.Path))
+			}
+
+			continue
+		}
+
+		keys, err := existingKeys(storedPath)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if txn.Operation != backend.PutOperation {
+
+			deleteEntries := make([]*backend.BackendEntry, 0, len(txn.Entries))
+
+			for _, entry := range txn.Entries {
+
+				storedKey := b.storedKey(txn.Path, entry.Key)
+
+				for _, key := range append(chunkKeys(keys, storedKey), storedKey) {
+					deleteEntries = append(deleteEntries, &backend.BackendEntry{Key: key})
+					delete(keys, key)
+				}
+			}
+
+			sealed = append(sealed, &backend.TxnEntry{
+				Operation: txn.Operation,
+				Path:      storedPath,
+				Entries:   deleteEntries,
+			})
+			continue
+		}
+
+		putEntries := make([]*backend.BackendEntry, 0, len(txn.Entries))
+		deleteEntries := make([]*backend.BackendEntry, 0)
+
+		for _, entry := range txn.Entries {
+
+			if strings.Contains(entry.Key, "\x00") {
+				return nil, fmt.Errorf("%w: %q contains a NUL byte", ErrReservedKey, entry.Key)
+			}
+
+			storedKey := b.storedKey(txn.Path, entry.Key)
+			value := entry.Value
+
+			if b.confidentialPath(txn.Path) {
+				value = encodeNamedValue(entry.Key, value)
+			}
+
+			entrySealed, err := b.sealEntry(txn.Path, storedKey, value)
+
+			if err != nil {
+				return nil, err
+			}
+
+			written := make(map[string]bool, len(entrySealed))
+
+			for _, sealedEntry := range entrySealed {
+				written[sealedEntry.Key] = true
+			}
+
+			for _, key := range chunkKeys(keys, storedKey) {
+				if !written[key] {
+					deleteEntries = append(deleteEntries, &backend.BackendEntry{Key: key})
+					delete(keys, key)
+				}
+			}
+
+			for key := range written {
+				keys[key] = true
+			}
+
+			putEntries = append(putEntries, entrySealed...)
+		}
+
+		if len(deleteEntries) > 0 {
+			sealed = append(sealed, &backend.TxnEntry{
+				Operation: backend.DeleteOperation,
+				Path:      storedPath,
+				Entries:   deleteEntries,
+			})
+		}
+
+		sealed = append(sealed, &backend.TxnEntry{
+			Operation: backend.PutOperation,
+			Path:      storedPath,
+			Entries:   putEntries,
+		})
+
+		if b.confidentialPath(txn.Path) {
+
+			indexTxn, err := b.indexTxn(txn.Path)
+
+			if err != nil {
+				return nil, err
+			}
+
+			sealed = append(sealed, indexTxn)
+		}
+	}
+
+	return sealed, nil
+}
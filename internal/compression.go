@@ -0,0 +1,165 @@
+package internal
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	CompressionNone = "none"
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+)
+
+// DefaultCompressionThreshold is the smallest value compressed when no
+// threshold is configured. Smaller values rarely shrink.
+const DefaultCompressionThreshold = 256
+
+// The compression of a value is recorded in the flags of the cipher text
+// header.
+const (
+	valueFlagGzip byte = 0x20
+	valueFlagZstd byte = 0x40
+
+	valueCompressionMask = valueFlagGzip | valueFlagZstd
+)
+
+var ErrCompressionInvalid = errors.New("compressed value is invalid")
+
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+// ValidateCompression checks the compression scheme is supported.
+func ValidateCompression(scheme string) error {
+
+	switch scheme {
+	case "", CompressionNone, CompressionGzip, CompressionZstd:
+		return nil
+	default:
+		return fmt.Errorf("unsupported compression '%s'. Must be one of none, gzip or zstd", scheme)
+	}
+}
+
+// compressionThreshold is the smallest value compressed with the config.
+func (c *BarrierConfig) compressionThreshold() int {
+
+	if c.CompressionThreshold > 0 {
+		return c.CompressionThreshold
+	}
+
+	return DefaultCompressionThreshold
+}
+
+// compressPath reports if values at the path are compressed. Paths under
+// an excluded prefix are never compressed, as compressing secrets alongside
+// attacker controlled data can leak them through the compressed length.
+func (c *BarrierConfig) compressPath(path string) bool {
+
+	if c.Compression != CompressionGzip && c.Compression != CompressionZstd {
+		return false
+	}
+
+	if isBarrierPath(path) {
+		return false
+	}
+
+	for _, prefix := range c.CompressionExclude {
+		if strings.HasPrefix(path, prefix) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// compressValue compresses the value with the scheme, reporting the flag
+// recording the compression. Values which do not shrink are kept as is,
+// without a flag.
+func compressValue(scheme string, value []byte) ([]byte, byte, error) {
+
+	var compressed []byte
+	var flag byte
+
+	switch scheme {
+
+	case CompressionGzip:
+
+		var buffer bytes.Buffer
+
+		writer := gzip.NewWriter(&buffer)
+
+		if _, err := writer.Write(value); err != nil {
+			return nil, 0, fmt.Errorf("failed to compress value: %s", err.Error())
+		}
+
+		if err := writer.Close(); err != nil {
+			return nil, 0, fmt.Errorf("failed to compress value: %s", err.Error())
+		}
+
+		compressed = buffer.Bytes()
+		flag = valueFlagGzip
+
+	case CompressionZstd:
+		compressed = zstdEncoder.EncodeAll(value, nil)
+		flag = valueFlagZstd
+
+	default:
+		return value, 0, nil
+	}
+
+	if len(compressed) >= len(value) {
+		return value, 0, nil
+	}
+
+	return compressed, flag, nil
+}
+
+// decompressValue reverses compressValue, given the flags of the cipher
+// text header.
+func decompressValue(flags byte, value []byte) ([]byte, error) {
+
+	switch flags & valueCompressionMask {
+
+	case 0:
+		return value, nil
+
+	case valueFlagGzip:
+
+		reader, err := gzip.NewReader(bytes.NewReader(value))
+
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrCompressionInvalid, err.Error())
+		}
+
+		defer reader.Close()
+
+		decompressed, err := io.ReadAll(reader)
+
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrCompressionInvalid, err.Error())
+		}
+
+		return decompressed, nil
+
+	case valueFlagZstd:
+
+		decompressed, err := zstdDecoder.DecodeAll(value, nil)
+
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrCompressionInvalid, err.Error())
+		}
+
+		return decompressed, nil
+
+	default:
+		return nil, fmt.Errorf("%w: unexpected compression flags %#x", ErrCompressionInvalid, flags&valueCompressionMask)
+	}
+}
@@ -0,0 +1,159 @@
+package internal
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/woodrufj4/keyring-practice/backend"
+)
+
+const (
+
+	// barrierIndexPath holds the encrypted names of the paths stored with
+	// confidential names, keyed by their stored name.
+	barrierIndexPath = "core/index"
+
+	pathNamePurpose = "barrier-path-names"
+	keyNamePurpose  = "barrier-key-names"
+)
+
+var ErrNamedValueInvalid = errors.New("stored value is missing its key name")
+
+// storedPath is the name the path is stored under within the backend.
+// With confidential names, this is a keyed HMAC of the path.
+func (b *Barrier) storedPath(path string) string {
+
+	if !b.config.ConfidentialNames || isBarrierPath(path) {
+		return path
+	}
+
+	return nameHMAC(b.keyring.DeriveKey(pathNamePurpose), path)
+}
+
+// storedKey is the key the entry is stored under within the backend. With
+// confidential names, this is a keyed HMAC of the key, bound to the path so
+// the same key at different paths can not be correlated.
+func (b *Barrier) storedKey(path string, key string) string {
+
+	if !b.config.ConfidentialNames || isBarrierPath(path) {
+		return key
+	}
+
+	return nameHMAC(b.keyring.DeriveKey(keyNamePurpose), path+"\x00"+key)
+}
+
+// confidentialPath reports if the names of the path are hidden.
+func (b *Barrier) confidentialPath(path string) bool {
+	return b.config.ConfidentialNames && !isBarrierPath(path)
+}
+
+func nameHMAC(key []byte, name string) string {
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(name))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// encodeNamedValue prefixes the value with its key, so the key can be
+// recovered once the value is decrypted.
+func encodeNamedValue(key string, value []byte) []byte {
+
+	named := make([]byte, binary.MaxVarintLen64, binary.MaxVarintLen64+len(key)+len(value))
+
+	n := binary.PutUvarint(named, uint64(len(key)))
+
+	named = append(named[:n], key...)
+
+	return append(named, value...)
+}
+
+func decodeNamedValue(named []byte) (string, []byte, error) {
+
+	size, n := binary.Uvarint(named)
+
+	if n <= 0 || uint64(len(named)-n) < size {
+		return "", nil, ErrNamedValueInvalid
+	}
+
+	return string(named[n : n+int(size)]), named[n+int(size):], nil
+}
+
+// indexTxn records the path within the encrypted index.
+func (b *Barrier) indexTxn(path string) (*backend.TxnEntry, error) {
+
+	pathCipher, err := b.Encrypt(context.Background(), []byte(path))
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt index entry: %s", err.Error())
+	}
+
+	return &backend.TxnEntry{
+		Operation: backend.PutOperation,
+		Path:      barrierIndexPath,
+		Entries: []*backend.BackendEntry{
+			{
+				Key:   b.storedPath(path),
+				Value: pathCipher,
+			},
+		},
+	}, nil
+}
+
+// unindexTxn removes the path from the encrypted index.
+func (b *Barrier) unindexTxn(path string) *backend.TxnEntry {
+	return &backend.TxnEntry{
+		Operation: backend.DeleteOperation,
+		Path:      barrierIndexPath,
+		Entries:   []*backend.BackendEntry{{Key: b.storedPath(path)}},
+	}
+}
+
+// listIndex reports the paths within the encrypted index that start with
+// the prefix, along with any paths managed by the barrier itself.
+func (b *Barrier) listIndex(ctx context.Context, prefix string) ([]string, error) {
+
+	entries, err := b.backend.Get(ctx, barrierIndexPath)
+
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(entries))
+
+	for _, entry := range entries {
+
+		path, err := b.Decrypt(ctx, entry.Value)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt index entry: %s", err.Error())
+		}
+
+		if strings.HasPrefix(string(path), prefix) {
+			paths = append(paths, string(path))
+		}
+	}
+
+	barrierPaths, err := b.backend.List(ctx, prefix)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, path := range barrierPaths {
+		if isBarrierPath(path) {
+			paths = append(paths, path)
+		}
+	}
+
+	sort.Strings(paths)
+
+	return paths, nil
+}
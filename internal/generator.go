@@ -0,0 +1,403 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	_ "embed"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	GeneratorKindPassword   = "password"
+	GeneratorKindPassphrase = "passphrase"
+	GeneratorKindHex        = "hex"
+	GeneratorKindBase64     = "base64"
+	GeneratorKindUUID       = "uuid"
+)
+
+// GeneratorCharsets are the character classes passwords are drawn from.
+var GeneratorCharsets = map[string]string{
+	"lower":  "abcdefghijklmnopqrstuvwxyz",
+	"upper":  "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+	"digit":  "0123456789",
+	"symbol": "!#$%&()*+,-./:;<=>?@[]^_{|}~",
+}
+
+// GeneratorPolicies are the named policies available to keyring generate.
+var GeneratorPolicies = map[string]*GeneratorPolicy{
+	"password": {
+		Kind:   GeneratorKindPassword,
+		Length: 24,
+		Charsets: []*CharsetRule{
+			{Name: "lower", Min: 1},
+			{Name: "upper", Min: 1},
+			{Name: "digit", Min: 1},
+			{Name: "symbol", Min: 1},
+		},
+	},
+	"alphanumeric": {
+		Kind:   GeneratorKindPassword,
+		Length: 32,
+		Charsets: []*CharsetRule{
+			{Name: "lower"},
+			{Name: "upper"},
+			{Name: "digit"},
+		},
+	},
+	"pin": {
+		Kind:   GeneratorKindPassword,
+		Length: 6,
+		Charsets: []*CharsetRule{
+			{Name: "digit"},
+		},
+	},
+	"passphrase": {
+		Kind:      GeneratorKindPassphrase,
+		Length:    6,
+		Separator: "-",
+	},
+	"hex": {
+		Kind:   GeneratorKindHex,
+		Length: 32,
+	},
+	"base64": {
+		Kind:   GeneratorKindBase64,
+		Length: 32,
+	},
+	"uuid": {
+		Kind: GeneratorKindUUID,
+	},
+}
+
+// effLargeWordlist is the EFF large wordlist for diceware passphrases,
+// published under CC BY 3.0:
+// https://www.eff.org/deeplinks/2016/07/new-wordlists-random-passphrases
+//
+//go:embed eff_large_wordlist.txt
+var effLargeWordlist []byte
+
+// CharsetRule requires a minimum number of characters from a class.
+// Classes with a minimum of 0 are still drawn from.
+type CharsetRule struct {
+	Name string
+	Min  int
+}
+
+// GeneratorPolicy describes how a value is generated.
+type GeneratorPolicy struct {
+	Kind string
+
+	// Length is the number of characters of passwords, the number of
+	// words of passphrases, or the number of random bytes of hex and
+	// base64 tokens. UUIDs have a fixed length.
+	Length int
+
+	// Charsets are the character classes of passwords.
+	Charsets []*CharsetRule
+
+	// Separator joins the words of passphrases.
+	Separator string
+}
+
+// GeneratorPolicyNames reports the names of the policies in order.
+func GeneratorPolicyNames() []string {
+
+	names := make([]string, 0, len(GeneratorPolicies))
+
+	for name := range GeneratorPolicies {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// ParseCharsetRules parses comma separated character classes, each with an
+// optional minimum such as "lower:2,upper:2,digit".
+func ParseCharsetRules(raw string) ([]*CharsetRule, error) {
+
+	rules := make([]*CharsetRule, 0)
+
+	for _, part := range strings.Split(raw, ",") {
+
+		name, rawMin, hasMin := strings.Cut(strings.TrimSpace(part), ":")
+
+		rule := &CharsetRule{Name: name}
+
+		if hasMin {
+
+			min, err := strconv.Atoi(rawMin)
+
+			if err != nil || min < 0 {
+				return nil, fmt.Errorf("invalid minimum for charset '%s'", name)
+			}
+
+			rule.Min = min
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// Clone copies the policy, so it can be adjusted without changing the
+// named policies.
+func (p *GeneratorPolicy) Clone() *GeneratorPolicy {
+
+	clone := *p
+	clone.Charsets = make([]*CharsetRule, 0, len(p.Charsets))
+
+	for _, rule := range p.Charsets {
+		clone.Charsets = append(clone.Charsets, &CharsetRule{Name: rule.Name, Min: rule.Min})
+	}
+
+	return &clone
+}
+
+// Validate checks the policy can generate a value.
+func (p *GeneratorPolicy) Validate() error {
+
+	switch p.Kind {
+
+	case GeneratorKindPassword:
+
+		if len(p.Charsets) == 0 {
+			return fmt.Errorf("passwords require at least one charset")
+		}
+
+		required := 0
+		seen := make(map[string]bool, len(p.Charsets))
+
+		for _, rule := range p.Charsets {
+
+			if _, ok := GeneratorCharsets[rule.Name]; !ok {
+				return fmt.Errorf("unknown charset '%s'. Must be one of lower, upper, digit or symbol", rule.Name)
+			}
+
+			if seen[rule.Name] {
+				return fmt.Errorf("charset '%s' is repeated", rule.Name)
+			}
+
+			seen[rule.Name] = true
+			required += rule.Min
+		}
+
+		if p.Length <= 0 {
+			return fmt.Errorf("length must be greater than 0")
+		}
+
+		if required > p.Length {
+			return fmt.Errorf("the charsets require %d characters, but the length is %d", required, p.Length)
+		}
+
+	case GeneratorKindPassphrase, GeneratorKindHex, GeneratorKindBase64:
+
+		if p.Length <= 0 {
+			return fmt.Errorf("length must be greater than 0")
+		}
+
+	case GeneratorKindUUID:
+
+	default:
+		return fmt.Errorf("unknown generator kind '%s'", p.Kind)
+	}
+
+	return nil
+}
+
+// Generate draws a new value from crypto/rand.
+func (p *GeneratorPolicy) Generate() (string, error) {
+
+	if err := p.Validate(); err != nil {
+		return "", err
+	}
+
+	switch p.Kind {
+
+	case GeneratorKindPassword:
+		return p.generatePassword()
+
+	case GeneratorKindPassphrase:
+		return p.generatePassphrase()
+
+	case GeneratorKindHex:
+
+		raw, err := randomBytes(p.Length)
+
+		if err != nil {
+			return "", err
+		}
+
+		return hex.EncodeToString(raw), nil
+
+	case GeneratorKindBase64:
+
+		raw, err := randomBytes(p.Length)
+
+		if err != nil {
+			return "", err
+		}
+
+		return base64.StdEncoding.EncodeToString(raw), nil
+
+	default:
+		return generateUUID()
+	}
+}
+
+// generatePassword draws the required characters of each charset, fills
+// the remaining length from every charset, and then shuffles the result so
+// the required characters are not in predictable positions.
+func (p *GeneratorPolicy) generatePassword() (string, error) {
+
+	password := make([]byte, 0, p.Length)
+
+	var all strings.Builder
+
+	for _, rule := range p.Charsets {
+
+		charset := GeneratorCharsets[rule.Name]
+
+		all.WriteString(charset)
+
+		for i := 0; i < rule.Min; i++ {
+
+			char, err := randomChar(charset)
+
+			if err != nil {
+				return "", err
+			}
+
+			password = append(password, char)
+		}
+	}
+
+	for len(password) < p.Length {
+
+		char, err := randomChar(all.String())
+
+		if err != nil {
+			return "", err
+		}
+
+		password = append(password, char)
+	}
+
+	for i := len(password) - 1; i > 0; i-- {
+
+		j, err := randomIndex(i + 1)
+
+		if err != nil {
+			return "", err
+		}
+
+		password[i], password[j] = password[j], password[i]
+	}
+
+	return string(password), nil
+}
+
+func (p *GeneratorPolicy) generatePassphrase() (string, error) {
+
+	wordlist, err := dicewareWords()
+
+	if err != nil {
+		return "", err
+	}
+
+	words := make([]string, 0, p.Length)
+
+	for i := 0; i < p.Length; i++ {
+
+		index, err := randomIndex(len(wordlist))
+
+		if err != nil {
+			return "", err
+		}
+
+		words = append(words, wordlist[index])
+	}
+
+	return strings.Join(words, p.Separator), nil
+}
+
+// dicewareWords parses the embedded wordlist of dice rolls and words.
+func dicewareWords() ([]string, error) {
+
+	words := make([]string, 0, 7776)
+
+	scanner := bufio.NewScanner(bytes.NewReader(effLargeWordlist))
+
+	for scanner.Scan() {
+
+		_, word, ok := strings.Cut(scanner.Text(), "\t")
+
+		if !ok {
+			return nil, fmt.Errorf("invalid wordlist line '%s'", scanner.Text())
+		}
+
+		words = append(words, word)
+	}
+
+	return words, scanner.Err()
+}
+
+// generateUUID generates a random version 4 UUID.
+func generateUUID() (string, error) {
+
+	raw, err := randomBytes(16)
+
+	if err != nil {
+		return "", err
+	}
+
+	raw[6] = (raw[6] & 0x0f) | 0x40
+	raw[8] = (raw[8] & 0x3f) | 0x80
+
+	encoded := hex.EncodeToString(raw)
+
+	return encoded[0:8] + "-" + encoded[8:12] + "-" + encoded[12:16] + "-" + encoded[16:20] + "-" + encoded[20:], nil
+}
+
+func randomBytes(size int) ([]byte, error) {
+
+	buf := make([]byte, size)
+
+	if _, err := rand.Read(buf); err != nil {
+		return nil, fmt.Errorf("failed to read random bytes: %s", err.Error())
+	}
+
+	return buf, nil
+}
+
+// randomIndex draws a uniformly random index below n.
+func randomIndex(n int) (int, error) {
+
+	index, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to read random bytes: %s", err.Error())
+	}
+
+	return int(index.Int64()), nil
+}
+
+func randomChar(charset string) (byte, error) {
+
+	index, err := randomIndex(len(charset))
+
+	if err != nil {
+		return 0, err
+	}
+
+	return charset[index], nil
+}
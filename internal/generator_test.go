@@ -0,0 +1,127 @@
+package internal
+
+import (
+	"encoding/base64"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestGeneratorPolicies(t *testing.T) {
+
+	for _, name := range GeneratorPolicyNames() {
+
+		value, err := GeneratorPolicies[name].Generate()
+
+		if err != nil {
+			t.Fatalf("failed to generate %s: %s", name, err.Error())
+		}
+
+		if value == "" {
+			t.Fatalf("expected %s to generate a value", name)
+		}
+	}
+
+	password, _ := GeneratorPolicies["password"].Generate()
+
+	if len(password) != 24 {
+		t.Fatalf("expected a 24 character password, but got %q", password)
+	}
+
+	for _, charset := range []string{"lower", "upper", "digit", "symbol"} {
+		if !strings.ContainsAny(password, GeneratorCharsets[charset]) {
+			t.Fatalf("expected password %q to contain a %s character", password, charset)
+		}
+	}
+
+	pin, _ := GeneratorPolicies["pin"].Generate()
+
+	if !regexp.MustCompile(`^[0-9]{6}$`).MatchString(pin) {
+		t.Fatalf("expected a 6 digit pin, but got %q", pin)
+	}
+
+	passphrase, _ := GeneratorPolicies["passphrase"].Generate()
+
+	if words := strings.Split(passphrase, "-"); len(words) < 6 {
+		t.Fatalf("expected at least 6 words, but got %q", passphrase)
+	}
+
+	token, _ := GeneratorPolicies["hex"].Generate()
+
+	if !regexp.MustCompile(`^[0-9a-f]{64}$`).MatchString(token) {
+		t.Fatalf("expected a 32 byte hex token, but got %q", token)
+	}
+
+	encoded, _ := GeneratorPolicies["base64"].Generate()
+
+	if raw, err := base64.StdEncoding.DecodeString(encoded); err != nil || len(raw) != 32 {
+		t.Fatalf("expected a 32 byte base64 token, but got %q", encoded)
+	}
+
+	uuid, _ := GeneratorPolicies["uuid"].Generate()
+
+	if !regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(uuid) {
+		t.Fatalf("expected a version 4 uuid, but got %q", uuid)
+	}
+}
+
+func TestGeneratorCharsetRules(t *testing.T) {
+
+	rules, err := ParseCharsetRules("digit:4,upper:4")
+
+	if err != nil {
+		t.Fatalf("failed to parse charset rules: %s", err.Error())
+	}
+
+	policy := &GeneratorPolicy{
+		Kind:     GeneratorKindPassword,
+		Length:   8,
+		Charsets: rules,
+	}
+
+	for i := 0; i < 20; i++ {
+
+		password, err := policy.Generate()
+
+		if err != nil {
+			t.Fatalf("failed to generate password: %s", err.Error())
+		}
+
+		if len(regexp.MustCompile(`[0-9]`).FindAllString(password, -1)) != 4 {
+			t.Fatalf("expected exactly 4 digits in %q", password)
+		}
+
+		if len(regexp.MustCompile(`[A-Z]`).FindAllString(password, -1)) != 4 {
+			t.Fatalf("expected exactly 4 upper case characters in %q", password)
+		}
+	}
+
+	policy.Length = 7
+
+	if _, err := policy.Generate(); err == nil {
+		t.Fatal("expected required counts exceeding the length to fail")
+	}
+
+	if _, err := ParseCharsetRules("lower:-1"); err == nil {
+		t.Fatal("expected a negative minimum to fail")
+	}
+
+	policy.Charsets = []*CharsetRule{{Name: "emoji"}}
+
+	if err := policy.Validate(); err == nil {
+		t.Fatal("expected an unknown charset to fail")
+	}
+}
+
+func TestDicewareWords(t *testing.T) {
+
+	words, err := dicewareWords()
+
+	if err != nil {
+		t.Fatalf("failed to parse wordlist: %s", err.Error())
+	}
+
+	if len(words) != 7776 {
+		t.Fatalf("expected 7776 words, but got %d", len(words))
+	}
+}
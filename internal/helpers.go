@@ -25,13 +25,30 @@ func ParseArgsData(stdin io.Reader, args []string) (map[string]interface{}, erro
 
 }
 
+// ReadConfig parses the general and backend configuration from the
+// provided args.
 func ReadConfig(args []string) (*GeneralConfig, *flag.FlagSet, error) {
 
+	fs := flag.NewFlagSet("put", flag.ContinueOnError)
+
+	config, err := ReadFlagSetConfig(fs, args)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return config, fs, nil
+}
+
+// ReadFlagSetConfig registers the general and backend flags on the provided
+// flag set before parsing the args. This allows commands to register their
+// own options on the flag set prior to reading the config.
+func ReadFlagSetConfig(fs *flag.FlagSet, args []string) (*GeneralConfig, error) {
+
 	config := &GeneralConfig{
 		Backend: &BackendConfig{},
 	}
 
-	fs := flag.NewFlagSet("put", flag.ContinueOnError)
 	fs.StringVar(&config.RootToken, "root-token", "", "The root token to use for encryption")
 	fs.StringVar(&config.Backend.Type, "backend-type", "", "The type of backend to use")
 
@@ -40,7 +57,7 @@ func ReadConfig(args []string) (*GeneralConfig, *flag.FlagSet, error) {
 	fs.StringVar(&fileConfig.Path, "filepath", "", "The file path to the local datastore")
 
 	if err := fs.Parse(args); err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
 	config = DefaultGeneralConfig().Merge(config)
@@ -48,16 +65,16 @@ func ReadConfig(args []string) (*GeneralConfig, *flag.FlagSet, error) {
 	backendType, err := config.Backend.GetBackendType()
 
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
 	if backendType == backend.FileBackend {
 		config.Backend.Options = bbolt.DefaultConfig().Merge(fileConfig)
 	} else {
-		return nil, nil, fmt.Errorf("backend options not setup for backend type '%s'", backendType)
+		return nil, fmt.Errorf("backend options not setup for backend type '%s'", backendType)
 	}
 
-	return config, fs, nil
+	return config, nil
 }
 
 func SetupBackend(ctx context.Context, config *GeneralConfig) (backend.Backend, error) {
@@ -0,0 +1,258 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+
+	// ImportFormatArchive is an encrypted archive created by keyring export,
+	// rather than a plaintext file.
+	ImportFormatArchive = "archive"
+
+	ImportFormatDotenv  = "dotenv"
+	ImportFormatJSON    = "json"
+	ImportFormatYAML    = "yaml"
+	ImportFormatVaultKV = "vault-kv"
+)
+
+// ImportFormatExtensions are the file extensions imported for each format
+// when importing a directory tree.
+var ImportFormatExtensions = map[string][]string{
+	ImportFormatDotenv:  {".env"},
+	ImportFormatJSON:    {".json"},
+	ImportFormatYAML:    {".yaml", ".yml"},
+	ImportFormatVaultKV: {".json"},
+}
+
+// ParseImportData parses the contents of a file in the format into the
+// key value pairs of a single path. Nested objects are preserved as the
+// value of their top level key.
+func ParseImportData(format string, data []byte) (map[string]interface{}, error) {
+
+	switch format {
+
+	case ImportFormatDotenv:
+		return ParseDotenv(data)
+
+	case ImportFormatJSON:
+		return parseJSONObject(data)
+
+	case ImportFormatYAML:
+
+		var kvPairs map[string]interface{}
+
+		if err := yaml.Unmarshal(data, &kvPairs); err != nil {
+			return nil, fmt.Errorf("failed to parse yaml: %s", err.Error())
+		}
+
+		if kvPairs == nil {
+			kvPairs = make(map[string]interface{})
+		}
+
+		return kvPairs, nil
+
+	case ImportFormatVaultKV:
+		return parseVaultKV(data)
+
+	default:
+		return nil, fmt.Errorf("unsupported import format '%s'", format)
+	}
+}
+
+// ParseDotenv parses KEY=VALUE lines. Blank lines, comments and a leading
+// export are ignored. Double quoted values support escapes and may span
+// lines, while single quoted values are taken literally.
+func ParseDotenv(data []byte) (map[string]interface{}, error) {
+
+	kvPairs := make(map[string]interface{})
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+
+	lineNumber := 0
+
+	for scanner.Scan() {
+
+		lineNumber++
+
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		line = strings.TrimPrefix(line, "export ")
+
+		parts := strings.SplitN(line, "=", 2)
+
+		key := strings.TrimSpace(parts[0])
+
+		if len(parts) != 2 || key == "" {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", lineNumber)
+		}
+
+		value := strings.TrimSpace(parts[1])
+
+		switch {
+
+		case strings.HasPrefix(value, `"`):
+
+			// Keep reading lines until the closing quote.
+			for !closedDoubleQuote(value) {
+
+				if !scanner.Scan() {
+					return nil, fmt.Errorf("line %d: unterminated quoted value", lineNumber)
+				}
+
+				lineNumber++
+				value += "\n" + scanner.Text()
+			}
+
+			unquoted, err := unquoteDotenv(value)
+
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s", lineNumber, err.Error())
+			}
+
+			value = unquoted
+
+		case strings.HasPrefix(value, "'"):
+
+			end := strings.Index(value[1:], "'")
+
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated quoted value", lineNumber)
+			}
+
+			value = value[1 : end+1]
+
+		default:
+
+			if i := strings.Index(value, " #"); i >= 0 {
+				value = strings.TrimSpace(value[:i])
+			}
+		}
+
+		kvPairs[key] = value
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return kvPairs, nil
+}
+
+// closedDoubleQuote reports if the double quoted value has an unescaped
+// closing quote.
+func closedDoubleQuote(value string) bool {
+
+	escaped := false
+
+	for _, r := range value[1:] {
+
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '"':
+			return true
+		}
+	}
+
+	return false
+}
+
+// unquoteDotenv unquotes a double quoted value, ignoring anything
+// after the closing quote.
+func unquoteDotenv(value string) (string, error) {
+
+	var unquoted strings.Builder
+
+	escaped := false
+
+	for _, r := range value[1:] {
+
+		if escaped {
+
+			switch r {
+			case 'n':
+				unquoted.WriteRune('\n')
+			case 't':
+				unquoted.WriteRune('\t')
+			case 'r':
+				unquoted.WriteRune('\r')
+			default:
+				unquoted.WriteRune(r)
+			}
+
+			escaped = false
+			continue
+		}
+
+		switch r {
+		case '\\':
+			escaped = true
+		case '"':
+			return unquoted.String(), nil
+		default:
+			unquoted.WriteRune(r)
+		}
+	}
+
+	return "", fmt.Errorf("unterminated quoted value")
+}
+
+func parseJSONObject(data []byte) (map[string]interface{}, error) {
+
+	var kvPairs map[string]interface{}
+
+	if err := json.Unmarshal(data, &kvPairs); err != nil {
+		return nil, fmt.Errorf("failed to parse json object: %s", err.Error())
+	}
+
+	if kvPairs == nil {
+		kvPairs = make(map[string]interface{})
+	}
+
+	return kvPairs, nil
+}
+
+// parseVaultKV parses the json output of vault kv get. Version 2 secrets
+// nest the data alongside its metadata, while version 1 secrets do not.
+func parseVaultKV(data []byte) (map[string]interface{}, error) {
+
+	var secret struct {
+		Data map[string]interface{} `json:"data"`
+	}
+
+	if err := json.Unmarshal(data, &secret); err != nil {
+		return nil, fmt.Errorf("failed to parse vault secret: %s", err.Error())
+	}
+
+	if secret.Data == nil {
+		return nil, fmt.Errorf("vault secret has no data")
+	}
+
+	_, hasMetadata := secret.Data["metadata"]
+	rawNested, hasData := secret.Data["data"]
+
+	if !hasMetadata || !hasData {
+		return secret.Data, nil
+	}
+
+	nested, ok := rawNested.(map[string]interface{})
+
+	if !ok {
+		return nil, fmt.Errorf("vault secret has no data. Has the version been deleted?")
+	}
+
+	return nested, nil
+}
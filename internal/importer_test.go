@@ -0,0 +1,99 @@
+package internal
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseDotenv(t *testing.T) {
+
+	data := []byte(`
+# database settings
+export DB_HOST=localhost
+DB_PORT = 5432 # inline comment
+DB_PASSWORD="p@ss \"word\"\nline"
+DB_LITERAL='no \n escapes # here'
+CERT="-----BEGIN-----
+abc
+-----END-----"
+EMPTY=
+`)
+
+	kvPairs, err := ParseDotenv(data)
+
+	if err != nil {
+		t.Fatalf("failed to parse dotenv: %s", err.Error())
+	}
+
+	expected := map[string]interface{}{
+		"DB_HOST":     "localhost",
+		"DB_PORT":     "5432",
+		"DB_PASSWORD": "p@ss \"word\"\nline",
+		"DB_LITERAL":  `no \n escapes # here`,
+		"CERT":        "-----BEGIN-----\nabc\n-----END-----",
+		"EMPTY":       "",
+	}
+
+	if !reflect.DeepEqual(kvPairs, expected) {
+		t.Fatalf("expected %v, but got %v", expected, kvPairs)
+	}
+
+	if _, err := ParseDotenv([]byte("NOT A PAIR")); err == nil {
+		t.Fatalf("expected an error for a line without a value")
+	}
+
+	if _, err := ParseDotenv([]byte(`KEY="unterminated`)); err == nil {
+		t.Fatalf("expected an error for an unterminated quote")
+	}
+
+}
+
+func TestParseImportData(t *testing.T) {
+
+	nested := map[string]interface{}{
+		"user": "admin",
+		"tls": map[string]interface{}{
+			"enabled": true,
+		},
+	}
+
+	cases := map[string]struct {
+		format string
+		data   string
+	}{
+		"json": {
+			format: ImportFormatJSON,
+			data:   `{"user": "admin", "tls": {"enabled": true}}`,
+		},
+		"yaml": {
+			format: ImportFormatYAML,
+			data:   "user: admin\ntls:\n  enabled: true\n",
+		},
+		"vault kv v1": {
+			format: ImportFormatVaultKV,
+			data:   `{"request_id": "1", "data": {"user": "admin", "tls": {"enabled": true}}}`,
+		},
+		"vault kv v2": {
+			format: ImportFormatVaultKV,
+			data:   `{"request_id": "1", "data": {"data": {"user": "admin", "tls": {"enabled": true}}, "metadata": {"version": 3}}}`,
+		},
+	}
+
+	for name, c := range cases {
+
+		kvPairs, err := ParseImportData(c.format, []byte(c.data))
+
+		if err != nil {
+			t.Fatalf("%s: failed to parse: %s", name, err.Error())
+		}
+
+		if !reflect.DeepEqual(kvPairs, nested) {
+			t.Fatalf("%s: expected %v, but got %v", name, nested, kvPairs)
+		}
+	}
+
+	if _, err := ParseImportData(ImportFormatVaultKV, []byte(`{"data": {"data": null, "metadata": {}}}`)); err == nil {
+		t.Fatalf("expected an error for a deleted vault kv version")
+	}
+
+}
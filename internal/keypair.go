@@ -0,0 +1,221 @@
+package internal
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	KeypairTypeEd25519   = "ed25519"
+	KeypairTypeRSA4096   = "rsa-4096"
+	KeypairTypeECDSAP256 = "ecdsa-p256"
+)
+
+const (
+
+	// KeypairFormatOpenSSH encodes the private key in the OpenSSH format,
+	// and the public key as an authorized_keys line.
+	KeypairFormatOpenSSH = "openssh"
+
+	// KeypairFormatPEM encodes the private key as PKCS #8, and the public
+	// key as PKIX, both PEM encoded.
+	KeypairFormatPEM = "pem"
+)
+
+// Keypair is a generated keypair, encoded in a keypair format.
+type Keypair struct {
+	Type       string
+	Format     string
+	PrivateKey string
+	PublicKey  string
+}
+
+// GenerateKeypair generates a keypair of the type, drawn from crypto/rand.
+// The comment is only included in OpenSSH keys.
+func GenerateKeypair(keyType string, format string, comment string) (*Keypair, error) {
+
+	if format != KeypairFormatOpenSSH && format != KeypairFormatPEM {
+		return nil, fmt.Errorf("unsupported keypair format '%s'", format)
+	}
+
+	var private crypto.Signer
+	var err error
+
+	switch keyType {
+
+	case KeypairTypeEd25519:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+
+	case KeypairTypeRSA4096:
+		private, err = rsa.GenerateKey(rand.Reader, 4096)
+
+	case KeypairTypeECDSAP256:
+		private, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	default:
+		return nil, fmt.Errorf("unsupported keypair type '%s'", keyType)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate %s key: %s", keyType, err.Error())
+	}
+
+	keypair := &Keypair{
+		Type:   keyType,
+		Format: format,
+	}
+
+	if format == KeypairFormatPEM {
+
+		privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode private key: %s", err.Error())
+		}
+
+		publicDER, err := x509.MarshalPKIXPublicKey(private.Public())
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode public key: %s", err.Error())
+		}
+
+		keypair.PrivateKey = string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER}))
+		keypair.PublicKey = string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}))
+
+		return keypair, nil
+	}
+
+	public, err := ssh.NewPublicKey(private.Public())
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode public key: %s", err.Error())
+	}
+
+	privateBytes, err := marshalOpenSSHPrivateKey(private, public, comment)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode private key: %s", err.Error())
+	}
+
+	keypair.PrivateKey = string(pem.EncodeToMemory(&pem.Block{Type: "OPENSSH PRIVATE KEY", Bytes: privateBytes}))
+
+	authorizedKey := strings.TrimSuffix(string(ssh.MarshalAuthorizedKey(public)), "\n")
+
+	if comment != "" {
+		authorizedKey += " " + comment
+	}
+
+	keypair.PublicKey = authorizedKey + "\n"
+
+	return keypair, nil
+}
+
+// marshalOpenSSHPrivateKey encodes an unencrypted private key in the
+// openssh-key-v1 format, as described by PROTOCOL.key of OpenSSH.
+func marshalOpenSSHPrivateKey(private crypto.Signer, public ssh.PublicKey, comment string) ([]byte, error) {
+
+	const magic = "openssh-key-v1\x00"
+
+	var keyFields []byte
+
+	switch key := private.(type) {
+
+	case ed25519.PrivateKey:
+
+		keyFields = ssh.Marshal(struct {
+			Pub  []byte
+			Priv []byte
+		}{
+			Pub:  key.Public().(ed25519.PublicKey),
+			Priv: key,
+		})
+
+	case *rsa.PrivateKey:
+
+		keyFields = ssh.Marshal(struct {
+			N    *big.Int
+			E    *big.Int
+			D    *big.Int
+			Iqmp *big.Int
+			P    *big.Int
+			Q    *big.Int
+		}{
+			N:    key.N,
+			E:    big.NewInt(int64(key.E)),
+			D:    key.D,
+			Iqmp: key.Precomputed.Qinv,
+			P:    key.Primes[0],
+			Q:    key.Primes[1],
+		})
+
+	case *ecdsa.PrivateKey:
+
+		keyFields = ssh.Marshal(struct {
+			Curve string
+			Pub   []byte
+			D     *big.Int
+		}{
+			Curve: "nistp256",
+			Pub:   elliptic.Marshal(key.Curve, key.X, key.Y),
+			D:     key.D,
+		})
+
+	default:
+		return nil, fmt.Errorf("unsupported private key %T", private)
+	}
+
+	checkBytes := make([]byte, 4)
+
+	if _, err := rand.Read(checkBytes); err != nil {
+		return nil, err
+	}
+
+	check := binary.BigEndian.Uint32(checkBytes)
+
+	privateBlock := ssh.Marshal(struct {
+		Check1  uint32
+		Check2  uint32
+		Keytype string
+	}{
+		Check1:  check,
+		Check2:  check,
+		Keytype: public.Type(),
+	})
+
+	privateBlock = append(privateBlock, keyFields...)
+	privateBlock = append(privateBlock, ssh.Marshal(struct{ Comment string }{comment})...)
+
+	// Pad to the block size of the "none" cipher with 1, 2, 3...
+	for i := byte(1); len(privateBlock)%8 != 0; i++ {
+		privateBlock = append(privateBlock, i)
+	}
+
+	encoded := ssh.Marshal(struct {
+		CipherName   string
+		KdfName      string
+		KdfOpts      string
+		NumKeys      uint32
+		PubKey       []byte
+		PrivKeyBlock []byte
+	}{
+		CipherName:   "none",
+		KdfName:      "none",
+		NumKeys:      1,
+		PubKey:       public.Marshal(),
+		PrivKeyBlock: privateBlock,
+	})
+
+	return append([]byte(magic), encoded...), nil
+}
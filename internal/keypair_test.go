@@ -0,0 +1,87 @@
+package internal
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestGenerateKeypair(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("to slow for testing.Short. RSA key generation")
+	}
+
+	keyTypes := []string{KeypairTypeEd25519, KeypairTypeRSA4096, KeypairTypeECDSAP256}
+
+	for _, keyType := range keyTypes {
+
+		keypair, err := GenerateKeypair(keyType, KeypairFormatOpenSSH, "deploy@ci")
+
+		if err != nil {
+			t.Fatalf("failed to generate %s openssh keypair: %s", keyType, err.Error())
+		}
+
+		signer, err := ssh.ParsePrivateKey([]byte(keypair.PrivateKey))
+
+		if err != nil {
+			t.Fatalf("failed to parse %s openssh private key: %s", keyType, err.Error())
+		}
+
+		public, comment, _, _, err := ssh.ParseAuthorizedKey([]byte(keypair.PublicKey))
+
+		if err != nil {
+			t.Fatalf("failed to parse %s authorized key: %s", keyType, err.Error())
+		}
+
+		if comment != "deploy@ci" {
+			t.Fatalf("expected the %s authorized key comment to be kept, but got %q", keyType, comment)
+		}
+
+		if string(public.Marshal()) != string(signer.PublicKey().Marshal()) {
+			t.Fatalf("expected the %s public key to match the private key", keyType)
+		}
+
+		keypair, err = GenerateKeypair(keyType, KeypairFormatPEM, "")
+
+		if err != nil {
+			t.Fatalf("failed to generate %s pem keypair: %s", keyType, err.Error())
+		}
+
+		privateBlock, _ := pem.Decode([]byte(keypair.PrivateKey))
+		publicBlock, _ := pem.Decode([]byte(keypair.PublicKey))
+
+		if privateBlock == nil || publicBlock == nil {
+			t.Fatalf("expected the %s keypair to be pem encoded", keyType)
+		}
+
+		private, err := x509.ParsePKCS8PrivateKey(privateBlock.Bytes)
+
+		if err != nil {
+			t.Fatalf("failed to parse %s pem private key: %s", keyType, err.Error())
+		}
+
+		pemPublic, err := x509.ParsePKIXPublicKey(publicBlock.Bytes)
+
+		if err != nil {
+			t.Fatalf("failed to parse %s pem public key: %s", keyType, err.Error())
+		}
+
+		if !reflect.DeepEqual(private.(crypto.Signer).Public(), pemPublic) {
+			t.Fatalf("expected the %s public key to match the private key", keyType)
+		}
+
+		if strings.Contains(keypair.PublicKey, "PRIVATE") {
+			t.Fatalf("expected the %s public key to not contain the private key", keyType)
+		}
+	}
+
+	if _, err := GenerateKeypair("dsa", KeypairFormatPEM, ""); err == nil {
+		t.Fatal("expected an unsupported type to fail")
+	}
+}
@@ -3,7 +3,9 @@ package internal
 import (
 	"bytes"
 	"crypto/aes"
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"time"
@@ -150,6 +152,16 @@ func (k *Keyring) RootKey() []byte {
 	return k.rootKey
 }
 
+// DeriveKey derives a purpose specific key from the root key.
+//
+// The derived key is stable for the lifetime of the root key, which makes it
+// suitable for keyed hashes that must be reproducible across key rotations.
+func (k *Keyring) DeriveKey(purpose string) []byte {
+	mac := hmac.New(sha256.New, k.rootKey)
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
+
 // AddKey adds a key to the key ring and makes the newly add key
 // the active key
 func (k *Keyring) AddKey(key *Key) error {
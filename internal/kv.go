@@ -0,0 +1,1305 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/woodrufj4/keyring-practice/backend"
+)
+
+const (
+
+	// DefaultMaxVersions is the number of versions retained for a path
+	// when the path metadata does not configure a limit.
+	DefaultMaxVersions = 10
+
+	kvMetadataKey = "_metadata"
+	kvDataPrefix  = "_data/"
+)
+
+var (
+	ErrKVNotFound         = errors.New("no value found at path")
+	ErrKVVersionNotFound  = errors.New("version not found")
+	ErrKVVersionDeleted   = errors.New("version has been deleted")
+	ErrKVVersionDestroyed = errors.New("version has been destroyed")
+	ErrKVVersionsRequired = errors.New("at least one version is required")
+	ErrKVExpired          = errors.New("secret has expired")
+
+	// ErrKVDestinationExists is returned when copying or moving onto
+	// a path that already exists.
+	ErrKVDestinationExists = errors.New("destination already exists")
+
+	// ErrKVCASMismatch is returned when a check-and-set write does not match
+	// the current version at the path.
+	ErrKVCASMismatch = errors.New("check-and-set conflict")
+
+	// ErrKVCASRequired is returned when a write without a check-and-set
+	// version targets a path that requires one.
+	ErrKVCASRequired = errors.New("check-and-set version is required for this path")
+)
+
+// KVMetadata tracks the versions stored at a path.
+type KVMetadata struct {
+	CurrentVersion uint64                `json:"current_version"`
+	OldestVersion  uint64                `json:"oldest_version"`
+	MaxVersions    int                   `json:"max_versions"`
+	CASRequired    bool                  `json:"cas_required"`
+	CreatedTime    time.Time             `json:"created_time"`
+	UpdatedTime    time.Time             `json:"updated_time"`
+	UpdatedBy      string                `json:"updated_by"`
+	LastReadTime   time.Time             `json:"last_read_time"`
+	ExpireTime     time.Time             `json:"expire_time"`
+	CustomMetadata map[string]string     `json:"custom_metadata"`
+	Labels         map[string]string     `json:"labels,omitempty"`
+	Versions       map[uint64]*KVVersion `json:"versions"`
+}
+
+// maxVersions reports the effective number of versions to retain.
+func (m *KVMetadata) maxVersions() int {
+
+	if m.MaxVersions <= 0 {
+		return DefaultMaxVersions
+	}
+
+	return m.MaxVersions
+}
+
+// Expired reports if the path has expired at the provided time.
+// Paths without an expiration never expire.
+func (m *KVMetadata) Expired(now time.Time) bool {
+	return !m.ExpireTime.IsZero() && !now.Before(m.ExpireTime)
+}
+
+// SortedVersions reports the retained versions in ascending order.
+func (m *KVMetadata) SortedVersions() []uint64 {
+
+	versions := make([]uint64, 0, len(m.Versions))
+
+	for version := range m.Versions {
+		versions = append(versions, version)
+	}
+
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i] < versions[j]
+	})
+
+	return versions
+}
+
+// KVVersion describes a single version of the data at a path.
+type KVVersion struct {
+	CreatedTime  time.Time `json:"created_time"`
+	CreatedBy    string    `json:"created_by"`
+	DeletionTime time.Time `json:"deletion_time"`
+	Destroyed    bool      `json:"destroyed"`
+}
+
+// Deleted reports if the version has been soft deleted.
+func (v *KVVersion) Deleted() bool {
+	return !v.DeletionTime.IsZero()
+}
+
+// KVSecret is a single version of the data at a path.
+type KVSecret struct {
+	Version  uint64
+	Metadata *KVVersion
+
+	// PathMetadata is the metadata of the path the version belongs to.
+	PathMetadata *KVMetadata
+
+	// Entries are the key value pairs of the version. This is nil if the
+	// version has been deleted or destroyed.
+	Entries []*backend.BackendEntry
+}
+
+// KVMetadataInput holds the configurable fields of the path metadata.
+// Nil fields are left unchanged.
+type KVMetadataInput struct {
+	MaxVersions *int
+	CASRequired *bool
+
+	// TTL sets the expiration of the path relative to now.
+	// A TTL of 0 removes the expiration.
+	TTL *time.Duration
+
+	// CustomMetadata is merged into the existing custom metadata.
+	// Keys with an empty value are removed.
+	CustomMetadata map[string]string
+
+	// Labels are merged into the existing labels. Unlike custom metadata,
+	// labels can be selected with a LabelSelector. Keys with an empty value
+	// are removed.
+	Labels map[string]string
+}
+
+// KVPutOptions controls how a new version is written.
+type KVPutOptions struct {
+
+	// CAS is the version the caller expects to be current at the path.
+	// The write fails if the path has changed since. A CAS of 0 only
+	// allows the write if nothing has been written to the path.
+	CAS *uint64
+
+	// TTL sets the expiration of the path relative to the write. Writes
+	// without a TTL keep the existing expiration of the path, unless it has
+	// already passed, in which case the expiration is removed.
+	TTL time.Duration
+}
+
+// KVExpiration reports when a path expires.
+type KVExpiration struct {
+	Path       string
+	ExpireTime time.Time
+}
+
+// KVRelocation is a path to copy or move, along with its destination.
+type KVRelocation struct {
+	Source      string
+	Destination string
+}
+
+// kvState is the decrypted state of a path.
+type kvState struct {
+	metadata *KVMetadata
+	data     map[uint64][]*backend.BackendEntry
+
+	// legacyKeys are the entry keys written before paths were versioned.
+	// These are migrated to version 1 on the next write.
+	legacyKeys []string
+}
+
+// KV provides versioned key value storage on top of the barrier.
+//
+// Every write creates a new version of the data at the path. Older versions
+// can be read, rolled back to, soft deleted, undeleted or destroyed.
+//
+// New versions are validated against the schema bound to the path, and
+// refused with a *SchemaValidationError when they do not match.
+type KV struct {
+	barrier  *Barrier
+	schemas  *Schemas
+	now      func() time.Time
+	identity string
+	lock     sync.Mutex
+}
+
+// NewKV instantiates a versioned key value store backed by an
+// initialized barrier.
+func NewKV(barrier *Barrier) (*KV, error) {
+
+	if barrier == nil || !barrier.Initialized() {
+		return nil, ErrKeyringNotSet
+	}
+
+	return &KV{
+		barrier:  barrier,
+		schemas:  &Schemas{barrier: barrier},
+		now:      time.Now,
+		identity: currentIdentity(),
+	}, nil
+}
+
+// Put writes a new version at the path, replacing all the key value pairs
+// of the current version with the provided entries.
+func (kv *KV) Put(ctx context.Context, path string, entries []*backend.BackendEntry, opts *KVPutOptions) (uint64, error) {
+
+	return kv.update(ctx, path, opts, false, func(data map[string][]byte) {
+
+		for key := range data {
+			delete(data, key)
+		}
+
+		for _, entry := range entries {
+			data[entry.Key] = entry.Value
+		}
+	})
+}
+
+// Patch writes a new version at the path, merging the provided entries into
+// the key value pairs of the current version.
+func (kv *KV) Patch(ctx context.Context, path string, entries []*backend.BackendEntry, opts *KVPutOptions) (uint64, error) {
+
+	return kv.update(ctx, path, opts, true, func(data map[string][]byte) {
+		for _, entry := range entries {
+			data[entry.Key] = entry.Value
+		}
+	})
+}
+
+// Unset writes a new version at the path without the provided keys.
+func (kv *KV) Unset(ctx context.Context, path string, keys []string, opts *KVPutOptions) (uint64, error) {
+
+	return kv.update(ctx, path, opts, true, func(data map[string][]byte) {
+		for _, key := range keys {
+			delete(data, key)
+		}
+	})
+}
+
+// update writes a new version at the path from the key value pairs of the
+// current version, as modified by the provided func.
+//
+// If existing is set, the update fails when there is no current version
+// to modify.
+func (kv *KV) update(ctx context.Context, path string, opts *KVPutOptions, existing bool, modify func(map[string][]byte)) (uint64, error) {
+
+	if opts != nil && opts.TTL < 0 {
+		return 0, fmt.Errorf("ttl must not be negative")
+	}
+
+	kv.lock.Lock()
+	defer kv.lock.Unlock()
+
+	state, err := kv.read(ctx, path)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if err := checkAndSet(state, opts); err != nil {
+		return 0, err
+	}
+
+	data := make(map[string][]byte)
+
+	found := false
+
+	if state != nil {
+
+		var current []*backend.BackendEntry
+
+		current, found = state.current()
+
+		for _, entry := range current {
+			data[entry.Key] = entry.Value
+		}
+	}
+
+	if existing && !found {
+		return 0, ErrKVNotFound
+	}
+
+	if existing && state.metadata.Expired(kv.now()) {
+		return 0, ErrKVExpired
+	}
+
+	modify(data)
+
+	switch {
+
+	case opts != nil && opts.TTL > 0:
+
+		if state == nil {
+			state = kv.newState()
+		}
+
+		state.metadata.ExpireTime = kv.now().Add(opts.TTL).UTC()
+
+	case state != nil && state.metadata.Expired(kv.now()):
+
+		// Keeping an expiration which has passed would write a version
+		// that can not be read.
+		state.metadata.ExpireTime = time.Time{}
+	}
+
+	return kv.putVersion(ctx, path, state, data)
+}
+
+// Get retrieves the current version at the path.
+//
+// If nothing has been written to the path, this returns nil.
+func (kv *KV) Get(ctx context.Context, path string) (*KVSecret, error) {
+	return kv.GetVersion(ctx, path, 0)
+}
+
+// GetVersion retrieves a specific version at the path. Version 0 retrieves
+// the current version.
+//
+// If nothing has been written to the path, this returns nil. Expired paths
+// are reported as ErrKVExpired. The read is not recorded, see RecordRead.
+func (kv *KV) GetVersion(ctx context.Context, path string, version uint64) (*KVSecret, error) {
+
+	kv.lock.Lock()
+	defer kv.lock.Unlock()
+
+	state, err := kv.read(ctx, path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if state == nil || state.metadata.CurrentVersion == 0 {
+		return nil, nil
+	}
+
+	if state.metadata.Expired(kv.now()) {
+		return nil, fmt.Errorf("%w at %s", ErrKVExpired, state.metadata.ExpireTime.Format(time.RFC3339))
+	}
+
+	if version == 0 {
+		version = state.metadata.CurrentVersion
+	}
+
+	versionMetadata, ok := state.metadata.Versions[version]
+
+	if !ok {
+		return nil, ErrKVVersionNotFound
+	}
+
+	secret := &KVSecret{
+		Version:      version,
+		Metadata:     versionMetadata,
+		PathMetadata: state.metadata,
+	}
+
+	if versionMetadata.Deleted() || versionMetadata.Destroyed {
+		return secret, nil
+	}
+
+	secret.Entries = state.data[version]
+
+	if secret.Entries == nil {
+		secret.Entries = []*backend.BackendEntry{}
+	}
+
+	return secret, nil
+}
+
+// RecordRead records the path as read now in its metadata. This is a write,
+// so only explicit reads are recorded, rather than every tool reading the
+// path.
+func (kv *KV) RecordRead(ctx context.Context, path string) error {
+
+	kv.lock.Lock()
+	defer kv.lock.Unlock()
+
+	state, err := kv.read(ctx, path)
+
+	if err != nil || state == nil {
+		return err
+	}
+
+	state.metadata.LastReadTime = kv.now().UTC()
+
+	return kv.write(ctx, path, state, nil, nil)
+}
+
+// Rollback writes the data of a previous version as a new version. Like
+// Put, the write is checked against the check-and-set version of the
+// options. The TTL of the options is not used.
+//
+// Like Patch, expired paths are refused with ErrKVExpired, as their
+// versions can no longer be read.
+func (kv *KV) Rollback(ctx context.Context, path string, version uint64, opts *KVPutOptions) (uint64, error) {
+
+	kv.lock.Lock()
+	defer kv.lock.Unlock()
+
+	state, err := kv.read(ctx, path)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if state == nil {
+		return 0, ErrKVVersionNotFound
+	}
+
+	if err := checkAndSet(state, opts); err != nil {
+		return 0, err
+	}
+
+	if state.metadata.Expired(kv.now()) {
+		return 0, ErrKVExpired
+	}
+
+	versionMetadata, ok := state.metadata.Versions[version]
+
+	if !ok {
+		return 0, ErrKVVersionNotFound
+	}
+
+	if versionMetadata.Destroyed {
+		return 0, ErrKVVersionDestroyed
+	}
+
+	if versionMetadata.Deleted() {
+		return 0, ErrKVVersionDeleted
+	}
+
+	data := make(map[string][]byte)
+
+	for _, entry := range state.data[version] {
+		data[entry.Key] = entry.Value
+	}
+
+	return kv.putVersion(ctx, path, state, data)
+}
+
+// Delete soft deletes the versions at the path. The data is retained and
+// can be restored with Undelete.
+//
+// If no versions are provided, the current version is deleted.
+func (kv *KV) Delete(ctx context.Context, path string, versions []uint64) error {
+
+	kv.lock.Lock()
+	defer kv.lock.Unlock()
+
+	state, err := kv.read(ctx, path)
+
+	if err != nil {
+		return err
+	}
+
+	if state == nil || state.metadata.CurrentVersion == 0 {
+		return nil
+	}
+
+	if len(versions) == 0 {
+		versions = []uint64{state.metadata.CurrentVersion}
+	}
+
+	now := kv.touch(state.metadata)
+
+	for _, version := range versions {
+
+		versionMetadata, ok := state.metadata.Versions[version]
+
+		if !ok || versionMetadata.Destroyed || versionMetadata.Deleted() {
+			continue
+		}
+
+		versionMetadata.DeletionTime = now
+	}
+
+	return kv.write(ctx, path, state, nil, nil)
+}
+
+// Undelete restores soft deleted versions at the path.
+func (kv *KV) Undelete(ctx context.Context, path string, versions []uint64) error {
+
+	if len(versions) == 0 {
+		return ErrKVVersionsRequired
+	}
+
+	kv.lock.Lock()
+	defer kv.lock.Unlock()
+
+	state, err := kv.read(ctx, path)
+
+	if err != nil {
+		return err
+	}
+
+	if state == nil {
+		return nil
+	}
+
+	for _, version := range versions {
+
+		versionMetadata, ok := state.metadata.Versions[version]
+
+		if !ok || versionMetadata.Destroyed {
+			continue
+		}
+
+		versionMetadata.DeletionTime = time.Time{}
+	}
+
+	kv.touch(state.metadata)
+
+	return kv.write(ctx, path, state, nil, nil)
+}
+
+// Destroy permanently removes the data of the versions at the path.
+// The version metadata is retained.
+func (kv *KV) Destroy(ctx context.Context, path string, versions []uint64) error {
+
+	if len(versions) == 0 {
+		return ErrKVVersionsRequired
+	}
+
+	kv.lock.Lock()
+	defer kv.lock.Unlock()
+
+	state, err := kv.read(ctx, path)
+
+	if err != nil {
+		return err
+	}
+
+	if state == nil {
+		return nil
+	}
+
+	deleteKeys := make([]string, 0)
+
+	for _, version := range versions {
+
+		versionMetadata, ok := state.metadata.Versions[version]
+
+		if !ok || versionMetadata.Destroyed {
+			continue
+		}
+
+		versionMetadata.Destroyed = true
+		deleteKeys = append(deleteKeys, kvDataKeys(version, state.data[version])...)
+	}
+
+	kv.touch(state.metadata)
+
+	return kv.write(ctx, path, state, nil, deleteKeys)
+}
+
+// DestroyAll permanently removes every version and the metadata
+// at the path.
+func (kv *KV) DestroyAll(ctx context.Context, path string) error {
+
+	kv.lock.Lock()
+	defer kv.lock.Unlock()
+
+	return kv.barrier.Delete(ctx, path)
+}
+
+// List reports the contents of the folder. The folder is treated as a
+// directory, so listing "secret/ap" does not include "secret/app".
+//
+// By default only the immediate children are reported, with sub-folders
+// suffixed by "/". When recursive, every path within the folder is
+// reported. Reported names are relative to the folder.
+func (kv *KV) List(ctx context.Context, folder string, recursive bool) ([]string, error) {
+
+	if folder != "" && !strings.HasSuffix(folder, "/") {
+		folder += "/"
+	}
+
+	paths, err := kv.barrier.List(ctx, folder)
+
+	if err != nil {
+		return nil, err
+	}
+
+	children := make([]string, 0)
+	seen := make(map[string]bool)
+
+	for _, path := range paths {
+
+		if strings.HasPrefix(path, KeyringPrefix) {
+			continue
+		}
+
+		child := strings.TrimPrefix(path, folder)
+
+		if !recursive {
+			if i := strings.Index(child, "/"); i >= 0 {
+				child = child[:i+1]
+			}
+		}
+
+		if child == "" || seen[child] {
+			continue
+		}
+
+		seen[child] = true
+		children = append(children, child)
+	}
+
+	sort.Strings(children)
+
+	return children, nil
+}
+
+// DestroyPaths permanently removes every version and the metadata of the
+// paths within a single transaction. Either all the paths are removed, or
+// none of them are.
+func (kv *KV) DestroyPaths(ctx context.Context, paths []string) error {
+
+	kv.lock.Lock()
+	defer kv.lock.Unlock()
+
+	txns := make([]*backend.TxnEntry, 0, len(paths))
+
+	for _, path := range paths {
+		txns = append(txns, &backend.TxnEntry{
+			Operation: backend.DeleteOperation,
+			Path:      path,
+		})
+	}
+
+	return kv.barrier.Transaction(ctx, txns)
+}
+
+// Copy copies every version and the metadata of the sources onto their
+// destinations within a single transaction. The entries are re-encrypted
+// under the active term.
+//
+// Destinations that already exist are refused, unless force is set, in
+// which case they are replaced along with their version history.
+// Destinations which require check-and-set are always refused, as there
+// is no version to check the replacement against.
+func (kv *KV) Copy(ctx context.Context, relocations []*KVRelocation, force bool) error {
+	return kv.relocate(ctx, relocations, force, false)
+}
+
+// Move copies the sources onto their destinations like Copy, removing the
+// sources within the same transaction.
+func (kv *KV) Move(ctx context.Context, relocations []*KVRelocation, force bool) error {
+	return kv.relocate(ctx, relocations, force, true)
+}
+
+func (kv *KV) relocate(ctx context.Context, relocations []*KVRelocation, force bool, move bool) error {
+
+	sources := make(map[string]bool, len(relocations))
+	destinations := make(map[string]bool, len(relocations))
+
+	for _, relocation := range relocations {
+		sources[relocation.Source] = true
+
+		if destinations[relocation.Destination] {
+			return fmt.Errorf("multiple paths map onto the destination '%s'", relocation.Destination)
+		}
+
+		destinations[relocation.Destination] = true
+	}
+
+	for destination := range destinations {
+		if sources[destination] {
+			return fmt.Errorf("destination '%s' is also a source", destination)
+		}
+	}
+
+	kv.lock.Lock()
+	defer kv.lock.Unlock()
+
+	txns := make([]*backend.TxnEntry, 0, len(relocations)*3)
+
+	for _, relocation := range relocations {
+
+		entries, err := kv.barrier.Get(ctx, relocation.Source)
+
+		if err != nil {
+			return err
+		}
+
+		if len(entries) == 0 {
+			return fmt.Errorf("%w: %s", ErrKVNotFound, relocation.Source)
+		}
+
+		state, err := kv.read(ctx, relocation.Source)
+
+		if err != nil {
+			return err
+		}
+
+		// The current version of the source becomes the current version
+		// of the destination, so it must match the destination schema.
+		if current, ok := state.current(); ok {
+			if err := kv.schemas.Validate(ctx, relocation.Destination, current); err != nil {
+				return err
+			}
+		}
+
+		existing, err := kv.barrier.Get(ctx, relocation.Destination)
+
+		if err != nil {
+			return err
+		}
+
+		if len(existing) > 0 {
+
+			if !force {
+				return fmt.Errorf("%w: %s", ErrKVDestinationExists, relocation.Destination)
+			}
+
+			destination, err := kv.read(ctx, relocation.Destination)
+
+			if err != nil {
+				return err
+			}
+
+			if destination.metadata.CASRequired {
+				return fmt.Errorf("%w: %s", ErrKVCASRequired, relocation.Destination)
+			}
+
+			txns = append(txns, &backend.TxnEntry{
+				Operation: backend.DeleteOperation,
+				Path:      relocation.Destination,
+			})
+		}
+
+		txns = append(txns, &backend.TxnEntry{
+			Operation: backend.PutOperation,
+			Path:      relocation.Destination,
+			Entries:   entries,
+		})
+
+		if move {
+			txns = append(txns, &backend.TxnEntry{
+				Operation: backend.DeleteOperation,
+				Path:      relocation.Source,
+			})
+		}
+	}
+
+	return kv.barrier.Transaction(ctx, txns)
+}
+
+// Expiring reports the paths within the folder that expire within the
+// provided duration, including paths that have already expired. Reported
+// paths are relative to the folder, ordered by expiration.
+func (kv *KV) Expiring(ctx context.Context, folder string, within time.Duration) ([]*KVExpiration, error) {
+
+	if folder != "" && !strings.HasSuffix(folder, "/") {
+		folder += "/"
+	}
+
+	children, err := kv.List(ctx, folder, true)
+
+	if err != nil {
+		return nil, err
+	}
+
+	kv.lock.Lock()
+	defer kv.lock.Unlock()
+
+	deadline := kv.now().Add(within)
+
+	expiring := make([]*KVExpiration, 0)
+
+	for _, child := range children {
+
+		state, err := kv.read(ctx, folder+child)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if state == nil || !state.metadata.Expired(deadline) {
+			continue
+		}
+
+		expiring = append(expiring, &KVExpiration{
+			Path:       child,
+			ExpireTime: state.metadata.ExpireTime,
+		})
+	}
+
+	sort.SliceStable(expiring, func(i, j int) bool {
+		return expiring[i].ExpireTime.Before(expiring[j].ExpireTime)
+	})
+
+	return expiring, nil
+}
+
+// Select reports the paths within the folder whose labels match the
+// selector. An empty selector selects every path. Reported paths are
+// relative to the folder.
+func (kv *KV) Select(ctx context.Context, folder string, selector *LabelSelector) ([]string, error) {
+
+	if folder != "" && !strings.HasSuffix(folder, "/") {
+		folder += "/"
+	}
+
+	children, err := kv.List(ctx, folder, true)
+
+	if err != nil || selector.Empty() {
+		return children, err
+	}
+
+	kv.lock.Lock()
+	defer kv.lock.Unlock()
+
+	selected := make([]string, 0)
+
+	for _, child := range children {
+
+		state, err := kv.read(ctx, folder+child)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if state != nil && selector.Matches(state.metadata.Labels) {
+			selected = append(selected, child)
+		}
+	}
+
+	return selected, nil
+}
+
+// Reap permanently removes every expired path, reporting the
+// removed paths.
+func (kv *KV) Reap(ctx context.Context) ([]string, error) {
+
+	paths, err := kv.barrier.List(ctx, "")
+
+	if err != nil {
+		return nil, err
+	}
+
+	kv.lock.Lock()
+	defer kv.lock.Unlock()
+
+	now := kv.now()
+
+	reaped := make([]string, 0)
+
+	for _, path := range paths {
+
+		if strings.HasPrefix(path, KeyringPrefix) {
+			continue
+		}
+
+		state, err := kv.read(ctx, path)
+
+		if err != nil {
+			return reaped, err
+		}
+
+		if state == nil || !state.metadata.Expired(now) {
+			continue
+		}
+
+		if err := kv.barrier.Delete(ctx, path); err != nil {
+			return reaped, fmt.Errorf("failed to remove expired path '%s': %s", path, err.Error())
+		}
+
+		reaped = append(reaped, path)
+	}
+
+	return reaped, nil
+}
+
+// Metadata retrieves the metadata at the path.
+//
+// If nothing has been written to the path, this returns nil.
+func (kv *KV) Metadata(ctx context.Context, path string) (*KVMetadata, error) {
+
+	kv.lock.Lock()
+	defer kv.lock.Unlock()
+
+	state, err := kv.read(ctx, path)
+
+	if err != nil || state == nil {
+		return nil, err
+	}
+
+	return state.metadata, nil
+}
+
+// PutMetadata updates the configurable metadata at the path. The metadata is
+// created if nothing has been written to the path yet.
+func (kv *KV) PutMetadata(ctx context.Context, path string, input *KVMetadataInput) error {
+
+	kv.lock.Lock()
+	defer kv.lock.Unlock()
+
+	state, err := kv.read(ctx, path)
+
+	if err != nil {
+		return err
+	}
+
+	if state == nil {
+		state = kv.newState()
+	}
+
+	if input.MaxVersions != nil {
+
+		if *input.MaxVersions < 0 {
+			return fmt.Errorf("max versions must not be negative")
+		}
+
+		state.metadata.MaxVersions = *input.MaxVersions
+	}
+
+	if input.CASRequired != nil {
+		state.metadata.CASRequired = *input.CASRequired
+	}
+
+	if input.TTL != nil {
+
+		switch {
+
+		case *input.TTL < 0:
+			return fmt.Errorf("ttl must not be negative")
+
+		case *input.TTL == 0:
+			state.metadata.ExpireTime = time.Time{}
+
+		default:
+			state.metadata.ExpireTime = kv.now().Add(*input.TTL).UTC()
+		}
+	}
+
+	if input.CustomMetadata != nil {
+
+		if state.metadata.CustomMetadata == nil {
+			state.metadata.CustomMetadata = make(map[string]string)
+		}
+
+		for key, value := range input.CustomMetadata {
+
+			if value == "" {
+				delete(state.metadata.CustomMetadata, key)
+				continue
+			}
+
+			state.metadata.CustomMetadata[key] = value
+		}
+	}
+
+	if input.Labels != nil {
+
+		if state.metadata.Labels == nil {
+			state.metadata.Labels = make(map[string]string)
+		}
+
+		for key, value := range input.Labels {
+
+			if value == "" {
+				delete(state.metadata.Labels, key)
+				continue
+			}
+
+			if err := ValidateLabel(key, value); err != nil {
+				return err
+			}
+
+			state.metadata.Labels[key] = value
+		}
+	}
+
+	kv.touch(state.metadata)
+
+	return kv.write(ctx, path, state, nil, nil)
+}
+
+// checkAndSet validates the check-and-set version against the current
+// version at the path.
+func checkAndSet(state *kvState, opts *KVPutOptions) error {
+
+	var current uint64
+	var required bool
+
+	if state != nil {
+		current = state.metadata.CurrentVersion
+		required = state.metadata.CASRequired
+	}
+
+	if opts == nil || opts.CAS == nil {
+
+		if required {
+			return ErrKVCASRequired
+		}
+
+		return nil
+	}
+
+	if *opts.CAS != current {
+		return fmt.Errorf("%w: expected version %d, but the current version is %d", ErrKVCASMismatch, *opts.CAS, current)
+	}
+
+	return nil
+}
+
+// putVersion writes the data as a new version at the path.
+func (kv *KV) putVersion(ctx context.Context, path string, state *kvState, data map[string][]byte) (uint64, error) {
+
+	if state == nil {
+		state = kv.newState()
+	}
+
+	entries := make([]*backend.BackendEntry, 0, len(data))
+
+	for key, value := range data {
+		entries = append(entries, &backend.BackendEntry{
+			Key:   key,
+			Value: value,
+		})
+	}
+
+	if err := kv.schemas.Validate(ctx, path, entries); err != nil {
+		return 0, err
+	}
+
+	now := kv.touch(state.metadata)
+
+	version := state.metadata.CurrentVersion + 1
+
+	state.metadata.CurrentVersion = version
+	state.metadata.Versions[version] = &KVVersion{
+		CreatedTime: now,
+		CreatedBy:   kv.identity,
+	}
+
+	if state.metadata.OldestVersion == 0 {
+		state.metadata.OldestVersion = version
+	}
+
+	writes := map[uint64][]*backend.BackendEntry{
+		version: entries,
+	}
+
+	if err := kv.write(ctx, path, state, writes, nil); err != nil {
+		return 0, err
+	}
+
+	return version, nil
+}
+
+// write atomically persists the metadata along with the version data.
+//
+// Versions beyond the retention limit are pruned, and legacy entries are
+// migrated to version 1 as part of the same transaction.
+func (kv *KV) write(ctx context.Context, path string, state *kvState, writes map[uint64][]*backend.BackendEntry, deleteKeys []string) error {
+
+	if writes == nil {
+		writes = make(map[uint64][]*backend.BackendEntry)
+	}
+
+	metadata := state.metadata
+
+	if len(state.legacyKeys) > 0 {
+
+		deleteKeys = append(deleteKeys, state.legacyKeys...)
+
+		if versionMetadata, ok := metadata.Versions[1]; ok && !versionMetadata.Destroyed {
+			if _, ok := writes[1]; !ok {
+				writes[1] = state.data[1]
+			}
+		}
+	}
+
+	for metadata.OldestVersion > 0 && metadata.CurrentVersion-metadata.OldestVersion+1 > uint64(metadata.maxVersions()) {
+
+		oldest := metadata.OldestVersion
+
+		deleteKeys = append(deleteKeys, kvDataKeys(oldest, state.data[oldest])...)
+
+		delete(writes, oldest)
+		delete(metadata.Versions, oldest)
+
+		metadata.OldestVersion++
+	}
+
+	metadataBytes, err := json.Marshal(metadata)
+
+	if err != nil {
+		return fmt.Errorf("failed to encode metadata: %s", err.Error())
+	}
+
+	putEntries := []*backend.BackendEntry{
+		{
+			Key:   kvMetadataKey,
+			Value: metadataBytes,
+		},
+	}
+
+	for version, entries := range writes {
+		for _, entry := range entries {
+			putEntries = append(putEntries, &backend.BackendEntry{
+				Key:   kvDataKey(version, entry.Key),
+				Value: entry.Value,
+			})
+		}
+	}
+
+	txns := make([]*backend.TxnEntry, 0, 2)
+
+	if len(deleteKeys) > 0 {
+
+		deleteEntries := make([]*backend.BackendEntry, 0, len(deleteKeys))
+
+		for _, key := range deleteKeys {
+			deleteEntries = append(deleteEntries, &backend.BackendEntry{Key: key})
+		}
+
+		txns = append(txns, &backend.TxnEntry{
+			Operation: backend.DeleteOperation,
+			Path:      path,
+			Entries:   deleteEntries,
+		})
+	}
+
+	txns = append(txns, &backend.TxnEntry{
+		Operation: backend.PutOperation,
+		Path:      path,
+		Entries:   putEntries,
+	})
+
+	return kv.barrier.Transaction(ctx, txns)
+}
+
+// read retrieves and decodes the state at the path.
+//
+// If nothing has been written to the path, this returns nil.
+func (kv *KV) read(ctx context.Context, path string) (*kvState, error) {
+
+	entries, err := kv.barrier.Get(ctx, path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	state := &kvState{
+		data: make(map[uint64][]*backend.BackendEntry),
+	}
+
+	legacyEntries := make([]*backend.BackendEntry, 0)
+
+	for _, entry := range entries {
+
+		if entry.Key == kvMetadataKey {
+
+			var metadata KVMetadata
+
+			if err := json.Unmarshal(entry.Value, &metadata); err != nil {
+				return nil, fmt.Errorf("failed to decode metadata at path '%s': %s", path, err.Error())
+			}
+
+			state.metadata = &metadata
+			continue
+		}
+
+		if strings.HasPrefix(entry.Key, kvDataPrefix) {
+
+			version, key, err := parseKVDataKey(entry.Key)
+
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse entry at path '%s': %s", path, err.Error())
+			}
+
+			state.data[version] = append(state.data[version], &backend.BackendEntry{
+				Key:   key,
+				Value: entry.Value,
+			})
+			continue
+		}
+
+		legacyEntries = append(legacyEntries, entry)
+	}
+
+	if state.metadata != nil {
+
+		if state.metadata.Versions == nil {
+			state.metadata.Versions = make(map[uint64]*KVVersion)
+		}
+
+		return state, nil
+	}
+
+	// Paths written before versioning hold the key value pairs directly.
+	// These are presented as the first version.
+	state.metadata = &KVMetadata{
+		CurrentVersion: 1,
+		OldestVersion:  1,
+		Versions: map[uint64]*KVVersion{
+			1: {},
+		},
+	}
+
+	state.data[1] = legacyEntries
+
+	for _, entry := range legacyEntries {
+		state.legacyKeys = append(state.legacyKeys, entry.Key)
+	}
+
+	return state, nil
+}
+
+// current reports the entries of the current version, and if the current
+// version exists and has not been deleted or destroyed.
+func (s *kvState) current() ([]*backend.BackendEntry, bool) {
+
+	version := s.metadata.Versions[s.metadata.CurrentVersion]
+
+	if version == nil || version.Deleted() || version.Destroyed {
+		return nil, false
+	}
+
+	return s.data[s.metadata.CurrentVersion], true
+}
+
+// touch records a change to the path metadata, reporting the time
+// of the change.
+func (kv *KV) touch(metadata *KVMetadata) time.Time {
+
+	now := kv.now().UTC()
+
+	metadata.UpdatedTime = now
+	metadata.UpdatedBy = kv.identity
+
+	return now
+}
+
+func (kv *KV) newState() *kvState {
+
+	now := kv.now().UTC()
+
+	return &kvState{
+		metadata: &KVMetadata{
+			CreatedTime: now,
+			UpdatedTime: now,
+			Versions:    make(map[uint64]*KVVersion),
+		},
+		data: make(map[uint64][]*backend.BackendEntry),
+	}
+}
+
+// currentIdentity reports the operating system user making changes.
+func currentIdentity() string {
+
+	if current, err := user.Current(); err == nil {
+		return current.Username
+	}
+
+	return os.Getenv("USER")
+}
+
+func kvDataKey(version uint64, key string) string {
+	return kvDataPrefix + strconv.FormatUint(version, 10) + "/" + key
+}
+
+func kvDataKeys(version uint64, entries []*backend.BackendEntry) []string {
+
+	keys := make([]string, 0, len(entries))
+
+	for _, entry := range entries {
+		keys = append(keys, kvDataKey(version, entry.Key))
+	}
+
+	return keys
+}
+
+func parseKVDataKey(dataKey string) (uint64, string, error) {
+
+	versionKey := strings.TrimPrefix(dataKey, kvDataPrefix)
+
+	parts := strings.SplitN(versionKey, "/", 2)
+
+	if len(parts) != 2 {
+		return 0, "", fmt.Errorf("invalid data key '%s'", dataKey)
+	}
+
+	version, err := strconv.ParseUint(parts[0], 10, 64)
+
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid data key version '%s'", dataKey)
+	}
+
+	return version, parts[1], nil
+}
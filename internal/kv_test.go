@@ -0,0 +1,867 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/woodrufj4/keyring-practice/backend"
+)
+
+func TestKVVersions(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("to slow for testing.Short. IO operations")
+	}
+
+	kv, err := NewKV(setupBarrier(t))
+
+	if err != nil {
+		t.Fatalf("failed to instantiate kv store: %s", err.Error())
+	}
+
+	ctx := context.Background()
+
+	for i, value := range []string{"one", "two", "three"} {
+
+		version, err := kv.Put(ctx, "secret/foo", testEntries("password", value), nil)
+
+		if err != nil {
+			t.Fatalf("failed to put secret: %s", err.Error())
+		}
+
+		if version != uint64(i+1) {
+			t.Fatalf("expected version %d, but got %d", i+1, version)
+		}
+	}
+
+	secret, err := kv.Get(ctx, "secret/foo")
+
+	if err != nil {
+		t.Fatalf("failed to get secret: %s", err.Error())
+	}
+
+	assertKVValue(t, secret, "password", "three")
+
+	secret, err = kv.GetVersion(ctx, "secret/foo", 1)
+
+	if err != nil {
+		t.Fatalf("failed to get secret version: %s", err.Error())
+	}
+
+	assertKVValue(t, secret, "password", "one")
+
+	version, err := kv.Rollback(ctx, "secret/foo", 1, nil)
+
+	if err != nil {
+		t.Fatalf("failed to rollback secret: %s", err.Error())
+	}
+
+	if version != 4 {
+		t.Fatalf("expected rollback to create version 4, but got %d", version)
+	}
+
+	secret, err = kv.Get(ctx, "secret/foo")
+
+	if err != nil {
+		t.Fatalf("failed to get secret: %s", err.Error())
+	}
+
+	assertKVValue(t, secret, "password", "one")
+
+	if _, err := kv.GetVersion(ctx, "secret/foo", 10); err != ErrKVVersionNotFound {
+		t.Fatalf("expected version not found, but got %v", err)
+	}
+
+}
+
+func TestKVDeleteUndeleteDestroy(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("to slow for testing.Short. IO operations")
+	}
+
+	kv, err := NewKV(setupBarrier(t))
+
+	if err != nil {
+		t.Fatalf("failed to instantiate kv store: %s", err.Error())
+	}
+
+	ctx := context.Background()
+
+	if _, err := kv.Put(ctx, "secret/foo", testEntries("password", "one"), nil); err != nil {
+		t.Fatalf("failed to put secret: %s", err.Error())
+	}
+
+	if err := kv.Delete(ctx, "secret/foo", nil); err != nil {
+		t.Fatalf("failed to delete secret: %s", err.Error())
+	}
+
+	secret, err := kv.Get(ctx, "secret/foo")
+
+	if err != nil {
+		t.Fatalf("failed to get secret: %s", err.Error())
+	}
+
+	if !secret.Metadata.Deleted() || secret.Entries != nil {
+		t.Fatalf("expected current version to be deleted")
+	}
+
+	if err := kv.Undelete(ctx, "secret/foo", []uint64{1}); err != nil {
+		t.Fatalf("failed to undelete secret: %s", err.Error())
+	}
+
+	secret, err = kv.Get(ctx, "secret/foo")
+
+	if err != nil {
+		t.Fatalf("failed to get secret: %s", err.Error())
+	}
+
+	assertKVValue(t, secret, "password", "one")
+
+	if err := kv.Destroy(ctx, "secret/foo", []uint64{1}); err != nil {
+		t.Fatalf("failed to destroy secret: %s", err.Error())
+	}
+
+	if err := kv.Undelete(ctx, "secret/foo", []uint64{1}); err != nil {
+		t.Fatalf("failed to undelete secret: %s", err.Error())
+	}
+
+	secret, err = kv.Get(ctx, "secret/foo")
+
+	if err != nil {
+		t.Fatalf("failed to get secret: %s", err.Error())
+	}
+
+	if !secret.Metadata.Destroyed || secret.Entries != nil {
+		t.Fatalf("expected destroyed version to not be restored")
+	}
+
+	if err := kv.DestroyAll(ctx, "secret/foo"); err != nil {
+		t.Fatalf("failed to destroy all versions: %s", err.Error())
+	}
+
+	secret, err = kv.Get(ctx, "secret/foo")
+
+	if err != nil {
+		t.Fatalf("failed to get secret: %s", err.Error())
+	}
+
+	if secret != nil {
+		t.Fatalf("expected no secret after destroying all versions, but got %#v", secret)
+	}
+
+}
+
+func TestKVMaxVersions(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("to slow for testing.Short. IO operations")
+	}
+
+	kv, err := NewKV(setupBarrier(t))
+
+	if err != nil {
+		t.Fatalf("failed to instantiate kv store: %s", err.Error())
+	}
+
+	ctx := context.Background()
+
+	maxVersions := 2
+
+	if err := kv.PutMetadata(ctx, "secret/foo", &KVMetadataInput{MaxVersions: &maxVersions}); err != nil {
+		t.Fatalf("failed to put metadata: %s", err.Error())
+	}
+
+	for _, value := range []string{"one", "two", "three"} {
+		if _, err := kv.Put(ctx, "secret/foo", testEntries("password", value), nil); err != nil {
+			t.Fatalf("failed to put secret: %s", err.Error())
+		}
+	}
+
+	metadata, err := kv.Metadata(ctx, "secret/foo")
+
+	if err != nil {
+		t.Fatalf("failed to get metadata: %s", err.Error())
+	}
+
+	if metadata.OldestVersion != 2 || metadata.CurrentVersion != 3 || len(metadata.Versions) != 2 {
+		t.Fatalf("expected versions 2 through 3 to be retained, but got %#v", metadata)
+	}
+
+	if _, err := kv.GetVersion(ctx, "secret/foo", 1); err != ErrKVVersionNotFound {
+		t.Fatalf("expected pruned version to not be found, but got %v", err)
+	}
+
+}
+
+func TestKVLegacyMigration(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("to slow for testing.Short. IO operations")
+	}
+
+	barrier := setupBarrier(t)
+
+	ctx := context.Background()
+
+	// Simulate a path written before versioning
+	if err := barrier.Put(ctx, "secret/foo", testEntries("password", "legacy")); err != nil {
+		t.Fatalf("failed to put legacy secret: %s", err.Error())
+	}
+
+	kv, err := NewKV(barrier)
+
+	if err != nil {
+		t.Fatalf("failed to instantiate kv store: %s", err.Error())
+	}
+
+	secret, err := kv.Get(ctx, "secret/foo")
+
+	if err != nil {
+		t.Fatalf("failed to get secret: %s", err.Error())
+	}
+
+	assertKVValue(t, secret, "password", "legacy")
+
+	if _, err := kv.Put(ctx, "secret/foo", testEntries("password", "new"), nil); err != nil {
+		t.Fatalf("failed to put secret: %s", err.Error())
+	}
+
+	secret, err = kv.GetVersion(ctx, "secret/foo", 1)
+
+	if err != nil {
+		t.Fatalf("failed to get secret version: %s", err.Error())
+	}
+
+	assertKVValue(t, secret, "password", "legacy")
+
+	secret, err = kv.Get(ctx, "secret/foo")
+
+	if err != nil {
+		t.Fatalf("failed to get secret: %s", err.Error())
+	}
+
+	if secret.Version != 2 {
+		t.Fatalf("expected version 2, but got %d", secret.Version)
+	}
+
+	assertKVValue(t, secret, "password", "new")
+
+}
+
+func TestKVCheckAndSet(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("to slow for testing.Short. IO operations")
+	}
+
+	kv, err := NewKV(setupBarrier(t))
+
+	if err != nil {
+		t.Fatalf("failed to instantiate kv store: %s", err.Error())
+	}
+
+	ctx := context.Background()
+
+	cas := uint64(0)
+
+	if _, err := kv.Put(ctx, "secret/foo", testEntries("password", "one"), &KVPutOptions{CAS: &cas}); err != nil {
+		t.Fatalf("failed to create secret with cas 0: %s", err.Error())
+	}
+
+	if _, err := kv.Put(ctx, "secret/foo", testEntries("password", "two"), &KVPutOptions{CAS: &cas}); !errors.Is(err, ErrKVCASMismatch) {
+		t.Fatalf("expected cas 0 to conflict with an existing secret, but got %v", err)
+	}
+
+	cas = 1
+
+	if _, err := kv.Put(ctx, "secret/foo", testEntries("password", "two"), &KVPutOptions{CAS: &cas}); err != nil {
+		t.Fatalf("failed to put secret with matching cas: %s", err.Error())
+	}
+
+	required := true
+
+	if err := kv.PutMetadata(ctx, "secret/foo", &KVMetadataInput{CASRequired: &required}); err != nil {
+		t.Fatalf("failed to put metadata: %s", err.Error())
+	}
+
+	if _, err := kv.Put(ctx, "secret/foo", testEntries("password", "three"), nil); err != ErrKVCASRequired {
+		t.Fatalf("expected cas to be required, but got %v", err)
+	}
+
+	if _, err := kv.Rollback(ctx, "secret/foo", 1, nil); err != ErrKVCASRequired {
+		t.Fatalf("expected cas to be required for rollback, but got %v", err)
+	}
+
+	if _, err := kv.Put(ctx, "secret/other", testEntries("password", "other"), nil); err != nil {
+		t.Fatalf("failed to put secret: %s", err.Error())
+	}
+
+	err = kv.Copy(ctx, []*KVRelocation{{Source: "secret/other", Destination: "secret/foo"}}, true)
+
+	if !errors.Is(err, ErrKVCASRequired) {
+		t.Fatalf("expected a forced copy onto a cas required path to be refused, but got %v", err)
+	}
+
+	secret, err := kv.Get(ctx, "secret/foo")
+
+	if err != nil {
+		t.Fatalf("failed to get secret: %s", err.Error())
+	}
+
+	assertKVValue(t, secret, "password", "two")
+
+	current := secret.Version
+
+	if _, err := kv.Rollback(ctx, "secret/foo", 1, &KVPutOptions{CAS: &current}); err != nil {
+		t.Fatalf("failed to rollback with cas: %s", err.Error())
+	}
+
+}
+
+func TestKVPatchUnset(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("to slow for testing.Short. IO operations")
+	}
+
+	kv, err := NewKV(setupBarrier(t))
+
+	if err != nil {
+		t.Fatalf("failed to instantiate kv store: %s", err.Error())
+	}
+
+	ctx := context.Background()
+
+	if _, err := kv.Patch(ctx, "secret/foo", testEntries("a", "1"), nil); err != ErrKVNotFound {
+		t.Fatalf("expected patching a missing path to fail, but got %v", err)
+	}
+
+	if _, err := kv.Put(ctx, "secret/foo", testEntries("a", "1", "b", "2"), nil); err != nil {
+		t.Fatalf("failed to put secret: %s", err.Error())
+	}
+
+	if _, err := kv.Put(ctx, "secret/foo", testEntries("c", "3"), nil); err != nil {
+		t.Fatalf("failed to put secret: %s", err.Error())
+	}
+
+	if _, err := kv.Patch(ctx, "secret/foo", testEntries("d", "4"), nil); err != nil {
+		t.Fatalf("failed to patch secret: %s", err.Error())
+	}
+
+	if _, err := kv.Unset(ctx, "secret/foo", []string{"c"}, nil); err != nil {
+		t.Fatalf("failed to unset secret key: %s", err.Error())
+	}
+
+	secret, err := kv.Get(ctx, "secret/foo")
+
+	if err != nil {
+		t.Fatalf("failed to get secret: %s", err.Error())
+	}
+
+	if len(secret.Entries) != 1 {
+		t.Fatalf("expected only a single key to remain, but got %d", len(secret.Entries))
+	}
+
+	assertKVValue(t, secret, "d", "4")
+
+}
+
+func TestKVMetadata(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("to slow for testing.Short. IO operations")
+	}
+
+	kv, err := NewKV(setupBarrier(t))
+
+	if err != nil {
+		t.Fatalf("failed to instantiate kv store: %s", err.Error())
+	}
+
+	kv.identity = "tester"
+
+	ctx := context.Background()
+
+	if _, err := kv.Put(ctx, "secret/foo", testEntries("password", "one"), nil); err != nil {
+		t.Fatalf("failed to put secret: %s", err.Error())
+	}
+
+	input := &KVMetadataInput{
+		CustomMetadata: map[string]string{
+			"owner": "payments",
+			"stale": "true",
+		},
+	}
+
+	if err := kv.PutMetadata(ctx, "secret/foo", input); err != nil {
+		t.Fatalf("failed to put metadata: %s", err.Error())
+	}
+
+	input.CustomMetadata = map[string]string{
+		"stale": "",
+	}
+
+	if err := kv.PutMetadata(ctx, "secret/foo", input); err != nil {
+		t.Fatalf("failed to put metadata: %s", err.Error())
+	}
+
+	metadata, err := kv.Metadata(ctx, "secret/foo")
+
+	if err != nil {
+		t.Fatalf("failed to get metadata: %s", err.Error())
+	}
+
+	if !metadata.LastReadTime.IsZero() {
+		t.Fatalf("expected no reads to be recorded, but got %s", metadata.LastReadTime)
+	}
+
+	if len(metadata.CustomMetadata) != 1 || metadata.CustomMetadata["owner"] != "payments" {
+		t.Fatalf("expected only the owner custom metadata to remain, but got %#v", metadata.CustomMetadata)
+	}
+
+	if metadata.UpdatedBy != "tester" || metadata.Versions[1].CreatedBy != "tester" {
+		t.Fatalf("expected changes to be attributed to the kv identity")
+	}
+
+	if _, err := kv.Get(ctx, "secret/foo"); err != nil {
+		t.Fatalf("failed to get secret: %s", err.Error())
+	}
+
+	metadata, err = kv.Metadata(ctx, "secret/foo")
+
+	if err != nil {
+		t.Fatalf("failed to get metadata: %s", err.Error())
+	}
+
+	if !metadata.LastReadTime.IsZero() {
+		t.Fatalf("expected reads to not be recorded unless requested, but got %s", metadata.LastReadTime)
+	}
+
+	if err := kv.RecordRead(ctx, "secret/foo"); err != nil {
+		t.Fatalf("failed to record read: %s", err.Error())
+	}
+
+	metadata, err = kv.Metadata(ctx, "secret/foo")
+
+	if err != nil {
+		t.Fatalf("failed to get metadata: %s", err.Error())
+	}
+
+	if metadata.LastReadTime.IsZero() {
+		t.Fatalf("expected the read to be recorded")
+	}
+
+}
+
+func TestKVList(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("to slow for testing.Short. IO operations")
+	}
+
+	kv, err := NewKV(setupBarrier(t))
+
+	if err != nil {
+		t.Fatalf("failed to instantiate kv store: %s", err.Error())
+	}
+
+	ctx := context.Background()
+
+	for _, path := range []string{"secret/app", "secret/app/db", "secret/app/cache/redis", "secret/apple"} {
+		if _, err := kv.Put(ctx, path, testEntries("password", "one"), nil); err != nil {
+			t.Fatalf("failed to put secret: %s", err.Error())
+		}
+	}
+
+	tests := []struct {
+		folder    string
+		recursive bool
+		expected  []string
+	}{
+		{"", false, []string{"secret/"}},
+		{"secret/", false, []string{"app", "app/", "apple"}},
+		{"secret/app", false, []string{"cache/", "db"}},
+		{"secret/ap", false, []string{}},
+		{"secret/app", true, []string{"cache/redis", "db"}},
+	}
+
+	for _, test := range tests {
+
+		children, err := kv.List(ctx, test.folder, test.recursive)
+
+		if err != nil {
+			t.Fatalf("failed to list folder '%s': %s", test.folder, err.Error())
+		}
+
+		if !reflect.DeepEqual(children, test.expected) {
+			t.Fatalf("expected folder '%s' to list %v, but got %v", test.folder, test.expected, children)
+		}
+	}
+
+}
+
+func TestKVSelect(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("to slow for testing.Short. IO operations")
+	}
+
+	kv, err := NewKV(setupBarrier(t))
+
+	if err != nil {
+		t.Fatalf("failed to instantiate kv store: %s", err.Error())
+	}
+
+	ctx := context.Background()
+
+	labels := map[string]map[string]string{
+		"secret/payments/db":  {"env": "prod", "team": "payments"},
+		"secret/payments/api": {"env": "dev", "team": "payments"},
+		"secret/infra/vpn":    {"env": "prod", "team": "infra"},
+		"secret/unlabeled":    nil,
+	}
+
+	for path, pathLabels := range labels {
+
+		if _, err := kv.Put(ctx, path, testEntries("password", "one"), nil); err != nil {
+			t.Fatalf("failed to put secret: %s", err.Error())
+		}
+
+		if err := kv.PutMetadata(ctx, path, &KVMetadataInput{Labels: pathLabels}); err != nil {
+			t.Fatalf("failed to label %s: %s", path, err.Error())
+		}
+	}
+
+	tests := []struct {
+		folder   string
+		selector string
+		expected []string
+	}{
+		{"secret/", "env=prod", []string{"infra/vpn", "payments/db"}},
+		{"secret/", "env=prod,team!=infra", []string{"payments/db"}},
+		{"secret/payments", "env in (dev,staging)", []string{"api"}},
+		{"secret/", "!team", []string{"unlabeled"}},
+	}
+
+	for _, test := range tests {
+
+		selector, err := ParseLabelSelector(test.selector)
+
+		if err != nil {
+			t.Fatalf("failed to parse selector %q: %s", test.selector, err.Error())
+		}
+
+		selected, err := kv.Select(ctx, test.folder, selector)
+
+		if err != nil {
+			t.Fatalf("failed to select %q: %s", test.selector, err.Error())
+		}
+
+		if !reflect.DeepEqual(selected, test.expected) {
+			t.Fatalf("expected selector %q to select %v, but got %v", test.selector, test.expected, selected)
+		}
+	}
+
+	// Labels with an empty value are removed.
+	err = kv.PutMetadata(ctx, "secret/infra/vpn", &KVMetadataInput{Labels: map[string]string{"team": ""}})
+
+	if err != nil {
+		t.Fatalf("failed to remove label: %s", err.Error())
+	}
+
+	metadata, _ := kv.Metadata(ctx, "secret/infra/vpn")
+
+	if _, ok := metadata.Labels["team"]; ok || metadata.Labels["env"] != "prod" {
+		t.Fatalf("expected only the team label to be removed, but got %v", metadata.Labels)
+	}
+
+	err = kv.PutMetadata(ctx, "secret/infra/vpn", &KVMetadataInput{Labels: map[string]string{"team": "in fra"}})
+
+	if err == nil {
+		t.Fatal("expected an invalid label to fail")
+	}
+}
+
+func TestKVDestroyPaths(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("to slow for testing.Short. IO operations")
+	}
+
+	kv, err := NewKV(setupBarrier(t))
+
+	if err != nil {
+		t.Fatalf("failed to instantiate kv store: %s", err.Error())
+	}
+
+	ctx := context.Background()
+
+	for _, path := range []string{"secret/app/db", "secret/app/cache", "secret/other"} {
+		if _, err := kv.Put(ctx, path, testEntries("password", "one"), nil); err != nil {
+			t.Fatalf("failed to put secret: %s", err.Error())
+		}
+	}
+
+	if err := kv.DestroyPaths(ctx, []string{"secret/app/db", "secret/app/cache"}); err != nil {
+		t.Fatalf("failed to destroy paths: %s", err.Error())
+	}
+
+	children, err := kv.List(ctx, "secret", true)
+
+	if err != nil {
+		t.Fatalf("failed to list folder: %s", err.Error())
+	}
+
+	if !reflect.DeepEqual(children, []string{"other"}) {
+		t.Fatalf("expected only the other path to remain, but got %v", children)
+	}
+
+}
+
+func TestKVExpiration(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("to slow for testing.Short. IO operations")
+	}
+
+	kv, err := NewKV(setupBarrier(t))
+
+	if err != nil {
+		t.Fatalf("failed to instantiate kv store: %s", err.Error())
+	}
+
+	ctx := context.Background()
+
+	if _, err := kv.Put(ctx, "secret/temp", testEntries("password", "one"), &KVPutOptions{TTL: time.Hour}); err != nil {
+		t.Fatalf("failed to put secret: %s", err.Error())
+	}
+
+	if _, err := kv.Put(ctx, "secret/later", testEntries("password", "two"), &KVPutOptions{TTL: 72 * time.Hour}); err != nil {
+		t.Fatalf("failed to put secret: %s", err.Error())
+	}
+
+	if _, err := kv.Put(ctx, "secret/forever", testEntries("password", "three"), nil); err != nil {
+		t.Fatalf("failed to put secret: %s", err.Error())
+	}
+
+	// A write without a ttl keeps the existing expiration.
+	if _, err := kv.Put(ctx, "secret/temp", testEntries("password", "four"), nil); err != nil {
+		t.Fatalf("failed to put secret: %s", err.Error())
+	}
+
+	expiring, err := kv.Expiring(ctx, "secret", 2*time.Hour)
+
+	if err != nil {
+		t.Fatalf("failed to list expiring paths: %s", err.Error())
+	}
+
+	if len(expiring) != 1 || expiring[0].Path != "temp" {
+		t.Fatalf("expected only the temp path to be expiring, but got %d paths", len(expiring))
+	}
+
+	kv.now = func() time.Time {
+		return time.Now().Add(2 * time.Hour)
+	}
+
+	if _, err := kv.Get(ctx, "secret/temp"); !errors.Is(err, ErrKVExpired) {
+		t.Fatalf("expected expired error, but got %v", err)
+	}
+
+	reaped, err := kv.Reap(ctx)
+
+	if err != nil {
+		t.Fatalf("failed to reap expired paths: %s", err.Error())
+	}
+
+	if !reflect.DeepEqual(reaped, []string{"secret/temp"}) {
+		t.Fatalf("expected only the temp path to be reaped, but got %v", reaped)
+	}
+
+	children, err := kv.List(ctx, "secret", true)
+
+	if err != nil {
+		t.Fatalf("failed to list folder: %s", err.Error())
+	}
+
+	if !reflect.DeepEqual(children, []string{"forever", "later"}) {
+		t.Fatalf("expected the unexpired paths to remain, but got %v", children)
+	}
+
+}
+
+func TestKVPutExpired(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("to slow for testing.Short. IO operations")
+	}
+
+	kv, err := NewKV(setupBarrier(t))
+
+	if err != nil {
+		t.Fatalf("failed to instantiate kv store: %s", err.Error())
+	}
+
+	ctx := context.Background()
+
+	if _, err := kv.Put(ctx, "secret/temp", testEntries("password", "one"), &KVPutOptions{TTL: time.Hour}); err != nil {
+		t.Fatalf("failed to put secret: %s", err.Error())
+	}
+
+	kv.now = func() time.Time {
+		return time.Now().Add(2 * time.Hour)
+	}
+
+	if _, err := kv.Rollback(ctx, "secret/temp", 1, nil); !errors.Is(err, ErrKVExpired) {
+		t.Fatalf("expected rollback of an expired path to be refused, but got %v", err)
+	}
+
+	// A write without a ttl removes an expiration which has passed.
+	if _, err := kv.Put(ctx, "secret/temp", testEntries("password", "two"), nil); err != nil {
+		t.Fatalf("failed to put secret: %s", err.Error())
+	}
+
+	secret, err := kv.Get(ctx, "secret/temp")
+
+	if err != nil {
+		t.Fatalf("failed to get secret: %s", err.Error())
+	}
+
+	assertKVValue(t, secret, "password", "two")
+
+	if !secret.PathMetadata.ExpireTime.IsZero() {
+		t.Fatalf("expected the expiration to be removed, but got %s", secret.PathMetadata.ExpireTime)
+	}
+
+}
+
+func TestKVCopyMove(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("to slow for testing.Short. IO operations")
+	}
+
+	kv, err := NewKV(setupBarrier(t))
+
+	if err != nil {
+		t.Fatalf("failed to instantiate kv store: %s", err.Error())
+	}
+
+	ctx := context.Background()
+
+	for _, value := range []string{"one", "two"} {
+		if _, err := kv.Put(ctx, "secret/app-old", testEntries("password", value), nil); err != nil {
+			t.Fatalf("failed to put secret: %s", err.Error())
+		}
+	}
+
+	if _, err := kv.Put(ctx, "secret/taken", testEntries("password", "taken"), nil); err != nil {
+		t.Fatalf("failed to put secret: %s", err.Error())
+	}
+
+	err = kv.Copy(ctx, []*KVRelocation{{Source: "secret/app-old", Destination: "secret/copy"}}, false)
+
+	if err != nil {
+		t.Fatalf("failed to copy path: %s", err.Error())
+	}
+
+	copied, err := kv.GetVersion(ctx, "secret/copy", 1)
+
+	if err != nil {
+		t.Fatalf("failed to get copied version: %s", err.Error())
+	}
+
+	assertKVValue(t, copied, "password", "one")
+
+	// A failed move leaves every path untouched.
+	err = kv.Move(ctx, []*KVRelocation{
+		{Source: "secret/app-old", Destination: "secret/app"},
+		{Source: "secret/copy", Destination: "secret/taken"},
+	}, false)
+
+	if !errors.Is(err, ErrKVDestinationExists) {
+		t.Fatalf("expected destination exists error, but got %v", err)
+	}
+
+	if secret, err := kv.Get(ctx, "secret/app"); err != nil || secret != nil {
+		t.Fatalf("expected nothing to be moved, but got %v, %v", secret, err)
+	}
+
+	err = kv.Move(ctx, []*KVRelocation{
+		{Source: "secret/app-old", Destination: "secret/app"},
+		{Source: "secret/copy", Destination: "secret/taken"},
+	}, true)
+
+	if err != nil {
+		t.Fatalf("failed to move paths: %s", err.Error())
+	}
+
+	moved, err := kv.Get(ctx, "secret/app")
+
+	if err != nil {
+		t.Fatalf("failed to get moved secret: %s", err.Error())
+	}
+
+	if moved.Version != 2 {
+		t.Fatalf("expected the version history to be moved, but got version %d", moved.Version)
+	}
+
+	assertKVValue(t, moved, "password", "two")
+
+	overwritten, err := kv.Get(ctx, "secret/taken")
+
+	if err != nil {
+		t.Fatalf("failed to get overwritten secret: %s", err.Error())
+	}
+
+	assertKVValue(t, overwritten, "password", "two")
+
+	children, err := kv.List(ctx, "secret", true)
+
+	if err != nil {
+		t.Fatalf("failed to list folder: %s", err.Error())
+	}
+
+	if !reflect.DeepEqual(children, []string{"app", "taken"}) {
+		t.Fatalf("expected the sources to be removed, but got %v", children)
+	}
+
+}
+
+func testEntries(kvPairs ...string) []*backend.BackendEntry {
+
+	entries := make([]*backend.BackendEntry, 0, len(kvPairs)/2)
+
+	for i := 0; i+1 < len(kvPairs); i += 2 {
+		entries = append(entries, &backend.BackendEntry{
+			Key:   kvPairs[i],
+			Value: []byte(kvPairs[i+1]),
+		})
+	}
+
+	return entries
+}
+
+func assertKVValue(t *testing.T, secret *KVSecret, key string, value string) {
+	t.Helper()
+
+	if secret == nil {
+		t.Fatalf("expected a secret, but got nil")
+	}
+
+	for _, entry := range secret.Entries {
+		if entry.Key == key {
+
+			if string(entry.Value) != value {
+				t.Fatalf("expected key '%s' to be '%s', but got '%s'", key, value, entry.Value)
+			}
+
+			return
+		}
+	}
+
+	t.Fatalf("expected key '%s' in version %d", key, secret.Version)
+}
@@ -0,0 +1,241 @@
+package internal
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	selectorEquals    = "="
+	selectorNotEquals = "!="
+	selectorIn        = "in"
+	selectorNotIn     = "notin"
+	selectorExists    = "exists"
+	selectorNotExists = "!"
+)
+
+var (
+	labelNamePattern   = regexp.MustCompile(`^[A-Za-z0-9]([-A-Za-z0-9_.]{0,61}[A-Za-z0-9])?$`)
+	labelPrefixPattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`)
+	selectorSetPattern = regexp.MustCompile(`^(\S+)\s+(in|notin)\s*\(([^()]*)\)$`)
+)
+
+// ValidateLabel checks the label follows the syntax of Kubernetes labels.
+// Keys are a name with an optional DNS subdomain prefix, such as
+// example.com/team, and values are a name or empty.
+func ValidateLabel(key string, value string) error {
+
+	if err := validateLabelKey(key); err != nil {
+		return err
+	}
+
+	return validateLabelValue(value)
+}
+
+func validateLabelKey(key string) error {
+
+	name := key
+
+	if i := strings.LastIndex(key, "/"); i >= 0 {
+
+		prefix := key[:i]
+		name = key[i+1:]
+
+		if len(prefix) > 253 || !labelPrefixPattern.MatchString(prefix) {
+			return fmt.Errorf("invalid label key '%s': the prefix must be a DNS subdomain", key)
+		}
+	}
+
+	if !labelNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid label key '%s': the name must be at most 63 alphanumeric characters, '-', '_' or '.', starting and ending with an alphanumeric character", key)
+	}
+
+	return nil
+}
+
+func validateLabelValue(value string) error {
+
+	if value != "" && !labelNamePattern.MatchString(value) {
+		return fmt.Errorf("invalid label value '%s': the value must be at most 63 alphanumeric characters, '-', '_' or '.', starting and ending with an alphanumeric character", value)
+	}
+
+	return nil
+}
+
+// LabelSelector selects paths by their labels, with the syntax of
+// Kubernetes label selectors, such as "env=prod,team!=infra". A path is
+// selected when it matches every requirement of the selector.
+type LabelSelector struct {
+	requirements []*labelRequirement
+}
+
+type labelRequirement struct {
+	key      string
+	operator string
+	values   []string
+}
+
+// ParseLabelSelector parses a comma separated list of requirements:
+//
+//	key=value, key==value   the label is set to the value
+//	key!=value              the label is not set to the value, or is unset
+//	key in (a,b)            the label is set to one of the values
+//	key notin (a,b)         the label is not set to any of the values
+//	key                     the label is set
+//	!key                    the label is not set
+//
+// An empty selector selects every path.
+func ParseLabelSelector(raw string) (*LabelSelector, error) {
+
+	selector := &LabelSelector{}
+
+	for _, part := range splitSelector(raw) {
+
+		part = strings.TrimSpace(part)
+
+		if part == "" {
+			continue
+		}
+
+		requirement, err := parseLabelRequirement(part)
+
+		if err != nil {
+			return nil, err
+		}
+
+		selector.requirements = append(selector.requirements, requirement)
+	}
+
+	return selector, nil
+}
+
+// splitSelector splits the selector on the commas outside of parentheses.
+func splitSelector(raw string) []string {
+
+	parts := make([]string, 0)
+	depth := 0
+	start := 0
+
+	for i, r := range raw {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, raw[start:i])
+				start = i + 1
+			}
+		}
+	}
+
+	return append(parts, raw[start:])
+}
+
+func parseLabelRequirement(raw string) (*labelRequirement, error) {
+
+	requirement := &labelRequirement{}
+
+	switch {
+
+	case selectorSetPattern.MatchString(raw):
+
+		matches := selectorSetPattern.FindStringSubmatch(raw)
+
+		requirement.key = matches[1]
+		requirement.operator = matches[2]
+
+		for _, value := range strings.Split(matches[3], ",") {
+			requirement.values = append(requirement.values, strings.TrimSpace(value))
+		}
+
+	case strings.Contains(raw, "!="):
+
+		parts := strings.SplitN(raw, "!=", 2)
+
+		requirement.key = strings.TrimSpace(parts[0])
+		requirement.operator = selectorNotEquals
+		requirement.values = []string{strings.TrimSpace(parts[1])}
+
+	case strings.Contains(raw, "="):
+
+		parts := strings.SplitN(raw, "=", 2)
+
+		requirement.key = strings.TrimSpace(parts[0])
+		requirement.operator = selectorEquals
+		requirement.values = []string{strings.TrimSpace(strings.TrimPrefix(parts[1], "="))}
+
+	case strings.HasPrefix(raw, "!"):
+		requirement.key = strings.TrimSpace(raw[1:])
+		requirement.operator = selectorNotExists
+
+	default:
+		requirement.key = raw
+		requirement.operator = selectorExists
+	}
+
+	if err := validateLabelKey(requirement.key); err != nil {
+		return nil, fmt.Errorf("invalid selector '%s': %s", raw, err.Error())
+	}
+
+	for _, value := range requirement.values {
+		if err := validateLabelValue(value); err != nil {
+			return nil, fmt.Errorf("invalid selector '%s': %s", raw, err.Error())
+		}
+	}
+
+	return requirement, nil
+}
+
+// Empty reports if the selector selects every path.
+func (s *LabelSelector) Empty() bool {
+	return len(s.requirements) == 0
+}
+
+// Matches reports if the labels match every requirement of the selector.
+func (s *LabelSelector) Matches(labels map[string]string) bool {
+
+	for _, requirement := range s.requirements {
+		if !requirement.matches(labels) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (r *labelRequirement) matches(labels map[string]string) bool {
+
+	value, ok := labels[r.key]
+
+	switch r.operator {
+
+	case selectorExists:
+		return ok
+
+	case selectorNotExists:
+		return !ok
+
+	case selectorEquals, selectorIn:
+		return ok && containsString(r.values, value)
+
+	case selectorNotEquals, selectorNotIn:
+		return !ok || !containsString(r.values, value)
+
+	default:
+		return false
+	}
+}
+
+func containsString(values []string, value string) bool {
+
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+
+	return false
+}
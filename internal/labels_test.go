@@ -0,0 +1,69 @@
+package internal
+
+import (
+	"testing"
+)
+
+func TestLabelSelector(t *testing.T) {
+
+	labels := map[string]string{
+		"env":              "prod",
+		"team":             "payments",
+		"example.com/tier": "1",
+	}
+
+	tests := []struct {
+		selector string
+		expected bool
+	}{
+		{"", true},
+		{"env=prod", true},
+		{"env==prod", true},
+		{"env=dev", false},
+		{"env=prod,team!=infra", true},
+		{"env=prod,team!=payments", false},
+		{"rotation!=quarterly", true},
+		{"env in (dev, prod)", true},
+		{"env in (dev,staging),team", false},
+		{"team notin (infra)", true},
+		{"rotation notin (quarterly)", true},
+		{"team", true},
+		{"!team", false},
+		{"!rotation", true},
+		{"example.com/tier=1", true},
+	}
+
+	for _, test := range tests {
+
+		selector, err := ParseLabelSelector(test.selector)
+
+		if err != nil {
+			t.Fatalf("failed to parse selector %q: %s", test.selector, err.Error())
+		}
+
+		if selector.Matches(labels) != test.expected {
+			t.Fatalf("expected selector %q to match %t", test.selector, test.expected)
+		}
+	}
+
+	for _, invalid := range []string{"env=pr od", "-env=prod", "Example.com/tier=1", "env in (a,b", "=prod"} {
+		if _, err := ParseLabelSelector(invalid); err == nil {
+			t.Fatalf("expected selector %q to be invalid", invalid)
+		}
+	}
+}
+
+func TestValidateLabel(t *testing.T) {
+
+	if err := ValidateLabel("rotation", "quarterly"); err != nil {
+		t.Fatalf("expected label to be valid: %s", err.Error())
+	}
+
+	if err := ValidateLabel("team", "payments/eu"); err == nil {
+		t.Fatal("expected a value containing '/' to be invalid")
+	}
+
+	if err := ValidateLabel("", "prod"); err == nil {
+		t.Fatal("expected an empty key to be invalid")
+	}
+}
@@ -0,0 +1,112 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+)
+
+const (
+	PaddingNone = "none"
+
+	// PaddingBuckets pads values to the smallest of the padding buckets
+	// which fits them. Larger values are padded to a multiple of the
+	// largest bucket.
+	PaddingBuckets = "buckets"
+
+	// PaddingPADME pads values with the PADMÉ scheme, which leaks at most
+	// O(log log n) bits of the length while adding at most 12% overhead.
+	PaddingPADME = "padme"
+)
+
+// paddingBuckets are the lengths values are padded to with PaddingBuckets.
+var paddingBuckets = []int{64, 256, 1024, 4096}
+
+// paddingDelimiter marks the end of the value, followed only by zeros,
+// as in ISO/IEC 7816-4 padding.
+const paddingDelimiter = 0x80
+
+var ErrPaddingInvalid = errors.New("padding is invalid")
+
+// ValidatePadding checks the padding scheme is supported.
+func ValidatePadding(scheme string) error {
+
+	switch scheme {
+	case "", PaddingNone, PaddingBuckets, PaddingPADME:
+		return nil
+	default:
+		return fmt.Errorf("unsupported padding '%s'. Must be one of none, buckets or padme", scheme)
+	}
+}
+
+// paddingEnabled reports if values are padded with the scheme.
+func paddingEnabled(scheme string) bool {
+	return scheme == PaddingBuckets || scheme == PaddingPADME
+}
+
+// paddedLength reports the length a value of n bytes is padded to,
+// including the delimiter.
+func paddedLength(scheme string, n int) int {
+
+	length := n + 1
+
+	switch scheme {
+
+	case PaddingBuckets:
+
+		for _, bucket := range paddingBuckets {
+			if length <= bucket {
+				return bucket
+			}
+		}
+
+		largest := paddingBuckets[len(paddingBuckets)-1]
+
+		return (length + largest - 1) / largest * largest
+
+	case PaddingPADME:
+
+		if length < 2 {
+			return length
+		}
+
+		exponent := bits.Len(uint(length)) - 1
+		significant := bits.Len(uint(exponent))
+		mask := (1 << (exponent - significant)) - 1
+
+		return (length + mask) &^ mask
+
+	default:
+		return length
+	}
+}
+
+// padValue appends the delimiter and zeros up to the padded length.
+func padValue(scheme string, value []byte) []byte {
+
+	padded := make([]byte, len(value), paddedLength(scheme, len(value)))
+
+	copy(padded, value)
+
+	padded = append(padded, paddingDelimiter)
+
+	return padded[:cap(padded)]
+}
+
+// unpadValue strips the zeros and delimiter appended by padValue.
+func unpadValue(padded []byte) ([]byte, error) {
+
+	for i := len(padded) - 1; i >= 0; i-- {
+
+		switch padded[i] {
+		case 0:
+			continue
+		case paddingDelimiter:
+			return padded[:i], nil
+		default:
+			return nil, ErrPaddingInvalid
+		}
+	}
+
+	return nil, ErrPaddingInvalid
+}
@@ -0,0 +1,68 @@
+package internal
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPaddedLength(t *testing.T) {
+
+	cases := []struct {
+		scheme   string
+		length   int
+		expected int
+	}{
+		{PaddingBuckets, 0, 64},
+		{PaddingBuckets, 4, 64},
+		{PaddingBuckets, 63, 64},
+		{PaddingBuckets, 64, 256},
+		{PaddingBuckets, 3000, 4096},
+		{PaddingBuckets, 4096, 8192},
+		{PaddingPADME, 0, 1},
+		{PaddingPADME, 8, 10},
+		{PaddingPADME, 99, 104},
+		{PaddingPADME, 1000, 1024},
+		{PaddingNone, 10, 11},
+	}
+
+	for _, tc := range cases {
+		if actual := paddedLength(tc.scheme, tc.length); actual != tc.expected {
+			t.Fatalf("expected %s padding of %d bytes to be %d, but got %d", tc.scheme, tc.length, tc.expected, actual)
+		}
+	}
+}
+
+func TestPadValue(t *testing.T) {
+
+	values := [][]byte{
+		{},
+		[]byte("1234"),
+		{0x80, 0x00, 0x80, 0x00},
+		bytes.Repeat([]byte{0xff}, 5000),
+	}
+
+	for _, scheme := range []string{PaddingBuckets, PaddingPADME} {
+		for _, value := range values {
+
+			padded := padValue(scheme, value)
+
+			if len(padded) != paddedLength(scheme, len(value)) {
+				t.Fatalf("expected %s padded length %d, but got %d", scheme, paddedLength(scheme, len(value)), len(padded))
+			}
+
+			unpadded, err := unpadValue(padded)
+
+			if err != nil {
+				t.Fatalf("failed to unpad %s value: %s", scheme, err.Error())
+			}
+
+			if !bytes.Equal(unpadded, value) {
+				t.Fatalf("expected %s unpadded value to round trip", scheme)
+			}
+		}
+	}
+
+	if _, err := unpadValue([]byte{0x01, 0x00}); err == nil {
+		t.Fatal("expected padding without a delimiter to be invalid")
+	}
+}
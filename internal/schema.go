@@ -0,0 +1,305 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"github.com/woodrufj4/keyring-practice/backend"
+)
+
+// schemasPath holds the JSON schemas stored through the barrier, keyed by
+// the path prefix they are bound to.
+const schemasPath = "core/schemas"
+
+var (
+	ErrSchemaNotFound = errors.New("no schema found for prefix")
+	ErrSchemaInvalid  = errors.New("schema is invalid")
+)
+
+// SchemaViolation is a single reason the data at a path does not match its
+// schema.
+type SchemaViolation struct {
+
+	// Location is the JSON pointer of the value within the data, such as
+	// /port. The data itself is "/".
+	Location string
+	Message  string
+}
+
+// SchemaValidationError is returned when the data at a path does not match
+// the schema bound to the path.
+type SchemaValidationError struct {
+	Path       string
+	Prefix     string
+	Violations []*SchemaViolation
+}
+
+func (e *SchemaValidationError) Error() string {
+
+	messages := make([]string, 0, len(e.Violations))
+
+	for _, violation := range e.Violations {
+		messages = append(messages, fmt.Sprintf("%s: %s", violation.Location, violation.Message))
+	}
+
+	return fmt.Sprintf("'%s' does not match the schema for '%s': %s", e.Path, e.Prefix, strings.Join(messages, "; "))
+}
+
+// Schemas binds JSON schemas to path prefixes. The key-value pairs written
+// to a path are validated against the schema of the longest prefix of the
+// path, as a JSON object of the keys and their values.
+//
+// Prefixes are treated as folders, so a schema bound to "secret/db" applies
+// to secret/db and the paths within it, but not to secret/db2.
+type Schemas struct {
+	barrier *Barrier
+}
+
+// NewSchemas instantiates the schemas stored within an initialized barrier.
+func NewSchemas(barrier *Barrier) (*Schemas, error) {
+
+	if barrier == nil || !barrier.Initialized() {
+		return nil, ErrKeyringNotSet
+	}
+
+	return &Schemas{
+		barrier: barrier,
+	}, nil
+}
+
+// Put binds the schema to the prefix, replacing any existing schema of the
+// prefix. The schema is compiled first, so invalid schemas are refused.
+func (s *Schemas) Put(ctx context.Context, prefix string, schema []byte) error {
+
+	if prefix == "" {
+		return fmt.Errorf("a prefix is required")
+	}
+
+	if _, err := compileSchema(schema); err != nil {
+		return err
+	}
+
+	return s.barrier.Put(ctx, schemasPath, []*backend.BackendEntry{
+		{
+			Key:   schemaPrefix(prefix),
+			Value: schema,
+		},
+	})
+}
+
+// Get retrieves the schema bound to the prefix.
+func (s *Schemas) Get(ctx context.Context, prefix string) ([]byte, error) {
+
+	schemas, err := s.all(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	schema, ok := schemas[schemaPrefix(prefix)]
+
+	if !ok {
+		return nil, fmt.Errorf("%w '%s'", ErrSchemaNotFound, prefix)
+	}
+
+	return schema, nil
+}
+
+// List reports the prefixes with a schema, in order.
+func (s *Schemas) List(ctx context.Context) ([]string, error) {
+
+	schemas, err := s.all(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	prefixes := make([]string, 0, len(schemas))
+
+	for prefix := range schemas {
+		prefixes = append(prefixes, prefix)
+	}
+
+	sort.Strings(prefixes)
+
+	return prefixes, nil
+}
+
+// Delete removes the schema bound to the prefix.
+func (s *Schemas) Delete(ctx context.Context, prefix string) error {
+
+	if _, err := s.Get(ctx, prefix); err != nil {
+		return err
+	}
+
+	return s.barrier.Transaction(ctx, []*backend.TxnEntry{
+		{
+			Operation: backend.DeleteOperation,
+			Path:      schemasPath,
+			Entries:   []*backend.BackendEntry{{Key: schemaPrefix(prefix)}},
+		},
+	})
+}
+
+// Validate checks the entries to write to the path against the schema of
+// the longest prefix of the path. Paths without a schema are always valid.
+// A *SchemaValidationError is returned when the entries do not match.
+func (s *Schemas) Validate(ctx context.Context, path string, entries []*backend.BackendEntry) error {
+
+	schemas, err := s.all(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	prefix := ""
+	found := false
+
+	for candidate := range schemas {
+		if strings.HasPrefix(path+"/", candidate) && len(candidate) >= len(prefix) {
+			prefix = candidate
+			found = true
+		}
+	}
+
+	if !found {
+		return nil
+	}
+
+	compiled, err := compileSchema(schemas[prefix])
+
+	if err != nil {
+		return err
+	}
+
+	data := make(map[string]interface{}, len(entries))
+
+	for _, entry := range entries {
+
+		value, err := schemaValue(entry.Value)
+
+		if err != nil {
+			return fmt.Errorf("failed to decode '%s': %s", entry.Key, err.Error())
+		}
+
+		data[entry.Key] = value
+	}
+
+	err = compiled.Validate(data)
+
+	var validationErr *jsonschema.ValidationError
+
+	if !errors.As(err, &validationErr) {
+		return err
+	}
+
+	invalid := &SchemaValidationError{
+		Path:   path,
+		Prefix: prefix,
+	}
+
+	// Only the leaves describe what is wrong, while their parents only
+	// report the keyword which failed because of them.
+	var collect func(*jsonschema.ValidationError)
+
+	collect = func(cause *jsonschema.ValidationError) {
+
+		if len(cause.Causes) == 0 {
+
+			location := cause.InstanceLocation
+
+			if location == "" {
+				location = "/"
+			}
+
+			invalid.Violations = append(invalid.Violations, &SchemaViolation{
+				Location: location,
+				Message:  cause.Message,
+			})
+		}
+
+		for _, nested := range cause.Causes {
+			collect(nested)
+		}
+	}
+
+	collect(validationErr)
+
+	return invalid
+}
+
+// schemaPrefix is the folder form of the prefix, which schemas are
+// bound under.
+func schemaPrefix(prefix string) string {
+	return strings.TrimSuffix(prefix, "/") + "/"
+}
+
+// all reads every schema, keyed by prefix.
+func (s *Schemas) all(ctx context.Context) (map[string][]byte, error) {
+
+	entries, err := s.barrier.Get(ctx, schemasPath)
+
+	if err != nil {
+		return nil, err
+	}
+
+	schemas := make(map[string][]byte, len(entries))
+
+	for _, entry := range entries {
+		schemas[entry.Key] = entry.Value
+	}
+
+	return schemas, nil
+}
+
+// compileSchema compiles the schema. References are only resolved within
+// the schema itself, never loaded from files or the network.
+func compileSchema(schema []byte) (*jsonschema.Schema, error) {
+
+	compiler := jsonschema.NewCompiler()
+
+	compiler.LoadURL = func(url string) (io.ReadCloser, error) {
+		return nil, fmt.Errorf("external reference '%s' is not supported", url)
+	}
+
+	const url = "keyring://schema.json"
+
+	if err := compiler.AddResource(url, bytes.NewReader(schema)); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrSchemaInvalid, err.Error())
+	}
+
+	compiled, err := compiler.Compile(url)
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrSchemaInvalid, err.Error())
+	}
+
+	return compiled, nil
+}
+
+// schemaValue decodes a stored value for validation. Raw values are
+// validated as strings.
+func schemaValue(stored []byte) (interface{}, error) {
+
+	if IsRawValue(stored) {
+		return string(stored[1:]), nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(stored))
+	decoder.UseNumber()
+
+	var value interface{}
+
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
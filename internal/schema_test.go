@@ -0,0 +1,205 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSchemas(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("to slow for testing.Short. IO operations")
+	}
+
+	ctx := context.Background()
+
+	schemas, err := NewSchemas(setupBarrier(t))
+
+	if err != nil {
+		t.Fatalf("failed to instantiate schemas: %s", err.Error())
+	}
+
+	database := `{
+		"type": "object",
+		"required": ["user", "password"],
+		"properties": {
+			"user": {"type": "string"},
+			"password": {"type": "string", "minLength": 8},
+			"port": {"type": "integer"}
+		},
+		"additionalProperties": false
+	}`
+
+	if err := schemas.Put(ctx, "secret/db/", []byte(database)); err != nil {
+		t.Fatalf("failed to put schema: %s", err.Error())
+	}
+
+	if err := schemas.Put(ctx, "secret/", []byte(`{"type": "object", "minProperties": 1}`)); err != nil {
+		t.Fatalf("failed to put schema: %s", err.Error())
+	}
+
+	if err := schemas.Put(ctx, "secret/bad/", []byte(`{"type": "objet"}`)); !errors.Is(err, ErrSchemaInvalid) {
+		t.Fatalf("expected an invalid schema to be refused, but got %v", err)
+	}
+
+	prefixes, err := schemas.List(ctx)
+
+	if err != nil {
+		t.Fatalf("failed to list schemas: %s", err.Error())
+	}
+
+	if !reflect.DeepEqual(prefixes, []string{"secret/", "secret/db/"}) {
+		t.Fatalf("expected two prefixes with a schema, but got %v", prefixes)
+	}
+
+	valid := testEntries("user", `"admin"`, "password", `"correct-horse"`, "port", "5432")
+
+	if err := schemas.Validate(ctx, "secret/db/main", valid); err != nil {
+		t.Fatalf("expected valid entries to match: %s", err.Error())
+	}
+
+	// The typo is reported as both a missing and an unexpected key.
+	err = schemas.Validate(ctx, "secret/db/main", testEntries("user", `"admin"`, "pasword", `"correct-horse"`))
+
+	var invalid *SchemaValidationError
+
+	if !errors.As(err, &invalid) {
+		t.Fatalf("expected a schema validation error, but got %v", err)
+	}
+
+	if invalid.Prefix != "secret/db/" || len(invalid.Violations) != 2 {
+		t.Fatalf("expected 2 violations of secret/db/, but got %s", invalid.Error())
+	}
+
+	err = schemas.Validate(ctx, "secret/db/main", testEntries("user", `"admin"`, "password", `"short"`, "port", `"5432"`))
+
+	if !errors.As(err, &invalid) || len(invalid.Violations) != 2 {
+		t.Fatalf("expected the password length and port type to be reported, but got %v", err)
+	}
+
+	// The longest prefix applies.
+	if err := schemas.Validate(ctx, "secret/app", testEntries("token", `"abc"`)); err != nil {
+		t.Fatalf("expected secret/app to match the secret/ schema: %s", err.Error())
+	}
+
+	if err := schemas.Validate(ctx, "other/app", nil); err != nil {
+		t.Fatalf("expected paths without a schema to be valid: %s", err.Error())
+	}
+
+	// Prefixes are folders, so sibling paths sharing the name are not
+	// validated against the schema.
+	if err := schemas.Put(ctx, "other/db", []byte(`{"type": "object", "required": ["user"]}`)); err != nil {
+		t.Fatalf("failed to put schema: %s", err.Error())
+	}
+
+	if err := schemas.Validate(ctx, "other/db2", nil); err != nil {
+		t.Fatalf("expected the sibling path to not match other/db: %s", err.Error())
+	}
+
+	for _, path := range []string{"other/db", "other/db/main"} {
+		if err := schemas.Validate(ctx, path, nil); !errors.As(err, &invalid) || invalid.Prefix != "other/db/" {
+			t.Fatalf("expected %s to match other/db/, but got %v", path, err)
+		}
+	}
+
+	if _, err := schemas.Get(ctx, "other/db/"); err != nil {
+		t.Fatalf("expected the schema to be bound to the folder: %s", err.Error())
+	}
+
+	if err := schemas.Delete(ctx, "secret/db/"); err != nil {
+		t.Fatalf("failed to delete schema: %s", err.Error())
+	}
+
+	if _, err := schemas.Get(ctx, "secret/db/"); !errors.Is(err, ErrSchemaNotFound) {
+		t.Fatalf("expected the deleted schema to not be found, but got %v", err)
+	}
+}
+
+func TestKVSchemas(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("to slow for testing.Short. IO operations")
+	}
+
+	ctx := context.Background()
+
+	kv, err := NewKV(setupBarrier(t))
+
+	if err != nil {
+		t.Fatalf("failed to instantiate kv store: %s", err.Error())
+	}
+
+	if _, err := kv.Put(ctx, "staging/db", testEntries("user", `"admin"`), nil); err != nil {
+		t.Fatalf("failed to put secret: %s", err.Error())
+	}
+
+	if _, err := kv.Put(ctx, "secret/db/main", testEntries("user", `"admin"`, "password", `"hunter22"`), nil); err != nil {
+		t.Fatalf("failed to put secret: %s", err.Error())
+	}
+
+	schema := `{"type": "object", "required": ["user", "password"]}`
+
+	if err := kv.schemas.Put(ctx, "secret/db/", []byte(schema)); err != nil {
+		t.Fatalf("failed to put schema: %s", err.Error())
+	}
+
+	var invalid *SchemaValidationError
+
+	_, err = kv.Put(ctx, "secret/db/main", testEntries("user", `"admin"`), nil)
+
+	if !errors.As(err, &invalid) {
+		t.Fatalf("expected put to be refused, but got %v", err)
+	}
+
+	// Imports write with a check-and-set version.
+	cas := uint64(0)
+
+	_, err = kv.Put(ctx, "secret/db/imported", testEntries("user", `"admin"`), &KVPutOptions{CAS: &cas})
+
+	if !errors.As(err, &invalid) {
+		t.Fatalf("expected import to be refused, but got %v", err)
+	}
+
+	_, err = kv.Unset(ctx, "secret/db/main", []string{"password"}, nil)
+
+	if !errors.As(err, &invalid) {
+		t.Fatalf("expected unset to be refused, but got %v", err)
+	}
+
+	// Version 1 was written before the schema was bound.
+	if _, err := kv.Put(ctx, "secret/db/main", testEntries("user", `"root"`, "password", `"hunter33"`), nil); err != nil {
+		t.Fatalf("failed to put secret: %s", err.Error())
+	}
+
+	if err := kv.schemas.Put(ctx, "secret/db/", []byte(`{"type": "object", "required": ["user", "password"], "properties": {"user": {"const": "root"}}}`)); err != nil {
+		t.Fatalf("failed to put schema: %s", err.Error())
+	}
+
+	_, err = kv.Rollback(ctx, "secret/db/main", 1, nil)
+
+	if !errors.As(err, &invalid) {
+		t.Fatalf("expected rollback to be refused, but got %v", err)
+	}
+
+	err = kv.Copy(ctx, []*KVRelocation{{Source: "staging/db", Destination: "secret/db/copy"}}, false)
+
+	if !errors.As(err, &invalid) {
+		t.Fatalf("expected copy to be refused, but got %v", err)
+	}
+
+	secret, err := kv.Get(ctx, "secret/db/main")
+
+	if err != nil {
+		t.Fatalf("failed to get secret: %s", err.Error())
+	}
+
+	if secret.Version != 2 {
+		t.Fatalf("expected the refused writes to leave version 2, but got %d", secret.Version)
+	}
+
+	if metadata, err := kv.Metadata(ctx, "secret/db/copy"); err != nil || metadata != nil {
+		t.Fatalf("expected the refused copy to write nothing, but got %v", err)
+	}
+}
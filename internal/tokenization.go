@@ -0,0 +1,228 @@
+package internal
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/woodrufj4/keyring-practice/backend"
+)
+
+const (
+
+	// TokenPrefix is the namespace where token mappings are persisted.
+	TokenPrefix = KeyringPrefix + "tokens/"
+
+	tokenValueKey      = "value"
+	tokenExpireTimeKey = "expire_time"
+	tokenSize          = 32
+	tokenKeyPurpose    = "tokenization"
+)
+
+var (
+	ErrTokenRoleInvalid = errors.New("token role is invalid")
+	ErrTokenInvalid     = errors.New("token is invalid")
+	ErrTokenNotFound    = errors.New("token not found")
+	ErrTokenExpired     = errors.New("token expired")
+)
+
+// TokenizeOptions controls how a value is mapped to a token.
+type TokenizeOptions struct {
+
+	// TTL is how long the token mapping is valid for.
+	// A zero TTL never expires.
+	TTL time.Duration
+
+	// Convergent maps the same value to the same token within a role.
+	Convergent bool
+}
+
+// Tokenizer maps sensitive values to opaque tokens. The values are
+// encrypted through the barrier and stored in the token mapping namespace.
+type Tokenizer struct {
+	barrier *Barrier
+	now     func() time.Time
+}
+
+// NewTokenizer instantiates a new tokenizer backed by an
+// initialized barrier.
+func NewTokenizer(barrier *Barrier) (*Tokenizer, error) {
+
+	if barrier == nil || !barrier.Initialized() {
+		return nil, ErrKeyringNotSet
+	}
+
+	return &Tokenizer{
+		barrier: barrier,
+		now:     time.Now,
+	}, nil
+}
+
+// Tokenize stores the value and returns the token that maps to it.
+//
+// Tokenizing a value again with the convergent option refreshes the
+// expiration of the existing mapping.
+func (t *Tokenizer) Tokenize(ctx context.Context, role string, value []byte, opts *TokenizeOptions) (string, error) {
+
+	if err := validateTokenRole(role); err != nil {
+		return "", err
+	}
+
+	if opts == nil {
+		opts = &TokenizeOptions{}
+	}
+
+	if opts.TTL < 0 {
+		return "", fmt.Errorf("token ttl must not be negative")
+	}
+
+	var token string
+	var err error
+
+	if opts.Convergent {
+		token, err = t.convergentToken(role, value)
+	} else {
+		token, err = randomToken()
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("failed to generate token: %s", err.Error())
+	}
+
+	// The expiration is always written, so a refreshed mapping
+	// never keeps a stale expiration.
+	var expireTime string
+
+	if opts.TTL > 0 {
+		expireTime = t.now().Add(opts.TTL).UTC().Format(time.RFC3339Nano)
+	}
+
+	entries := []*backend.BackendEntry{
+		{
+			Key:   tokenValueKey,
+			Value: value,
+		},
+		{
+			Key:   tokenExpireTimeKey,
+			Value: []byte(expireTime),
+		},
+	}
+
+	if err := t.barrier.Put(ctx, tokenPath(role, token), entries); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// Detokenize retrieves the value mapped to the token.
+//
+// Expired token mappings are removed and reported as ErrTokenExpired.
+func (t *Tokenizer) Detokenize(ctx context.Context, role string, token string) ([]byte, error) {
+
+	if err := validateTokenRole(role); err != nil {
+		return nil, err
+	}
+
+	if token == "" || strings.Contains(token, "/") {
+		return nil, ErrTokenInvalid
+	}
+
+	path := tokenPath(role, token)
+
+	entries, err := t.barrier.Get(ctx, path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if entries == nil {
+		return nil, ErrTokenNotFound
+	}
+
+	var value []byte
+
+	for _, entry := range entries {
+
+		switch entry.Key {
+
+		case tokenValueKey:
+			value = entry.Value
+
+		case tokenExpireTimeKey:
+
+			if len(entry.Value) == 0 {
+				continue
+			}
+
+			expireTime, err := time.Parse(time.RFC3339Nano, string(entry.Value))
+
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse token expiration: %s", err.Error())
+			}
+
+			if !t.now().Before(expireTime) {
+
+				if err := t.barrier.Delete(ctx, path); err != nil {
+					return nil, fmt.Errorf("failed to remove expired token: %s", err.Error())
+				}
+
+				return nil, ErrTokenExpired
+			}
+		}
+	}
+
+	if value == nil {
+		return nil, ErrTokenNotFound
+	}
+
+	return value, nil
+}
+
+// convergentToken derives a deterministic token from the role and value.
+func (t *Tokenizer) convergentToken(role string, value []byte) (string, error) {
+
+	key, err := t.barrier.deriveKey(tokenKeyPurpose)
+
+	if err != nil {
+		return "", err
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(role))
+	mac.Write([]byte{0})
+	mac.Write(value)
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// randomToken generates a token that has no relation to the value.
+func randomToken() (string, error) {
+
+	buf := make([]byte, tokenSize)
+
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func tokenPath(role string, token string) string {
+	return TokenPrefix + role + "/" + token
+}
+
+func validateTokenRole(role string) error {
+
+	if role == "" || strings.Contains(role, "/") {
+		return ErrTokenRoleInvalid
+	}
+
+	return nil
+}
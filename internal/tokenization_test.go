@@ -0,0 +1,128 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTokenize(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("to slow for testing.Short. IO operations")
+	}
+
+	tokenizer, err := NewTokenizer(setupBarrier(t))
+
+	if err != nil {
+		t.Fatalf("failed to instantiate tokenizer: %s", err.Error())
+	}
+
+	value := []byte("4111-1111-1111-1111")
+
+	token1, err := tokenizer.Tokenize(context.Background(), "cards", value, nil)
+
+	if err != nil {
+		t.Fatalf("failed to tokenize value: %s", err.Error())
+	}
+
+	token2, err := tokenizer.Tokenize(context.Background(), "cards", value, nil)
+
+	if err != nil {
+		t.Fatalf("failed to tokenize value: %s", err.Error())
+	}
+
+	if token1 == token2 {
+		t.Fatalf("expected non-convergent tokens to differ, but both were %s", token1)
+	}
+
+	detokenized, err := tokenizer.Detokenize(context.Background(), "cards", token1)
+
+	if err != nil {
+		t.Fatalf("failed to detokenize token: %s", err.Error())
+	}
+
+	if !bytes.Equal(detokenized, value) {
+		t.Fatalf("expected detokenized value to be %s, but got %s", value, detokenized)
+	}
+
+	if _, err := tokenizer.Detokenize(context.Background(), "other", token1); err != ErrTokenNotFound {
+		t.Fatalf("expected token to be scoped to its role, but got %v", err)
+	}
+
+}
+
+func TestTokenizeConvergent(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("to slow for testing.Short. IO operations")
+	}
+
+	tokenizer, err := NewTokenizer(setupBarrier(t))
+
+	if err != nil {
+		t.Fatalf("failed to instantiate tokenizer: %s", err.Error())
+	}
+
+	opts := &TokenizeOptions{Convergent: true}
+
+	token1, err := tokenizer.Tokenize(context.Background(), "ssn", []byte("123-45-6789"), opts)
+
+	if err != nil {
+		t.Fatalf("failed to tokenize value: %s", err.Error())
+	}
+
+	token2, err := tokenizer.Tokenize(context.Background(), "ssn", []byte("123-45-6789"), opts)
+
+	if err != nil {
+		t.Fatalf("failed to tokenize value: %s", err.Error())
+	}
+
+	if token1 != token2 {
+		t.Fatalf("expected convergent tokens to match, but got %s and %s", token1, token2)
+	}
+
+	token3, err := tokenizer.Tokenize(context.Background(), "other", []byte("123-45-6789"), opts)
+
+	if err != nil {
+		t.Fatalf("failed to tokenize value: %s", err.Error())
+	}
+
+	if token1 == token3 {
+		t.Fatalf("expected convergent tokens to differ across roles")
+	}
+
+}
+
+func TestTokenizeExpired(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("to slow for testing.Short. IO operations")
+	}
+
+	tokenizer, err := NewTokenizer(setupBarrier(t))
+
+	if err != nil {
+		t.Fatalf("failed to instantiate tokenizer: %s", err.Error())
+	}
+
+	token, err := tokenizer.Tokenize(context.Background(), "cards", []byte("secret"), &TokenizeOptions{TTL: time.Hour})
+
+	if err != nil {
+		t.Fatalf("failed to tokenize value: %s", err.Error())
+	}
+
+	tokenizer.now = func() time.Time {
+		return time.Now().Add(2 * time.Hour)
+	}
+
+	if _, err := tokenizer.Detokenize(context.Background(), "cards", token); err != ErrTokenExpired {
+		t.Fatalf("expected token to be expired, but got %v", err)
+	}
+
+	if _, err := tokenizer.Detokenize(context.Background(), "cards", token); err != ErrTokenNotFound {
+		t.Fatalf("expected expired token to be removed, but got %v", err)
+	}
+
+}
@@ -0,0 +1,95 @@
+package internal
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+)
+
+const (
+
+	// ValueEncodingJSON stores values as json, which is the default.
+	ValueEncodingJSON = "json"
+
+	// ValueEncodingRaw stores the bytes of values as is.
+	ValueEncodingRaw = "raw"
+
+	// ValueEncodingBase64 decodes base64 values, storing the decoded bytes
+	// as is.
+	ValueEncodingBase64 = "base64"
+)
+
+// rawValueMarker prefixes values stored as raw bytes. A json document can
+// never begin with a NUL byte, so raw values are told apart from json
+// values without any additional metadata.
+const rawValueMarker = 0x00
+
+// EncodeValue encodes the value for storage as json.
+func EncodeValue(value interface{}) ([]byte, error) {
+	return json.Marshal(value)
+}
+
+// EncodeRawValue encodes the bytes for storage as is.
+func EncodeRawValue(raw []byte) []byte {
+
+	encoded := make([]byte, 0, len(raw)+1)
+	encoded = append(encoded, rawValueMarker)
+
+	return append(encoded, raw...)
+}
+
+// EncodeValueAs encodes the value for storage with the provided encoding.
+// The raw and base64 encodings only accept string values.
+func EncodeValueAs(encoding string, value interface{}) ([]byte, error) {
+
+	if encoding == ValueEncodingJSON || encoding == "" {
+		return EncodeValue(value)
+	}
+
+	str, ok := value.(string)
+
+	if !ok {
+		return nil, fmt.Errorf("only string values can be stored with the %s encoding", encoding)
+	}
+
+	switch encoding {
+
+	case ValueEncodingRaw:
+		return EncodeRawValue([]byte(str)), nil
+
+	case ValueEncodingBase64:
+
+		raw, err := base64.StdEncoding.DecodeString(str)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode base64 value: %s", err.Error())
+		}
+
+		return EncodeRawValue(raw), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported value encoding '%s'", encoding)
+	}
+}
+
+// DecodeValue decodes a stored value. Raw values are returned as []byte,
+// while json values are returned as decoded by encoding/json.
+func DecodeValue(stored []byte) (interface{}, error) {
+
+	if IsRawValue(stored) {
+		return append([]byte{}, stored[1:]...), nil
+	}
+
+	var value interface{}
+
+	if err := json.Unmarshal(stored, &value); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal json: %s", err.Error())
+	}
+
+	return value, nil
+}
+
+// IsRawValue reports if the stored value holds raw bytes rather than json.
+func IsRawValue(stored []byte) bool {
+	return len(stored) > 0 && stored[0] == rawValueMarker
+}
@@ -0,0 +1,60 @@
+package internal
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestValueEncoding(t *testing.T) {
+
+	binary := []byte{0x00, 0xff, '{', '"', '\n', 0x7f}
+
+	cases := []struct {
+		name     string
+		encoding string
+		value    interface{}
+		expected interface{}
+	}{
+		{"json string", ValueEncodingJSON, "bar", "bar"},
+		{"json object", "", map[string]interface{}{"nested": true}, map[string]interface{}{"nested": true}},
+		{"raw", ValueEncodingRaw, string(binary), binary},
+		{"base64", ValueEncodingBase64, "AP97Igp/", binary},
+	}
+
+	for _, tc := range cases {
+
+		stored, err := EncodeValueAs(tc.encoding, tc.value)
+
+		if err != nil {
+			t.Fatalf("%s: failed to encode value: %s", tc.name, err.Error())
+		}
+
+		decoded, err := DecodeValue(stored)
+
+		if err != nil {
+			t.Fatalf("%s: failed to decode value: %s", tc.name, err.Error())
+		}
+
+		if !reflect.DeepEqual(decoded, tc.expected) {
+			t.Fatalf("%s: expected %#v, but got %#v", tc.name, tc.expected, decoded)
+		}
+	}
+
+	if _, err := EncodeValueAs(ValueEncodingRaw, 42.0); err == nil {
+		t.Fatal("expected raw encoding of a number to fail")
+	}
+
+	if _, err := EncodeValueAs(ValueEncodingBase64, "not base64!"); err == nil {
+		t.Fatal("expected invalid base64 to fail")
+	}
+
+	stored := EncodeRawValue(binary)
+	decoded, _ := DecodeValue(stored)
+
+	decoded.([]byte)[0] = 0x01
+
+	if !bytes.Equal(stored[1:], binary) {
+		t.Fatal("expected decoded raw values to not alias the stored value")
+	}
+}